@@ -0,0 +1,239 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"math"
+	"sort"
+	"time"
+)
+
+/*
+回测:
+
+每根 K 线先在模拟交易所 (FakeExchange) 上把盘口依次移动到 open, 离 open 近的一端 (阳线先 low 后 high, 阴线先 high 后 low), close,
+挂单和止损单在途经的价格成交, 限价单按挂单价成交 (maker 费率); 然后把这根收盘的 K 线交给 Strategy.
+Strategy 此时下的市价单按 close 成交 (taker 费率), 限价单要等后面的 K 线经过挂单价才成交, 不会用到未来的价格.
+盘口每个价格上挂 Depth 的数量 (默认这根 K 线的成交量), 市价单超出的部分过期; 没设 Depth 时成交量为 0 的 K 线盘口是空的,
+停牌或冷门交易对上没有成交的 K 线里市价单不成交直接过期.
+交易对的过滤器和手续费率取自真实账户 (exchangeInfo, TradeFee), 下单走同一个 SpotClient.Trade, 所以会被同样的过滤器拒绝.
+手续费从收到的资产里扣 (买入扣 base, 卖出扣 quote). 模拟交易所不冻结挂单占用的余额, 成交时余额不够的挂单过期.
+*/
+
+// BacktestReq replay candles of Symbol through a simulated exchange
+type BacktestReq struct {
+	Symbol    string            `json:"symbol"`
+	Interval  string            `json:"interval"`  // required
+	StartTime int64             `json:"startTime"` // open time, ms, 0 from the first candle
+	EndTime   int64             `json:"endTime"`   // open time, ms, 0 to the last candle
+	Balances  map[string]string `json:"balances"`  // starting balances, base and quote asset default to 0
+	Depth     string            `json:"depth"`     // base quantity quoted at each price of a candle, default the candle volume
+	Klines    []*Kline          `json:"-"`         // candles replayed, read from Store when empty
+	Store     *KlineStore       `json:"-"`
+}
+
+// EquityPoint account value at a candle's close
+type EquityPoint struct {
+	Time     int64   `json:"time"`     // close time, ms
+	Price    Decimal `json:"price"`    // close
+	Equity   Decimal `json:"equity"`   // balances valued in the quote asset
+	Drawdown float64 `json:"drawdown"` // fraction below the highest equity so far
+}
+
+type BacktestResp struct {
+	Symbol      string             `json:"symbol"`
+	QuoteAsset  string             `json:"quoteAsset"` // asset of the equity values
+	Klines      int                `json:"klines"`
+	StartEquity Decimal            `json:"startEquity"` // starting balances at the first open
+	EndEquity   Decimal            `json:"endEquity"`
+	Return      float64            `json:"return"`      // EndEquity / StartEquity - 1
+	MaxDrawdown float64            `json:"maxDrawdown"` // largest fraction below a previous high
+	Sharpe      float64            `json:"sharpe"`      // annualized from candle returns, zero risk free rate
+	Equity      []*EquityPoint     `json:"equity"`
+	Trades      []*binance.TradeV3 `json:"trades"`
+	Orders      []*binance.Order   `json:"orders"`
+	Fees        map[string]Decimal `json:"fees"`      // commission paid by asset
+	FeesQuote   Decimal            `json:"feesQuote"` // commission valued in the quote asset at the fill price
+	Balances    map[string]Decimal `json:"balances"`  // at the end
+}
+
+// klinePath prices the book moves through within k: open, the nearer extreme, the other extreme, close
+func klinePath(k *Kline) []Decimal {
+	if k.Close.LessThan(k.Open) {
+		return []Decimal{k.Open, k.High, k.Low, k.Close}
+	}
+	return []Decimal{k.Open, k.Low, k.High, k.Close}
+}
+
+// Backtest run strategy over the candles of req, with the symbol filters and fee rates of this client's account
+func (c *SpotClient) Backtest(ctx context.Context, req *BacktestReq, strategy Strategy) (*BacktestResp, error) {
+	interval, ok := KlineInterval(req.Interval)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("invalid kline interval %q", req.Interval))
+	}
+	symbol, err := c.registry.Symbol(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	fees, err := c.exchange.TradeFee(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(fees) == 0 {
+		return nil, errors.New(fmt.Sprintf("no trade fee for %s", req.Symbol))
+	}
+
+	klines := req.Klines
+	if len(klines) == 0 && req.Store != nil {
+		if klines, err = req.Store.Read(req.Symbol, req.Interval, req.StartTime, req.EndTime); err != nil {
+			return nil, err
+		}
+	}
+	var replay []*Kline
+	for _, k := range klines {
+		if (req.StartTime == 0 || k.OpenTime >= req.StartTime) && (req.EndTime == 0 || k.OpenTime <= req.EndTime) {
+			replay = append(replay, k)
+		}
+	}
+	if len(replay) == 0 {
+		return nil, errors.New(fmt.Sprintf("no %s %s klines to replay", req.Symbol, req.Interval))
+	}
+	sort.Slice(replay, func(i, j int) bool {
+		return replay[i].OpenTime < replay[j].OpenTime
+	})
+
+	var clock time.Time
+	sim := NewFakeExchange()
+	sim.now = func() time.Time { return clock }
+	raw := symbol.Raw
+	raw.Status = "TRADING" // delisted symbols replay too
+	sim.AddSymbol(raw)
+	sim.SetTradeFee(req.Symbol, fees[0].MakerCommission, fees[0].TakerCommission)
+	sim.SetChargeFees(true)
+	sim.SetBalance(symbol.BaseAsset, "0")
+	sim.SetBalance(symbol.QuoteAsset, "0")
+	for asset, free := range req.Balances {
+		if _, err = ParseDecimal(free); err != nil {
+			return nil, fmt.Errorf("balance %s %w", asset, err)
+		}
+		sim.SetBalance(asset, free)
+	}
+	simCli := NewSpotClientWithExchange(sim)
+
+	var depth Decimal
+	if req.Depth != "" {
+		if depth, err = ParseDecimal(req.Depth); err != nil {
+			return nil, fmt.Errorf("depth %w", err)
+		}
+	}
+	equity := func(price Decimal) Decimal {
+		return sim.Balance(symbol.QuoteAsset).Add(sim.Balance(symbol.BaseAsset).Mul(price))
+	}
+	resp := &BacktestResp{
+		Symbol:      req.Symbol,
+		QuoteAsset:  symbol.QuoteAsset,
+		Klines:      len(replay),
+		StartEquity: equity(replay[0].Open),
+		Fees:        map[string]Decimal{},
+	}
+	for _, k := range replay {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		clock = time.Unix(0, k.OpenTime*int64(time.Millisecond))
+		quantity := depth
+		if quantity.Sign() <= 0 {
+			quantity = k.Volume
+		}
+		for _, price := range klinePath(k) {
+			sim.SetAveragePrice(req.Symbol, price.String())
+			sim.SetBookTicker(req.Symbol, price.String(), quantity.String(), price.String(), quantity.String())
+		}
+		// market orders of the strategy take the book at the close, a candle without trades quotes none
+		var level []binance.Bid
+		if quantity.Sign() > 0 {
+			level = []binance.Bid{{Price: k.Close.String(), Quantity: quantity.String()}}
+		}
+		sim.SetDepth(req.Symbol, k.OpenTime, level, level)
+		clock = time.Unix(0, k.CloseTime*int64(time.Millisecond))
+		if err = strategy.OnKline(ctx, simCli, req.Symbol, k); err != nil {
+			return nil, fmt.Errorf("kline %d %w", k.OpenTime, err)
+		}
+		resp.Equity = append(resp.Equity, &EquityPoint{Time: k.CloseTime, Price: k.Close, Equity: equity(k.Close)})
+	}
+
+	resp.EndEquity = resp.Equity[len(resp.Equity)-1].Equity
+	if resp.StartEquity.Sign() > 0 {
+		resp.Return = resp.EndEquity.Float64()/resp.StartEquity.Float64() - 1
+	}
+	resp.MaxDrawdown = equityDrawdowns(resp.StartEquity, resp.Equity)
+	resp.Sharpe = equitySharpe(resp.StartEquity, resp.Equity, interval)
+
+	sim.mu.Lock()
+	resp.Trades = append(resp.Trades, sim.trades...)
+	resp.Orders = append(resp.Orders, sim.orders...)
+	resp.Balances = make(map[string]Decimal, len(sim.balances))
+	for asset, balance := range sim.balances {
+		resp.Balances[asset] = balance
+	}
+	sim.mu.Unlock()
+	for _, trade := range resp.Trades {
+		commission := MustDecimal(trade.Commission)
+		resp.Fees[trade.CommissionAsset] = resp.Fees[trade.CommissionAsset].Add(commission)
+		if trade.CommissionAsset != symbol.QuoteAsset {
+			commission = commission.Mul(MustDecimal(trade.Price))
+		}
+		resp.FeesQuote = resp.FeesQuote.Add(commission)
+	}
+	return resp, nil
+}
+
+// equityDrawdowns set the Drawdown of every point and return the largest, start is the first high
+func equityDrawdowns(start Decimal, points []*EquityPoint) float64 {
+	high, max := start.Float64(), 0.0
+	for _, point := range points {
+		equity := point.Equity.Float64()
+		if equity > high {
+			high = equity
+		}
+		if high > 0 {
+			point.Drawdown = (high - equity) / high
+		}
+		if point.Drawdown > max {
+			max = point.Drawdown
+		}
+	}
+	return max
+}
+
+// equitySharpe mean over standard deviation of the candle returns, scaled to a year
+func equitySharpe(start Decimal, points []*EquityPoint, interval time.Duration) float64 {
+	var returns []float64
+	prev := start.Float64()
+	for _, point := range points {
+		equity := point.Equity.Float64()
+		if prev > 0 {
+			returns = append(returns, equity/prev-1)
+		}
+		prev = equity
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std == 0 {
+		return 0
+	}
+	return mean / std * math.Sqrt(float64(365*24*time.Hour)/float64(interval))
+}
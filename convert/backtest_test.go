@@ -0,0 +1,178 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+// testCandles 1m candles from start opening at the previous close, 0.1 beyond the body unless highs overrides
+func testCandles(start int64, closes []string, highs map[int]string) []*Kline {
+	minute := int64(time.Minute / time.Millisecond)
+	var klines []*Kline
+	open := MustDecimal(closes[0])
+	for i, c := range closes {
+		close := MustDecimal(c)
+		high := MaxDecimal(open, close).Add(MustDecimal("0.1"))
+		if h, ok := highs[i]; ok {
+			high = MustDecimal(h)
+		}
+		klines = append(klines, &Kline{
+			OpenTime: start + int64(i)*minute, Open: open, High: high, Low: MinDecimal(open, close).Sub(MustDecimal("0.1")), Close: close,
+			Volume: MustDecimal("100"), CloseTime: start + int64(i+1)*minute - 1,
+		})
+		open = close
+	}
+	return klines
+}
+
+func TestBacktest(t *testing.T) {
+	convey.Convey("TestBacktest", t, func(convCtx convey.C) {
+		live := newTestFakeExchange()
+		live.SetTradeFee("EOSBTC", "0.001", "0.002")
+		cli := NewSpotClientWithExchange(live)
+		ctx := context.Background()
+		start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+		klines := testCandles(start, []string{"2.0", "1.9", "2.1", "2.3", "2.2"}, map[int]string{3: "2.5"})
+
+		// buy 1 BTC worth at the first close, take profit at 2.4
+		var seen []int64
+		strategy := StrategyFunc(func(ctx context.Context, cli *SpotClient, symbol string, kline *Kline) error {
+			seen = append(seen, kline.OpenTime)
+			if len(seen) > 1 {
+				return nil
+			}
+			if _, err := cli.Trade(ctx, &TradeReq{Symbol: symbol, Side: "BUY", Quantity: "1"}); err != nil {
+				return err
+			}
+			asset, err := cli.GetUserAsset(ctx, &UserAssetReq{Asset: "EOS"})
+			if err != nil {
+				return err
+			}
+			_, err = cli.Trade(ctx, &TradeReq{Symbol: symbol, Side: "SELL", Type: "LIMIT", Quantity: asset.Data[0].Free, Price: "2.4"})
+			return err
+		})
+		resp, err := cli.Backtest(ctx, &BacktestReq{Symbol: "EOSBTC", Interval: "1m", Balances: map[string]string{"BTC": "10"}, Klines: klines}, strategy)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(seen), convey.ShouldEqual, 5)
+		convCtx.So(resp.Klines, convey.ShouldEqual, 5)
+		convCtx.So(len(resp.Trades), convey.ShouldEqual, 2)
+
+		// taker buy pays 0.2% in EOS, the resting sell fills on the 4th candle as maker and pays 0.1% in BTC
+		buy, sell := resp.Trades[0], resp.Trades[1]
+		convCtx.So(buy.Price, convey.ShouldEqual, "2.00000000")
+		convCtx.So(buy.Commission, convey.ShouldEqual, "0.00100000")
+		convCtx.So(buy.CommissionAsset, convey.ShouldEqual, "EOS")
+		convCtx.So(buy.IsMaker, convey.ShouldBeFalse)
+		convCtx.So(sell.Price, convey.ShouldEqual, "2.40000000")
+		convCtx.So(sell.Quantity, convey.ShouldEqual, "0.40000000")
+		convCtx.So(sell.Commission, convey.ShouldEqual, "0.00096000")
+		convCtx.So(sell.CommissionAsset, convey.ShouldEqual, "BTC")
+		convCtx.So(sell.IsMaker, convey.ShouldBeTrue)
+		convCtx.So(sell.Time, convey.ShouldEqual, klines[3].OpenTime)
+		convCtx.So(resp.Fees["EOS"].String(), convey.ShouldEqual, "0.001")
+		convCtx.So(resp.Fees["BTC"].String(), convey.ShouldEqual, "0.00096")
+		convCtx.So(resp.FeesQuote.String(), convey.ShouldEqual, "0.00296")
+		convCtx.So(resp.Balances["BTC"].String(), convey.ShouldEqual, "9.95904")
+		convCtx.So(resp.Balances["EOS"].String(), convey.ShouldEqual, "0.099")
+		convCtx.So(resp.Orders[1].Status, convey.ShouldEqual, binance.OrderStatusTypeFilled)
+
+		convCtx.So(resp.StartEquity.String(), convey.ShouldEqual, "10")
+		convCtx.So(resp.Equity[1].Equity.String(), convey.ShouldEqual, "9.9481")
+		convCtx.So(resp.EndEquity.String(), convey.ShouldEqual, "10.17684")
+		convCtx.So(resp.Return, convey.ShouldAlmostEqual, 0.017684, 1e-9)
+		convCtx.So(resp.MaxDrawdown, convey.ShouldAlmostEqual, 0.00519, 1e-9)
+		convCtx.So(resp.Equity[4].Drawdown, convey.ShouldAlmostEqual, (10.18674-10.17684)/10.18674, 1e-9)
+		convCtx.So(resp.Sharpe, convey.ShouldBeGreaterThan, 0)
+		convCtx.So(live.Balance("BTC").IsZero(), convey.ShouldBeTrue)
+
+		// the same filters as live trading
+		_, err = cli.Backtest(ctx, &BacktestReq{Symbol: "EOSBTC", Interval: "1m", Balances: map[string]string{"BTC": "10"}, Klines: klines},
+			StrategyFunc(func(ctx context.Context, cli *SpotClient, symbol string, kline *Kline) error {
+				_, err := cli.Trade(ctx, &TradeReq{Symbol: symbol, Side: "BUY", Quantity: "0.00001"})
+				return err
+			}))
+		convCtx.So(errors.Is(err, ErrFilterViolation), convey.ShouldBeTrue)
+
+		// market orders expire unfilled on a candle without volume, unless Depth quotes a book, which caps what one takes
+		quiet := testCandles(start, []string{"2.0", "2.0", "2.1"}, nil)
+		quiet[1].Volume = Zero
+		var fills []*TradeResp
+		buy1 := StrategyFunc(func(ctx context.Context, cli *SpotClient, symbol string, kline *Kline) error {
+			resp, err := cli.Trade(ctx, &TradeReq{Symbol: symbol, Side: "BUY", Quantity: "1", QuantityKind: QuantityKindBase})
+			fills = append(fills, resp)
+			return err
+		})
+		resp, err = cli.Backtest(ctx, &BacktestReq{Symbol: "EOSBTC", Interval: "1m", Balances: map[string]string{"BTC": "10"}, Klines: quiet}, buy1)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(resp.Trades), convey.ShouldEqual, 2)
+		convCtx.So(fills[1].Status, convey.ShouldEqual, binance.OrderStatusTypeExpired)
+		convCtx.So(fills[1].ExecutedQuantity, convey.ShouldEqual, "0.00000000")
+		convCtx.So(resp.Trades[1].Price, convey.ShouldEqual, "2.10000000")
+		fills = nil
+		resp, err = cli.Backtest(ctx, &BacktestReq{Symbol: "EOSBTC", Interval: "1m", Balances: map[string]string{"BTC": "10"}, Klines: quiet, Depth: "0.5"}, buy1)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(fills[1].Status, convey.ShouldEqual, binance.OrderStatusTypeExpired)
+		convCtx.So(fills[1].ExecutedQuantity, convey.ShouldEqual, "0.50000000")
+		_, err = cli.Backtest(ctx, &BacktestReq{Symbol: "EOSBTC", Interval: "1m", Klines: quiet, Depth: "x"}, buy1)
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		// replayed from a store
+		store, err := OpenKlineStore(t.TempDir())
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(store.Append("EOSBTC", "1m", klines), convey.ShouldBeNil)
+		seen = nil
+		resp, err = cli.Backtest(ctx, &BacktestReq{Symbol: "EOSBTC", Interval: "1m", StartTime: klines[1].OpenTime, Balances: map[string]string{"BTC": "10"}, Store: store}, strategy)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(seen, convey.ShouldResemble, []int64{klines[1].OpenTime, klines[2].OpenTime, klines[3].OpenTime, klines[4].OpenTime})
+		convCtx.So(resp.Trades[0].Price, convey.ShouldEqual, "1.90000000")
+
+		_, err = cli.Backtest(ctx, &BacktestReq{Symbol: "EOSBTC", Interval: "1m", Store: store, StartTime: start + 3600000}, strategy)
+		convCtx.So(err, convey.ShouldNotBeNil)
+		_, err = cli.Backtest(ctx, &BacktestReq{Symbol: "EOSBTC", Klines: klines}, strategy)
+		convCtx.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestRunStrategy(t *testing.T) {
+	convey.Convey("TestRunStrategy", t, func(convCtx convey.C) {
+		server, conns := newTestWsServer()
+		defer server.Close()
+		stream := NewMarketStream(&StreamConfig{BaseURL: testWsURL(server)})
+		defer stream.Close()
+		cli := NewSpotClientWithExchange(newTestFakeExchange())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		klines := make(chan *Kline, 4)
+		done := make(chan error, 1)
+		go func() {
+			done <- RunStrategy(ctx, cli, stream, "EOSBTC", "1m", StrategyFunc(func(ctx context.Context, c *SpotClient, symbol string, kline *Kline) error {
+				if c != cli || symbol != "EOSBTC" {
+					return errors.New("wrong client or symbol")
+				}
+				klines <- kline
+				return nil
+			}))
+		}()
+		conn := waitConn(conns)
+		convCtx.So(conn.URL, convey.ShouldEqual, "/stream?streams=eosbtc@kline_1m")
+		for i, final := range []bool{false, true} {
+			msg := fmt.Sprintf(`{"stream":"eosbtc@kline_1m","data":{"e":"kline","s":"EOSBTC","k":{"t":60000,"T":119999,"i":"1m","o":"2","h":"2.2","l":"1.9","c":"2.%d","v":"10","x":%v}}}`, i, final)
+			convCtx.So(conn.WriteMessage(websocket.TextMessage, []byte(msg)), convey.ShouldBeNil)
+		}
+		kline := <-klines
+		convCtx.So(kline.OpenTime, convey.ShouldEqual, 60000)
+		convCtx.So(kline.Close.String(), convey.ShouldEqual, "2.1")
+		convCtx.So(kline.Volume.String(), convey.ShouldEqual, "10")
+
+		cancel()
+		convCtx.So(<-done, convey.ShouldEqual, context.Canceled)
+		convCtx.So(stream.Streams(), convey.ShouldBeEmpty)
+		convCtx.So(len(klines), convey.ShouldEqual, 0)
+	})
+}
@@ -6,29 +6,58 @@ import (
 	"fmt"
 	"github.com/jinzhu/copier"
 	"github.com/pursonchen/go-binance/v2"
-	"strconv"
 )
 
 type SpotClient struct {
-	binanceSpotClient *binance.Client
+	exchange Exchange
+	registry *SymbolRegistry
+	limiter  *RateLimiter
+	retry    OrderRetryConfig
 }
 
+// NewSpotClient every request goes through a RateLimiter with DefaultRateLimits (see ratelimit.go),
+// spotClient itself is not modified
 func NewSpotClient(spotClient *binance.Client) *SpotClient {
-	return &SpotClient{binanceSpotClient: spotClient}
+	limiter, err := NewRateLimiter(nil)
+	if err != nil {
+		// DefaultRateLimits are valid
+		panic(err)
+	}
+	return NewSpotClientWithLimiter(spotClient, limiter)
+}
+
+// NewSpotClientWithExchange use any Exchange implementation, e.g. FakeExchange in tests
+func NewSpotClientWithExchange(exchange Exchange) *SpotClient {
+	exchange = classifyExchange(exchange)
+	return &SpotClient{exchange: exchange, registry: NewSymbolRegistry(exchange, DefaultSymbolTTL), retry: DefaultOrderRetry}
+}
+
+// Registry cached exchangeInfo shared by every call of this client
+func (c *SpotClient) Registry() *SymbolRegistry {
+	return c.registry
 }
 
+// EstQuoteReq with Side and Quantity set EstQuote also walks the order book (see QuoteEstimate)
 type EstQuoteReq struct {
-	Symbol string `json:"symbol"`
+	Symbol       string           `json:"symbol"`
+	Side         binance.SideType `json:"side"`         // BUY SELL, empty returns the book ticker only
+	Quantity     string           `json:"quantity"`     // amount in QuantityKind
+	QuantityKind QuantityKind     `json:"quantityKind"` // default QUOTE for BUY, BASE for SELL like Trade
+	Rounding     RoundingMode     `json:"rounding"`     // quantity rounding, default DOWN
+	FeeRate      string           `json:"feeRate"`      // taker fee rate, default the account's TradeFee
+	DepthLimit   int              `json:"depthLimit"`   // depth snapshot levels, default 100
+	Book         *OrderBook       `json:"-"`            // walk a synced local book instead of a depth snapshot
 }
 
 type EstQuoteResp struct {
 	MinNotional string                `json:"minNotional"`
 	Data        []*binance.BookTicker `json:"data"`
+	Estimate    *QuoteEstimate        `json:"estimate,omitempty"`
 }
 
 func (c *SpotClient) EstQuote(ctx context.Context, req *EstQuoteReq) (*EstQuoteResp, error) {
 
-	list, err := c.binanceSpotClient.NewListBookTickersService().Symbol(req.Symbol).Do(ctx)
+	list, err := c.exchange.ListBookTickers(ctx, req.Symbol)
 
 	if err != nil {
 		return nil, err
@@ -39,26 +68,29 @@ func (c *SpotClient) EstQuote(ctx context.Context, req *EstQuoteReq) (*EstQuoteR
 		resp = append(resp, li)
 	}
 
-	exchangeInfo, err := c.binanceSpotClient.NewExchangeInfoService().Symbol(req.Symbol).Do(ctx)
+	symbol, err := c.registry.Symbol(ctx, req.Symbol)
 	if err != nil {
 		return nil, err
 	}
 
 	var minNotional string
-
-	if len(exchangeInfo.Symbols) == 0 || exchangeInfo.Symbols == nil {
-		return nil, errors.New("exchangeInfo.Symbols fail")
+	if f := symbol.Filters.Notional; f != nil {
+		minNotional = f.MinNotional.StringFixed(symbol.QuoteAssetPrecision)
+	} else if f := symbol.Filters.MinNotional; f != nil {
+		minNotional = f.MinNotional.StringFixed(symbol.QuoteAssetPrecision)
 	}
 
-	for _, filter := range exchangeInfo.Symbols[0].Filters {
-		if filter["filterType"] == "MIN_NOTIONAL" {
-			minNotional = filter["minNotional"].(string)
+	var estimate *QuoteEstimate
+	if req.Side != "" {
+		if estimate, err = c.estimate(ctx, symbol, req); err != nil {
+			return nil, err
 		}
 	}
 
 	return &EstQuoteResp{
 		Data:        resp,
 		MinNotional: minNotional,
+		Estimate:    estimate,
 	}, nil
 }
 
@@ -83,12 +115,26 @@ BUY 买入
 SELL 卖出
 */
 
+// QuantityKind asset TradeReq.Quantity is expressed in
+type QuantityKind string
+
+const (
+	QuantityKindBase  QuantityKind = "BASE"  // exact amount of the base asset bought or sold
+	QuantityKindQuote QuantityKind = "QUOTE" // amount of the quote asset spent or received
+)
+
 type TradeReq struct {
 	Symbol           string                   `json:"symbol"`
-	Side             binance.SideType         `json:"side"` // BUY SELL
-	Quantity         string                   `json:"quantity"`
+	Side             binance.SideType         `json:"side"`         // BUY SELL
+	Type             binance.OrderType        `json:"type"`         // MARKET (default) LIMIT LIMIT_MAKER
+	TimeInForce      binance.TimeInForceType  `json:"timeInForce"`  // LIMIT only: GTC (default) IOC FOK
+	Quantity         string                   `json:"quantity"`     // amount in QuantityKind
+	QuantityKind     QuantityKind             `json:"quantityKind"` // default QUOTE for MARKET BUY, BASE otherwise
+	Price            string                   `json:"price"`        // LIMIT / LIMIT_MAKER
 	NewClientOrderId string                   `json:"newClientOrderId"`
 	NewOrderRespType binance.NewOrderRespType `json:"newOrderRespType"`
+	Rounding         RoundingMode             `json:"rounding"`      // quantity rounding, default DOWN
+	PriceRounding    RoundingMode             `json:"priceRounding"` // price rounding, default DOWN
 }
 
 type TradeResp struct {
@@ -108,8 +154,12 @@ type TradeResp struct {
 	Fills                    []*binance.Fill         `json:"fills"`
 	MarginBuyBorrowAmount    string                  `json:"marginBuyBorrowAmount"`
 	MarginBuyBorrowAsset     string                  `json:"marginBuyBorrowAsset"`
+	Dust                     string                  `json:"dust"`      // part of req.Quantity dropped by rounding
+	Attempts                 int                     `json:"attempts"`  // placements sent
+	Recovered                bool                    `json:"recovered"` // found by clientOrderId after an ambiguous failure, Fills are empty
 }
 
+// Trade normalize, validate and place an order, at most once per NewClientOrderId (generated when empty)
 func (c *SpotClient) Trade(ctx context.Context, req *TradeReq) (*TradeResp, error) {
 
 	// check symbol quantity filters
@@ -126,69 +176,142 @@ func (c *SpotClient) Trade(ctx context.Context, req *TradeReq) (*TradeResp, erro
 		对于市价单(MARKET), 用于计算的价格采用的是在 avgPriceMins 定义的时间之内的平均价.
 		如果 avgPriceMins 为 0, 则采用最新的价格.
 	*/
-	exchangeInfo, err := c.binanceSpotClient.NewExchangeInfoService().Symbol(req.Symbol).Do(ctx)
+	symbol, err := c.registry.Symbol(ctx, req.Symbol)
 	if err != nil {
 		return nil, err
 	}
+	if err = symbol.checkTrading(); err != nil {
+		return nil, err
+	}
 
-	var minNotional string
-
-	if len(exchangeInfo.Symbols) == 0 || exchangeInfo.Symbols == nil {
-		return nil, errors.New("exchangeInfo.Symbols fail")
+	normalizer, err := symbol.Normalizer()
+	if err != nil {
+		return nil, err
+	}
+	if req.Rounding != "" {
+		normalizer.QuantityMode = req.Rounding
 	}
 
-	for _, filter := range exchangeInfo.Symbols[0].Filters {
-		if filter["filterType"] == "MIN_NOTIONAL" {
-			minNotional = filter["minNotional"].(string)
-		}
+	if req.PriceRounding != "" {
+		normalizer.PriceMode = req.PriceRounding
 	}
 
-	baseAsset := exchangeInfo.Symbols[0].BaseAsset   // LUNC
-	quoteAsset := exchangeInfo.Symbols[0].QuoteAsset // BUSD
-	var quoteQuantity string
+	dQuantity, err := ParseDecimal(req.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("quantity %w", err)
+	}
 
-	if req.Side == "BUY" {
-		fMinNotional, _ := strconv.ParseFloat(minNotional, 64)
-		fQuantity, _ := strconv.ParseFloat(req.Quantity, 64)
-		if fQuantity < fMinNotional {
-			return nil, errors.New(fmt.Sprintf("quantity less than min notional : %s %s < %s %s", req.Quantity, quoteAsset, minNotional, quoteAsset))
+	orderType := req.Type
+	if orderType == "" {
+		orderType = binance.OrderTypeMarket
+	}
+	params := &CreateOrderParams{
+		Symbol:           req.Symbol,
+		Side:             req.Side,
+		Type:             orderType,
+		NewClientOrderId: req.NewClientOrderId,
+		NewOrderRespType: req.NewOrderRespType,
+	}
+	check := &OrderCheck{Side: req.Side, Type: orderType}
+	var dust Decimal
+
+	quantityKind := req.QuantityKind
+	if quantityKind == "" {
+		quantityKind = QuantityKindBase
+		if orderType == binance.OrderTypeMarket && req.Side == binance.SideTypeBuy {
+			quantityKind = QuantityKindQuote
 		}
-		quoteQuantity = req.Quantity
+	}
+	if quantityKind != QuantityKindBase && quantityKind != QuantityKindQuote {
+		return nil, errors.New(fmt.Sprintf("unknown quantityKind %s", quantityKind))
+	}
 
-	} else {
-		res, err := c.binanceSpotClient.NewAveragePriceService().Symbol(req.Symbol).Do(ctx)
+	switch orderType {
+	case binance.OrderTypeMarket:
+		if quantityKind == QuantityKindQuote {
+			quote := normalizer.QuoteQuantity(dQuantity)
+			check.QuoteOrderQty, dust = quote.Value, quote.Dust
+			params.QuoteOrderQty = check.QuoteOrderQty.String()
+		} else {
+			// notional filters price a base quantity market order at the average price
+			if check.AvgPrice, err = c.avgPrice(ctx, req.Symbol); err != nil {
+				return nil, err
+			}
+			base := normalizer.Quantity(dQuantity, binance.OrderTypeMarket)
+			check.Quantity, dust = base.Value, base.Dust
+			params.Quantity = check.Quantity.String()
+		}
+	case binance.OrderTypeLimit, binance.OrderTypeLimitMaker:
+		dPrice, err := ParseDecimal(req.Price)
 		if err != nil {
+			return nil, fmt.Errorf("price %w", err)
+		}
+		check.Price = normalizer.Price(dPrice).Value
+		if quantityKind == QuantityKindQuote {
+			if check.Price.Sign() <= 0 {
+				return nil, errors.New(fmt.Sprintf("price %s must be positive", req.Price))
+			}
+			dQuantity = dQuantity.Div(check.Price, normalizer.BasePrecision)
+		}
+		base := normalizer.Quantity(dQuantity, orderType)
+		check.Quantity, dust = base.Value, base.Dust
+		// PERCENT_PRICE and PERCENT_PRICE_BY_SIDE compare against the average price
+		if check.AvgPrice, err = c.avgPrice(ctx, req.Symbol); err != nil {
 			return nil, err
 		}
-		fMinNotional, _ := strconv.ParseFloat(minNotional, 64)
-		fQuantity, _ := strconv.ParseFloat(req.Quantity, 64)
-		fPrice, _ := strconv.ParseFloat(res.Price, 64)
-		notional := fQuantity * fPrice
-		if notional < fMinNotional {
-			return nil, errors.New(fmt.Sprintf("quantity * avgPrice less than min notional : %f * %f %s/%s < %f %s", fQuantity, fPrice, baseAsset, quoteAsset, fMinNotional, quoteAsset))
+		params.Quantity = check.Quantity.String()
+		params.Price = check.Price.String()
+		if orderType == binance.OrderTypeLimit {
+			params.TimeInForce = req.TimeInForce
+			if params.TimeInForce == "" {
+				params.TimeInForce = binance.TimeInForceTypeGTC
+			}
 		}
-
-		quoteQuantity = strconv.FormatFloat(notional, 'f', 8, 64)
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported order type %s", orderType))
 	}
 
-	order, err := c.binanceSpotClient.NewCreateOrderService().Symbol(req.Symbol).
-		Side(req.Side).Type(binance.OrderTypeMarket).NewClientOrderID(req.NewClientOrderId).
-		QuoteOrderQty(quoteQuantity).NewOrderRespType(req.NewOrderRespType).Do(ctx)
+	// MAX_NUM_ORDERS and MAX_POSITION count the orders already open and the base asset held
+	if err = c.accountCheck(ctx, symbol, check); err != nil {
+		return nil, err
+	}
+	// MIN_NOTIONAL, NOTIONAL and the other symbol filters, see filters.go
+	if violations := normalizer.Filters.Validate(check); len(violations) > 0 {
+		return nil, violations
+	}
 
+	placement, err := c.placeOrder(ctx, params)
 	if err != nil {
 		return nil, err
 	}
 
 	var resp TradeResp
 
-	copier.Copy(&resp, order)
+	copier.Copy(&resp, placement.order)
+	resp.Dust = dust.String()
+	resp.Attempts, resp.Recovered = placement.attempts, placement.recovered
 
 	return &resp, nil
 }
 
+// avgPrice current average price of symbol
+func (c *SpotClient) avgPrice(ctx context.Context, symbol string) (Decimal, error) {
+	res, err := c.exchange.AveragePrice(ctx, symbol)
+	if err != nil {
+		return Zero, err
+	}
+	dPrice, err := ParseDecimal(res.Price)
+	if err != nil {
+		return Zero, fmt.Errorf("avgPrice %w", err)
+	}
+	return dPrice, nil
+}
+
+// GetOrderReq identify the order by OrderId or by the clientOrderId it was placed with
 type GetOrderReq struct {
-	Symbol  string `json:"symbol"`
-	OrderId int64  `json:"orderId"`
+	Symbol            string `json:"symbol"`
+	OrderId           int64  `json:"orderId"`
+	OrigClientOrderId string `json:"origClientOrderId"`
 }
 
 type GetOrderResp struct {
@@ -214,37 +337,39 @@ type GetOrderResp struct {
 }
 
 func (c *SpotClient) GetOrder(ctx context.Context, req *GetOrderReq) (*GetOrderResp, error) {
+	query, err := queryOrderParams(req.Symbol, req.OrderId, req.OrigClientOrderId)
+	if err != nil {
+		return nil, err
+	}
+	order, err := c.exchange.GetOrder(ctx, query)
+	if err != nil {
+		return nil, err
+	}
 
-	if req.OrderId > 0 {
-		order, err := c.binanceSpotClient.NewGetOrderService().Symbol(req.Symbol).OrderID(req.OrderId).Do(ctx)
-		if err != nil {
-			return nil, err
-		}
-
-		var resp GetOrderResp
-
-		copier.Copy(&resp, order)
-
-		return &resp, err
-	} else {
-		order, err := c.binanceSpotClient.NewGetOrderService().Symbol(req.Symbol).Do(ctx)
-		if err != nil {
-			return nil, err
-		}
+	var resp GetOrderResp
 
-		var resp GetOrderResp
+	copier.Copy(&resp, order)
 
-		copier.Copy(&resp, order)
+	return &resp, nil
+}
 
-		return &resp, err
+func queryOrderParams(symbol string, orderId int64, origClientOrderId string) (*QueryOrderParams, error) {
+	if symbol == "" {
+		return nil, errors.New("symbol is required")
 	}
-
+	if orderId <= 0 && origClientOrderId == "" {
+		return nil, errors.New("orderId or origClientOrderId is required")
+	}
+	return &QueryOrderParams{Symbol: symbol, OrderId: orderId, OrigClientOrderId: origClientOrderId}, nil
 }
 
+// OrderListReq a single allOrders page, at most 24 hours between StartTime and EndTime. See OrderHistory for longer ranges
 type OrderListReq struct {
-	Symbol  string `json:"symbol"`
-	OrderId int64  `json:"orderId"`
-	Limit   int    `json:"limit"`
+	Symbol    string `json:"symbol"`
+	OrderId   int64  `json:"orderId"`
+	StartTime int64  `json:"startTime"`
+	EndTime   int64  `json:"endTime"`
+	Limit     int    `json:"limit"`
 }
 
 type OrderListResp struct {
@@ -253,7 +378,13 @@ type OrderListResp struct {
 
 func (c *SpotClient) OrderList(ctx context.Context, req *OrderListReq) (*OrderListResp, error) {
 
-	list, err := c.binanceSpotClient.NewListOrdersService().Symbol(req.Symbol).OrderID(req.OrderId).Limit(req.Limit).Do(ctx)
+	list, err := c.exchange.ListOrders(ctx, &ListOrdersParams{
+		Symbol:    req.Symbol,
+		OrderId:   req.OrderId,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Limit:     req.Limit,
+	})
 
 	if err != nil {
 		return nil, err
@@ -269,7 +400,7 @@ func (c *SpotClient) OrderList(ctx context.Context, req *OrderListReq) (*OrderLi
 
 func (c *SpotClient) HangOrderList(ctx context.Context) (*OrderListResp, error) {
 
-	list, err := c.binanceSpotClient.NewListOpenOrdersService().Do(ctx)
+	list, err := c.exchange.ListOpenOrders(ctx, "")
 
 	if err != nil {
 		return nil, err
@@ -283,9 +414,11 @@ func (c *SpotClient) HangOrderList(ctx context.Context) (*OrderListResp, error)
 	return &OrderListResp{Data: resp}, nil
 }
 
+// CancelReq identify the order by OrderId or by the clientOrderId it was placed with
 type CancelReq struct {
-	Symbol  string `json:"symbol"`
-	OrderId int64  `json:"orderId"`
+	Symbol            string `json:"symbol"`
+	OrderId           int64  `json:"orderId"`
+	OrigClientOrderId string `json:"origClientOrderId"`
 }
 
 type CancelResp struct {
@@ -305,14 +438,89 @@ type CancelResp struct {
 }
 
 func (c *SpotClient) CancelOrder(ctx context.Context, req *CancelReq) (*CancelResp, error) {
-	order, err := c.binanceSpotClient.NewCancelOrderService().Symbol(req.Symbol).OrderID(req.OrderId).Do(ctx)
+	query, err := queryOrderParams(req.Symbol, req.OrderId, req.OrigClientOrderId)
 	if err != nil {
 		return nil, err
 	}
-	var resp CancelResp
-	copier.Copy(&resp, order)
+	order, err := c.exchange.CancelOrder(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &CancelResp{
+		Symbol:              order.Symbol,
+		OrigClientOrderId:   order.OrigClientOrderID,
+		OrderId:             int(order.OrderID),
+		OrderListId:         int(order.OrderListID),
+		ClientOrderId:       order.ClientOrderID,
+		Price:               order.Price,
+		OrigQty:             order.OrigQuantity,
+		ExecutedQty:         order.ExecutedQuantity,
+		CummulativeQuoteQty: order.CummulativeQuoteQuantity,
+		Status:              string(order.Status),
+		TimeInForce:         string(order.TimeInForce),
+		Type:                string(order.Type),
+		Side:                string(order.Side),
+	}, nil
+}
 
-	return &resp, nil
+type CancelByClientIdReq struct {
+	Symbol        string `json:"symbol"`
+	ClientOrderId string `json:"clientOrderId"`
+}
+
+// CancelByClientIdResp Canceled is false when the order was already filled, canceled or expired,
+// CancelResp is then its final state. Remaining is the quantity left unfilled
+type CancelByClientIdResp struct {
+	CancelResp
+	Canceled  bool   `json:"canceled"`
+	Remaining string `json:"remaining"`
+}
+
+// CancelByClientId cancel the open, possibly partially filled, order placed with ClientOrderId.
+// An order closed before the cancel arrived is looked up instead of failing with ErrUnknownOrder
+func (c *SpotClient) CancelByClientId(ctx context.Context, req *CancelByClientIdReq) (*CancelByClientIdResp, error) {
+	if req.ClientOrderId == "" {
+		return nil, errors.New("clientOrderId is required")
+	}
+	cancel, err := c.CancelOrder(ctx, &CancelReq{Symbol: req.Symbol, OrigClientOrderId: req.ClientOrderId})
+	if err == nil {
+		return cancelByClientIdResp(cancel, true)
+	}
+	if !errors.Is(err, ErrUnknownOrder) {
+		return nil, err
+	}
+
+	order, queryErr := c.GetOrder(ctx, &GetOrderReq{Symbol: req.Symbol, OrigClientOrderId: req.ClientOrderId})
+	if queryErr != nil || order.Status == binance.OrderStatusTypeNew || order.Status == binance.OrderStatusTypePartiallyFilled {
+		return nil, err
+	}
+	return cancelByClientIdResp(&CancelResp{
+		Symbol:              order.Symbol,
+		OrigClientOrderId:   order.ClientOrderID,
+		OrderId:             int(order.OrderID),
+		OrderListId:         int(order.OrderListId),
+		ClientOrderId:       order.ClientOrderID,
+		Price:               order.Price,
+		OrigQty:             order.OrigQuantity,
+		ExecutedQty:         order.ExecutedQuantity,
+		CummulativeQuoteQty: order.CummulativeQuoteQuantity,
+		Status:              string(order.Status),
+		TimeInForce:         string(order.TimeInForce),
+		Type:                string(order.Type),
+		Side:                string(order.Side),
+	}, false)
+}
+
+func cancelByClientIdResp(order *CancelResp, canceled bool) (*CancelByClientIdResp, error) {
+	origQty, err := ParseDecimal(order.OrigQty)
+	if err != nil {
+		return nil, fmt.Errorf("origQty %w", err)
+	}
+	executedQty, err := ParseDecimal(order.ExecutedQty)
+	if err != nil {
+		return nil, fmt.Errorf("executedQty %w", err)
+	}
+	return &CancelByClientIdResp{CancelResp: *order, Canceled: canceled, Remaining: origQty.Sub(executedQty).String()}, nil
 }
 
 type WithdrawReq struct {
@@ -328,9 +536,13 @@ type WithdrawResp struct {
 }
 
 func (c *SpotClient) Withdraw(ctx context.Context, req *WithdrawReq) (*WithdrawResp, error) {
-	withdrawId, err := c.binanceSpotClient.NewCreateWithdrawService().
-		Coin(req.Coin).Address(req.Address).AddressTag(req.AddressTag).
-		Amount(req.Amount).WithdrawOrderID(req.WithdrawOrderId).Do(ctx)
+	withdrawId, err := c.exchange.CreateWithdraw(ctx, &WithdrawParams{
+		Coin:            req.Coin,
+		Address:         req.Address,
+		AddressTag:      req.AddressTag,
+		Amount:          req.Amount,
+		WithdrawOrderId: req.WithdrawOrderId,
+	})
 
 	if err != nil {
 		return nil, err
@@ -349,7 +561,7 @@ type TradeFeeResp struct {
 }
 
 func (c *SpotClient) TradeFee(ctx context.Context, req *TradeFeeReq) (*TradeFeeResp, error) {
-	feeDetails, err := c.binanceSpotClient.NewTradeFeeService().Symbol(req.Symbol).Do(ctx)
+	feeDetails, err := c.exchange.TradeFee(ctx, req.Symbol)
 	if err != nil {
 		return nil, err
 	}
@@ -367,8 +579,7 @@ type WithdrawHistoryResp struct {
 }
 
 func (c *SpotClient) WithdrawHistory(ctx context.Context, req *WithdrawHistoryReq) (*WithdrawHistoryResp, error) {
-	withdrawList, err := c.binanceSpotClient.NewListWithdrawsService().Coin(req.Coin).
-		WithdrawOrderId(req.WithdrawOrderId).Do(ctx)
+	withdrawList, err := c.exchange.ListWithdraws(ctx, req.Coin, req.WithdrawOrderId)
 	if err != nil {
 		return nil, err
 	}
@@ -381,30 +592,31 @@ func (c *SpotClient) WithdrawHistory(ctx context.Context, req *WithdrawHistoryRe
 	return &WithdrawHistoryResp{Data: resp}, nil
 }
 
-type Kline struct {
-	OpenTime                 int64  `json:"openTime"`
-	Open                     string `json:"open"`
-	High                     string `json:"high"`
-	Low                      string `json:"low"`
-	Close                    string `json:"close"`
-	Volume                   string `json:"volume"`
-	CloseTime                int64  `json:"closeTime"`
-	QuoteAssetVolume         string `json:"quoteAssetVolume"`
-	TradeNum                 int64  `json:"tradeNum"`
-	TakerBuyBaseAssetVolume  string `json:"takerBuyBaseAssetVolume"`
-	TakerBuyQuoteAssetVolume string `json:"takerBuyQuoteAssetVolume"`
+// KlinesReq one klines request, see BackfillKlines for longer ranges
+type KlinesReq struct {
+	Symbol    string `json:"symbol"`
+	Interval  string `json:"interval"`  // 1s (default) 1m 3m 5m 15m 30m 1h 2h 4h 6h 8h 12h 1d 3d 1w 1M
+	StartTime int64  `json:"startTime"` // open time, ms
+	EndTime   int64  `json:"endTime"`   // open time, ms
+	Limit     int    `json:"limit"`     // default 500, at most 1000
 }
 
-type KlinesOneSecReq struct {
-	Symbol string `json:"symbol"`
+type KlinesResp struct {
+	Data   []*binance.Kline `json:"data"`
+	Klines []*Kline         `json:"klines"`
 }
 
-type KlinesOneSecResp struct {
-	Data []*binance.Kline `json:"data"`
-}
+// KlinesOneSecReq KlinesOneSecResp names of the 1s only Klines
+type KlinesOneSecReq = KlinesReq
+type KlinesOneSecResp = KlinesResp
 
-func (c *SpotClient) Klines(ctx context.Context, req *KlinesOneSecReq) (*KlinesOneSecResp, error) {
-	klines, err := c.binanceSpotClient.NewKlinesService().Symbol(req.Symbol).Interval("1s").Do(ctx)
+// Klines the latest Limit candles, or the first Limit from StartTime
+func (c *SpotClient) Klines(ctx context.Context, req *KlinesReq) (*KlinesResp, error) {
+	params, err := klinesParams(req)
+	if err != nil {
+		return nil, err
+	}
+	klines, err := c.exchange.Klines(ctx, params)
 	if err != nil {
 		return nil, err
 	}
@@ -413,8 +625,12 @@ func (c *SpotClient) Klines(ctx context.Context, req *KlinesOneSecReq) (*KlinesO
 	for _, data := range klines {
 		resp = append(resp, data)
 	}
+	typed, err := NewKlines(klines)
+	if err != nil {
+		return nil, err
+	}
 
-	return &KlinesOneSecResp{Data: resp}, nil
+	return &KlinesResp{Data: resp, Klines: typed}, nil
 }
 
 type NewPriceReq struct {
@@ -428,7 +644,7 @@ type NewPriceResp struct {
 
 func (c *SpotClient) GetTickerPrice(ctx context.Context, req *NewPriceReq) (*NewPriceResp, error) {
 	if req.Symbols != nil && len(req.Symbols) > 0 {
-		priceInfo, err := c.binanceSpotClient.NewListPricesService().Symbols(req.Symbols).Do(ctx)
+		priceInfo, err := c.exchange.ListPrices(ctx, req.Symbols...)
 		if err != nil {
 			return nil, err
 		}
@@ -440,7 +656,7 @@ func (c *SpotClient) GetTickerPrice(ctx context.Context, req *NewPriceReq) (*New
 
 		return &NewPriceResp{Data: resp}, nil
 	} else if req.Symbol != "" {
-		priceInfo, err := c.binanceSpotClient.NewListPricesService().Symbol(req.Symbol).Do(ctx)
+		priceInfo, err := c.exchange.ListPrices(ctx, req.Symbol)
 		if err != nil {
 			return nil, err
 		}
@@ -452,7 +668,7 @@ func (c *SpotClient) GetTickerPrice(ctx context.Context, req *NewPriceReq) (*New
 
 		return &NewPriceResp{Data: resp}, nil
 	}
-	priceInfo, err := c.binanceSpotClient.NewListPricesService().Do(ctx)
+	priceInfo, err := c.exchange.ListPrices(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -474,7 +690,7 @@ type UserAssetResp struct {
 }
 
 func (c *SpotClient) GetUserAsset(ctx context.Context, req *UserAssetReq) (*UserAssetResp, error) {
-	assets, err := c.binanceSpotClient.NewGetUserAssetService().Asset(req.Asset).Do(ctx)
+	assets, err := c.exchange.UserAsset(ctx, req.Asset)
 	if err != nil {
 		return nil, err
 	}
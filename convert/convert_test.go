@@ -15,16 +15,25 @@ import (
 var sCli *SpotClient
 
 func TestMain(m *testing.M) {
+	// live tests need ../.env, the offline ones run against FakeExchange
 	if err := godotenv.Load("../.env"); err != nil {
-		log.Fatal("Error loading .env file")
+		log.Print("Error loading .env file, skipping live tests")
+	} else {
+		//binance.UseTestnet = true
+		binanceClient := binance.NewProxiedClient(os.Getenv("BINANCE_API_KEY"), os.Getenv("BINANCE_SECRET_KEY"), os.Getenv("PROXY_URL"))
+		sCli = NewSpotClient(binanceClient)
 	}
-	//binance.UseTestnet = true
-	binanceClient := binance.NewProxiedClient(os.Getenv("BINANCE_API_KEY"), os.Getenv("BINANCE_SECRET_KEY"), os.Getenv("PROXY_URL"))
-	sCli = NewSpotClient(binanceClient)
 	os.Exit(m.Run())
 }
 
+func skipIfOffline(t *testing.T) {
+	if sCli == nil {
+		t.Skip("no ../.env, live test skipped")
+	}
+}
+
 func TestEstQuote(t *testing.T) {
+	skipIfOffline(t)
 
 	convey.Convey("TestEstQuote", t, func(convCtx convey.C) {
 		resp, err := sCli.EstQuote(context.Background(), &EstQuoteReq{
@@ -43,12 +52,11 @@ func TestEstQuote(t *testing.T) {
 }
 
 func TestOrderList(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestOrderList", t, func(convCtx convey.C) {
 		resp, err := sCli.OrderList(context.Background(), &OrderListReq{
-			Symbol:    "EOSBTC",
-			StartTime: 0,
-			EndTime:   0,
-			Limit:     500,
+			Symbol: "EOSBTC",
+			Limit:  500,
 		})
 
 		if err != nil {
@@ -62,6 +70,7 @@ func TestOrderList(t *testing.T) {
 }
 
 func TestGetOrder(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestGetOrder", t, func(convCtx convey.C) {
 		resp, err := sCli.GetOrder(context.Background(), &GetOrderReq{
 			Symbol:  "EOSBTC",
@@ -79,6 +88,7 @@ func TestGetOrder(t *testing.T) {
 }
 
 func TestTrade(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestTrade", t, func(convCtx convey.C) {
 		resp, err := sCli.Trade(context.Background(), &TradeReq{
 			Symbol:           "LUNCBUSD",
@@ -116,6 +126,7 @@ func TestTrade(t *testing.T) {
 }
 
 func TestTradedFee(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestTradedFee", t, func(convCtx convey.C) {
 		resp, err := sCli.TradeFee(context.Background(), &TradeFeeReq{
 			Symbol: "EOSBTC",
@@ -132,6 +143,7 @@ func TestTradedFee(t *testing.T) {
 }
 
 func TestWithdraw(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestWithdraw", t, func(convCtx convey.C) {
 		resp, err := sCli.Withdraw(context.Background(), &WithdrawReq{
 			Coin:            "EOS",
@@ -152,6 +164,7 @@ func TestWithdraw(t *testing.T) {
 }
 
 func TestWithdrawList(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestWithdrawList", t, func(convCtx convey.C) {
 		resp, err := sCli.WithdrawHistory(context.Background(), &WithdrawHistoryReq{
 			Coin:            "EOS",
@@ -169,6 +182,7 @@ func TestWithdrawList(t *testing.T) {
 }
 
 func TestKlines(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestKlines", t, func(convCtx convey.C) {
 		resp, err := sCli.Klines(context.Background(), &KlinesOneSecReq{
 			Symbol: "EOSUSDT",
@@ -185,6 +199,7 @@ func TestKlines(t *testing.T) {
 }
 
 func TestTickerPrice(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestTickerPrice", t, func(convCtx convey.C) {
 		resp, err := sCli.GetTickerPrice(context.Background(), &NewPriceReq{
 			Symbol: "FILUSDT",
@@ -226,6 +241,7 @@ func TestTickerPrice(t *testing.T) {
 }
 
 func TestGetUserAssets(t *testing.T) {
+	skipIfOffline(t)
 	convey.Convey("TestGetUserAssets", t, func(convCtx convey.C) {
 		resp, err := sCli.GetUserAsset(context.Background(), &UserAssetReq{
 			Asset: "EOS",
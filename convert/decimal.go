@@ -0,0 +1,292 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+var decimalPattern = regexp.MustCompile(`^([+-]?)(\d*)(?:\.(\d*))?(?:[eE]([+-]?\d+))?$`)
+
+var bigTen = big.NewInt(10)
+
+// maxDecimalExp bound of a parsed exponent, far beyond any price or quantity, keeps rescaling cheap
+// and the exponents of products well inside int32
+const maxDecimalExp = 64
+
+// Decimal exact base-10 number used for prices, quantities and notionals.
+// value = coef * 10^exp, the zero value is 0.
+type Decimal struct {
+	coef *big.Int
+	exp  int32
+}
+
+var Zero = Decimal{}
+
+// ParseDecimal strict parse of binance number strings like "0.00010000", "-12", "1e-8"
+func ParseDecimal(s string) (Decimal, error) {
+	m := decimalPattern.FindStringSubmatch(s)
+	if m == nil || m[2]+m[3] == "" {
+		return Zero, fmt.Errorf("%w : %q", ErrInvalidDecimal, s)
+	}
+	coef, ok := new(big.Int).SetString(m[2]+m[3], 10)
+	if !ok {
+		return Zero, fmt.Errorf("%w : %q", ErrInvalidDecimal, s)
+	}
+	exp := -int64(len(m[3]))
+	if m[4] != "" {
+		e, err := strconv.ParseInt(m[4], 10, 32)
+		if err != nil {
+			return Zero, fmt.Errorf("%w : %q exponent out of range", ErrInvalidDecimal, s)
+		}
+		exp += e
+	}
+	if exp < -maxDecimalExp || exp > maxDecimalExp {
+		return Zero, fmt.Errorf("%w : %q exponent out of range", ErrInvalidDecimal, s)
+	}
+	if m[1] == "-" {
+		coef.Neg(coef)
+	}
+	return Decimal{coef: coef, exp: int32(exp)}, nil
+}
+
+// MustDecimal ParseDecimal that panics, for constants and tests
+func MustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func NewDecimalFromInt(i int64) Decimal {
+	return Decimal{coef: big.NewInt(i)}
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) c() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// rescale coef expressed with exponent exp, exp must be <= d.exp
+func (d Decimal) rescale(exp int32) *big.Int {
+	if exp == d.exp {
+		return new(big.Int).Set(d.c())
+	}
+	return new(big.Int).Mul(d.c(), pow10(d.exp-exp))
+}
+
+func minExp(a, b Decimal) int32 {
+	if a.exp < b.exp {
+		return a.exp
+	}
+	return b.exp
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	exp := minExp(d, o)
+	return Decimal{coef: new(big.Int).Add(d.rescale(exp), o.rescale(exp)), exp: exp}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	exp := minExp(d, o)
+	return Decimal{coef: new(big.Int).Sub(d.rescale(exp), o.rescale(exp)), exp: exp}
+}
+
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.c(), o.c()), exp: d.exp + o.exp}
+}
+
+// Div d / o truncated toward zero to places decimals, panics when o is zero
+func (d Decimal) Div(o Decimal, places int32) Decimal {
+	if o.IsZero() {
+		panic("convert: decimal division by zero")
+	}
+	num, den := new(big.Int).Set(d.c()), new(big.Int).Set(o.c())
+	if k := d.exp - o.exp + places; k >= 0 {
+		num.Mul(num, pow10(k))
+	} else {
+		den.Mul(den, pow10(-k))
+	}
+	return Decimal{coef: num.Quo(num, den), exp: -places}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.c()), exp: d.exp}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.c()), exp: d.exp}
+}
+
+// Truncate drop digits after places decimals (toward zero)
+func (d Decimal) Truncate(places int32) Decimal {
+	if -d.exp <= places {
+		return d
+	}
+	return Decimal{coef: new(big.Int).Quo(d.c(), pow10(-d.exp-places)), exp: -places}
+}
+
+func (d Decimal) Cmp(o Decimal) int {
+	exp := minExp(d, o)
+	return d.rescale(exp).Cmp(o.rescale(exp))
+}
+
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+func (d Decimal) LessThan(o Decimal) bool {
+	return d.Cmp(o) < 0
+}
+
+func (d Decimal) GreaterThan(o Decimal) bool {
+	return d.Cmp(o) > 0
+}
+
+func (d Decimal) Sign() int {
+	return d.c().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+func MinDecimal(a, b Decimal) Decimal {
+	if a.LessThan(b) {
+		return a
+	}
+	return b
+}
+
+func MaxDecimal(a, b Decimal) Decimal {
+	if a.GreaterThan(b) {
+		return a
+	}
+	return b
+}
+
+// Places number of digits after the decimal point, trailing zeros count: "1.10" is 2
+func (d Decimal) Places() int32 {
+	if d.exp >= 0 {
+		return 0
+	}
+	return -d.exp
+}
+
+// Float64 nearest float, only for display and statistics
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String shortest plain representation without exponent and trailing zeros
+func (d Decimal) String() string {
+	s := d.format()
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// StringFixed exactly places decimals, truncated, like binance formats "0.00010000"
+func (d Decimal) StringFixed(places int32) string {
+	t := d.Truncate(places)
+	if -t.exp < places {
+		t = Decimal{coef: t.rescale(-places), exp: -places}
+	}
+	return t.format()
+}
+
+func (d Decimal) format() string {
+	digits := new(big.Int).Abs(d.c()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.exp >= 0 {
+		if d.Sign() == 0 {
+			return "0"
+		}
+		return sign + digits + strings.Repeat("0", int(d.exp))
+	}
+	places := int(-d.exp)
+	if len(digits) <= places {
+		digits = strings.Repeat("0", places-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-places] + "." + digits[len(digits)-places:]
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	s := strings.Trim(string(data), `"`)
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// RoundingMode how a value is brought onto a step grid, the zero value rounds down
+type RoundingMode string
+
+const (
+	RoundDown     RoundingMode = "DOWN"      // toward zero, never spends more than asked
+	RoundUp       RoundingMode = "UP"        // away from zero
+	RoundHalfUp   RoundingMode = "HALF_UP"   // nearest, ties away from zero
+	RoundHalfEven RoundingMode = "HALF_EVEN" // nearest, ties to the even step
+)
+
+// RoundStep round d to a multiple of step, a zero step returns d unchanged
+func (d Decimal) RoundStep(step Decimal, mode RoundingMode) Decimal {
+	if step.IsZero() {
+		return d
+	}
+	step = step.Abs()
+	exp := minExp(d, step)
+	num, den := d.rescale(exp), step.rescale(exp)
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() != 0 {
+		away := false
+		switch mode {
+		case RoundUp:
+			away = true
+		case RoundHalfUp, RoundHalfEven:
+			twice := new(big.Int).Abs(r)
+			twice.Lsh(twice, 1)
+			c := twice.Cmp(den)
+			away = c > 0 || (c == 0 && (mode == RoundHalfUp || q.Bit(0) == 1))
+		}
+		if away {
+			q.Add(q, big.NewInt(int64(num.Sign())))
+		}
+	}
+	return Decimal{coef: q.Mul(q, den), exp: exp}
+}
+
+// Round round d to places decimals
+func (d Decimal) Round(places int32, mode RoundingMode) Decimal {
+	return d.RoundStep(Decimal{coef: big.NewInt(1), exp: -places}, mode)
+}
@@ -0,0 +1,59 @@
+package convert
+
+import (
+	"errors"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	convey.Convey("TestParseDecimal", t, func(convCtx convey.C) {
+		for in, want := range map[string]string{
+			"0.00010000": "0.0001",
+			"10":         "10",
+			"-12.50":     "-12.5",
+			".5":         "0.5",
+			"3.":         "3",
+			"1e-8":       "0.00000001",
+			"1.5E3":      "1500",
+			"-0.000":     "0",
+		} {
+			d, err := ParseDecimal(in)
+			convCtx.So(err, convey.ShouldBeNil)
+			convCtx.So(d.String(), convey.ShouldEqual, want)
+		}
+
+		for _, in := range []string{"", " 1", "1 ", "abc", "1.2.3", "--1", ".", "NaN", "1e", "0x10"} {
+			_, err := ParseDecimal(in)
+			convCtx.So(errors.Is(err, ErrInvalidDecimal), convey.ShouldBeTrue)
+		}
+
+		// huge exponents would make rounding build enormous powers of ten
+		for _, in := range []string{"1e1000000000", "1e-1000000000", "1e99999999999", "1e65", "1e-65"} {
+			_, err := ParseDecimal(in)
+			convCtx.So(errors.Is(err, ErrInvalidDecimal), convey.ShouldBeTrue)
+		}
+		d, err := ParseDecimal("1e64")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(d.RoundStep(MustDecimal("0.00000001"), RoundDown).Cmp(d), convey.ShouldEqual, 0)
+	})
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	convey.Convey("TestDecimalArithmetic", t, func(convCtx convey.C) {
+		a, b := MustDecimal("0.1"), MustDecimal("0.2")
+		convCtx.So(a.Add(b).String(), convey.ShouldEqual, "0.3")
+		convCtx.So(a.Sub(b).String(), convey.ShouldEqual, "-0.1")
+		convCtx.So(MustDecimal("100000").Mul(MustDecimal("0.00020005")).String(), convey.ShouldEqual, "20.005")
+		convCtx.So(MustDecimal("20.01").Div(MustDecimal("0.0002001"), 8).String(), convey.ShouldEqual, "100000")
+		convCtx.So(MustDecimal("1").Div(MustDecimal("3"), 4).String(), convey.ShouldEqual, "0.3333")
+		convCtx.So(MustDecimal("-1").Div(MustDecimal("3"), 2).String(), convey.ShouldEqual, "-0.33")
+		convCtx.So(MustDecimal("1.23456789").Truncate(4).String(), convey.ShouldEqual, "1.2345")
+		convCtx.So(MustDecimal("10").StringFixed(8), convey.ShouldEqual, "10.00000000")
+		convCtx.So(MustDecimal("0.000123456789").StringFixed(8), convey.ShouldEqual, "0.00012345")
+		convCtx.So(MustDecimal("10.00000000").Equal(MustDecimal("10")), convey.ShouldBeTrue)
+		convCtx.So(MustDecimal("9.99999999").LessThan(MustDecimal("10")), convey.ShouldBeTrue)
+		convCtx.So(Zero.IsZero(), convey.ShouldBeTrue)
+		convCtx.So(Zero.Add(MustDecimal("1.5")).String(), convey.ShouldEqual, "1.5")
+	})
+}
@@ -0,0 +1,282 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"strings"
+)
+
+/*
+错误分类:
+
+Exchange 返回的 *common.APIError 按 code (和 msg) 映射成 *ExchangeError, Kind 是下面的某个哨兵错误,
+调用方用 errors.Is(err, ErrInsufficientBalance) 判断类别, 用 errors.As(err, &exchangeErr) 取 Code, Filter 等细节,
+errors.As(err, &apiErr) 依然能拿到原始的 *common.APIError.
+本地检查出的错误 (过滤器, 限频, 交易对状态) 也实现了同样的 errors.Is.
+see https://binance-docs.github.io/apidocs/spot/en/#error-codes
+*/
+
+var (
+	ErrInsufficientBalance        = errors.New("insufficient balance")
+	ErrUnknownOrder               = errors.New("unknown order")
+	ErrDuplicateOrder             = errors.New("duplicate order")
+	ErrOrderWouldTrigger          = errors.New("order would trigger or match immediately")
+	ErrTimestampOutsideRecvWindow = errors.New("timestamp outside recvWindow")
+	ErrInvalidSignature           = errors.New("invalid signature")
+	ErrInvalidAPIKey              = errors.New("invalid api key, ip or permissions")
+	ErrSymbolNotTrading           = errors.New("symbol not trading")
+	ErrInvalidParameter           = errors.New("invalid parameter")
+	ErrExchangeUnavailable        = errors.New("exchange unavailable")
+	ErrListenKeyNotFound          = errors.New("listenKey not found")
+	ErrOrderRejected              = errors.New("order rejected")
+)
+
+// ExchangeError an APIError with its Kind, errors.Is(err, Kind) is true
+type ExchangeError struct {
+	Code    int64  `json:"code"`
+	Message string `json:"msg"`
+	Kind    error  `json:"-"`
+	Filter  string `json:"filter,omitempty"` // failed filter of a -1013 "Filter failure: X"
+	Err     error  `json:"-"`                // the *common.APIError
+}
+
+func (e *ExchangeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExchangeError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *ExchangeError) Unwrap() error {
+	return e.Err
+}
+
+const filterFailurePrefix = "Filter failure: "
+
+// errorKinds by code, codes sharing a code across kinds are told apart by message in errorKind
+var errorKinds = map[int64]error{
+	0:      ErrExchangeUnavailable, // 5xx pages without json
+	-1000:  ErrExchangeUnavailable,
+	-1001:  ErrExchangeUnavailable,
+	-1002:  ErrInvalidAPIKey,
+	-1003:  ErrRateLimited,
+	-1006:  ErrExchangeUnavailable,
+	-1007:  ErrExchangeUnavailable,
+	-1008:  ErrExchangeUnavailable,
+	-1015:  ErrRateLimited,
+	-1021:  ErrTimestampOutsideRecvWindow,
+	-1022:  ErrInvalidSignature,
+	-1121:  ErrUnknownSymbol,
+	-1125:  ErrListenKeyNotFound,
+	-2010:  ErrOrderRejected,
+	-2011:  ErrUnknownOrder,
+	-2013:  ErrUnknownOrder,
+	-2014:  ErrInvalidAPIKey,
+	-2015:  ErrInvalidAPIKey,
+	-4026:  ErrInsufficientBalance,
+	345103: ErrConvertQuoteExpired,
+}
+
+// errorKind Kind and failed filter of a binance error
+func errorKind(apiErr *common.APIError) (error, string) {
+	msg := apiErr.Message
+	switch {
+	case strings.HasPrefix(msg, filterFailurePrefix):
+		return ErrFilterViolation, strings.TrimSuffix(strings.TrimPrefix(msg, filterFailurePrefix), ".")
+	case strings.Contains(msg, "insufficient balance"):
+		return ErrInsufficientBalance, ""
+	case strings.Contains(msg, "Duplicate order"):
+		return ErrDuplicateOrder, ""
+	case strings.Contains(msg, "would immediately match") || strings.Contains(msg, "would trigger immediately"):
+		return ErrOrderWouldTrigger, ""
+	case strings.Contains(msg, "Market is closed") || strings.Contains(msg, "not possible in this trading phase"):
+		return ErrSymbolNotTrading, ""
+	case strings.Contains(msg, "This action is disabled on this account") || strings.Contains(msg, "not permitted"):
+		return ErrInvalidAPIKey, ""
+	}
+	if kind, ok := errorKinds[apiErr.Code]; ok {
+		return kind, ""
+	}
+	// -11xx request issues, -1013 "Invalid quantity." and the like
+	if (apiErr.Code <= -1100 && apiErr.Code > -1200) || apiErr.Code == -1013 {
+		return ErrInvalidParameter, ""
+	}
+	return nil, ""
+}
+
+// ClassifyError wrap the *common.APIError in err as *ExchangeError, other errors are returned as they are
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var exchangeErr *ExchangeError
+	if errors.As(err, &exchangeErr) {
+		return err
+	}
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	kind, filter := errorKind(apiErr)
+	if kind == nil {
+		return err
+	}
+	return &ExchangeError{Code: apiErr.Code, Message: apiErr.Message, Kind: kind, Filter: filter, Err: err}
+}
+
+// checkTrading ErrSymbolNotTrading unless the symbol is TRADING, an unknown status is let through
+func (s *SymbolInfo) checkTrading() error {
+	if s.Status != "" && !s.IsTrading() {
+		return fmt.Errorf("%s status %s %w", s.Symbol, s.Status, ErrSymbolNotTrading)
+	}
+	return nil
+}
+
+// classifiedExchange every error of Exchange goes through ClassifyError
+type classifiedExchange struct {
+	Exchange
+}
+
+func classifyExchange(exchange Exchange) Exchange {
+	if _, ok := exchange.(*classifiedExchange); ok {
+		return exchange
+	}
+	return &classifiedExchange{Exchange: exchange}
+}
+
+func (e *classifiedExchange) ListBookTickers(ctx context.Context, symbol string) ([]*binance.BookTicker, error) {
+	res, err := e.Exchange.ListBookTickers(ctx, symbol)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) ExchangeInfo(ctx context.Context, symbols ...string) (*binance.ExchangeInfo, error) {
+	res, err := e.Exchange.ExchangeInfo(ctx, symbols...)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) AveragePrice(ctx context.Context, symbol string) (*binance.AvgPrice, error) {
+	res, err := e.Exchange.AveragePrice(ctx, symbol)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) CreateOrder(ctx context.Context, params *CreateOrderParams) (*binance.CreateOrderResponse, error) {
+	res, err := e.Exchange.CreateOrder(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) GetOrder(ctx context.Context, params *QueryOrderParams) (*binance.Order, error) {
+	res, err := e.Exchange.GetOrder(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) CancelOrder(ctx context.Context, params *QueryOrderParams) (*binance.CancelOrderResponse, error) {
+	res, err := e.Exchange.CancelOrder(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) ListOrders(ctx context.Context, params *ListOrdersParams) ([]*binance.Order, error) {
+	res, err := e.Exchange.ListOrders(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) ListOpenOrders(ctx context.Context, symbol string) ([]*binance.Order, error) {
+	res, err := e.Exchange.ListOpenOrders(ctx, symbol)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) ListTrades(ctx context.Context, params *ListTradesParams) ([]*binance.TradeV3, error) {
+	res, err := e.Exchange.ListTrades(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) CreateWithdraw(ctx context.Context, params *WithdrawParams) (*binance.CreateWithdrawResponse, error) {
+	res, err := e.Exchange.CreateWithdraw(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) ListWithdraws(ctx context.Context, coin string, withdrawOrderId string) ([]*binance.Withdraw, error) {
+	res, err := e.Exchange.ListWithdraws(ctx, coin, withdrawOrderId)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) TradeFee(ctx context.Context, symbol string) ([]*binance.TradeFeeDetails, error) {
+	res, err := e.Exchange.TradeFee(ctx, symbol)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) Klines(ctx context.Context, params *KlinesParams) ([]*binance.Kline, error) {
+	res, err := e.Exchange.Klines(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) ListPrices(ctx context.Context, symbols ...string) ([]*binance.SymbolPrice, error) {
+	res, err := e.Exchange.ListPrices(ctx, symbols...)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) UserAsset(ctx context.Context, asset string) ([]*binance.UserAssetV3, error) {
+	res, err := e.Exchange.UserAsset(ctx, asset)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) CreateOCO(ctx context.Context, params *CreateOCOParams) (*binance.CreateOCOResponse, error) {
+	res, err := e.Exchange.CreateOCO(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) GetOrderList(ctx context.Context, params *QueryOrderListParams) (*binance.Oco, error) {
+	res, err := e.Exchange.GetOrderList(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) ListOpenOrderLists(ctx context.Context) ([]*binance.Oco, error) {
+	res, err := e.Exchange.ListOpenOrderLists(ctx)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) CancelOrderList(ctx context.Context, params *QueryOrderListParams) (*binance.CancelOCOResponse, error) {
+	res, err := e.Exchange.CancelOrderList(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) StartUserStream(ctx context.Context) (string, error) {
+	res, err := e.Exchange.StartUserStream(ctx)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) KeepaliveUserStream(ctx context.Context, listenKey string) error {
+	return ClassifyError(e.Exchange.KeepaliveUserStream(ctx, listenKey))
+}
+
+func (e *classifiedExchange) CloseUserStream(ctx context.Context, listenKey string) error {
+	return ClassifyError(e.Exchange.CloseUserStream(ctx, listenKey))
+}
+
+func (e *classifiedExchange) Depth(ctx context.Context, symbol string, limit int) (*binance.DepthResponse, error) {
+	res, err := e.Exchange.Depth(ctx, symbol, limit)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) GetConvertQuote(ctx context.Context, params *ConvertQuoteParams) (*ConvertQuoteResponse, error) {
+	res, err := e.Exchange.GetConvertQuote(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) AcceptConvertQuote(ctx context.Context, quoteId string) (*AcceptQuoteResponse, error) {
+	res, err := e.Exchange.AcceptConvertQuote(ctx, quoteId)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) GetConvertOrder(ctx context.Context, params *QueryConvertOrderParams) (*binance.ConvertTradeHistoryItem, error) {
+	res, err := e.Exchange.GetConvertOrder(ctx, params)
+	return res, ClassifyError(err)
+}
+
+func (e *classifiedExchange) ListConvertTrades(ctx context.Context, params *ListConvertTradesParams) (*binance.ConvertTradeHistory, error) {
+	res, err := e.Exchange.ListConvertTrades(ctx, params)
+	return res, ClassifyError(err)
+}
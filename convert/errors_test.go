@@ -0,0 +1,97 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	convey.Convey("TestClassifyError", t, func(convCtx convey.C) {
+		cases := []struct {
+			code int64
+			msg  string
+			kind error
+		}{
+			{-2010, "Account has insufficient balance for requested action.", ErrInsufficientBalance},
+			{-2010, "Duplicate order sent.", ErrDuplicateOrder},
+			{-2010, "Order would immediately match and take.", ErrOrderWouldTrigger},
+			{-2010, "Stop price would trigger immediately.", ErrOrderWouldTrigger},
+			{-2010, "Market is closed.", ErrSymbolNotTrading},
+			{-2010, "This action is disabled on this account.", ErrInvalidAPIKey},
+			{-2010, "Unsupported order combination", ErrOrderRejected},
+			{-2011, "Unknown order sent.", ErrUnknownOrder},
+			{-2013, "Order does not exist.", ErrUnknownOrder},
+			{-1003, "Too many requests.", ErrRateLimited},
+			{-1015, "Too many new orders.", ErrRateLimited},
+			{-1021, "Timestamp for this request is outside of the recvWindow.", ErrTimestampOutsideRecvWindow},
+			{-1022, "Signature for this request is not valid.", ErrInvalidSignature},
+			{-2015, "Invalid API-key, IP, or permissions for action.", ErrInvalidAPIKey},
+			{-1121, "Invalid symbol.", ErrUnknownSymbol},
+			{-1125, "This listenKey does not exist.", ErrListenKeyNotFound},
+			{-1100, "Illegal characters found in parameter 'quantity'.", ErrInvalidParameter},
+			{-1013, "Invalid quantity.", ErrInvalidParameter},
+			{-1007, "Timeout waiting for response from backend server.", ErrExchangeUnavailable},
+			{-4026, "User has insufficient balance", ErrInsufficientBalance},
+			{345103, "Quote expired. Please try again.", ErrConvertQuoteExpired},
+		}
+		for _, c := range cases {
+			err := ClassifyError(&common.APIError{Code: c.code, Message: c.msg})
+			convCtx.So(errors.Is(err, c.kind), convey.ShouldBeTrue)
+		}
+
+		err := ClassifyError(fmt.Errorf("order %w", &common.APIError{Code: -1013, Message: "Filter failure: LOT_SIZE"}))
+		convCtx.So(errors.Is(err, ErrFilterViolation), convey.ShouldBeTrue)
+		convCtx.So(errors.Is(err, ErrInvalidParameter), convey.ShouldBeFalse)
+		var exchangeErr *ExchangeError
+		convCtx.So(errors.As(err, &exchangeErr), convey.ShouldBeTrue)
+		convCtx.So(exchangeErr.Filter, convey.ShouldEqual, FilterTypeLotSize)
+		convCtx.So(exchangeErr.Code, convey.ShouldEqual, -1013)
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(err.Error(), convey.ShouldEqual, "order <APIError> code=-1013, msg=Filter failure: LOT_SIZE")
+		convCtx.So(ClassifyError(err), convey.ShouldEqual, err)
+
+		// unknown codes and other errors pass through
+		unknown := &common.APIError{Code: -9999, Message: "?"}
+		convCtx.So(ClassifyError(unknown), convey.ShouldEqual, unknown)
+		convCtx.So(ClassifyError(context.Canceled), convey.ShouldEqual, context.Canceled)
+		convCtx.So(ClassifyError(nil), convey.ShouldBeNil)
+	})
+}
+
+func TestSpotClientErrors(t *testing.T) {
+	convey.Convey("TestSpotClientErrors", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		cli := NewSpotClient(server.Client())
+		ctx := context.Background()
+
+		server.ScriptError(http.MethodPost, "/api/v3/order", http.StatusBadRequest, -1013, "Filter failure: PERCENT_PRICE_BY_SIDE")
+		_, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20"})
+		var exchangeErr *ExchangeError
+		convCtx.So(errors.As(err, &exchangeErr), convey.ShouldBeTrue)
+		convCtx.So(exchangeErr.Filter, convey.ShouldEqual, FilterTypePercentPriceBySide)
+
+		_, err = cli.CancelOrder(ctx, &CancelReq{Symbol: "LUNCBUSD", OrderId: 42})
+		convCtx.So(errors.Is(err, ErrUnknownOrder), convey.ShouldBeTrue)
+
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "1000"})
+		convCtx.So(errors.Is(err, ErrInsufficientBalance), convey.ShouldBeTrue)
+
+		// checked locally, nothing is sent
+		fake.AddSymbol(binance.Symbol{Symbol: "EOSUSDT", Status: "BREAK", BaseAsset: "EOS", QuoteAsset: "USDT"})
+		cli.Registry().Invalidate()
+		requests := len(server.Requests())
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "EOSUSDT", Side: "BUY", Quantity: "20"})
+		convCtx.So(errors.Is(err, ErrSymbolNotTrading), convey.ShouldBeTrue)
+		_, err = cli.StopLoss(ctx, &ConditionalOrderReq{Symbol: "EOSUSDT", Side: "SELL", Quantity: "1", StopPrice: "1"})
+		convCtx.So(errors.Is(err, ErrSymbolNotTrading), convey.ShouldBeTrue)
+		convCtx.So(len(server.Requests()), convey.ShouldEqual, requests+1) // exchangeInfo
+	})
+}
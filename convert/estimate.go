@@ -0,0 +1,155 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+)
+
+/*
+按深度估算市价兑换的实际成交:
+
+沿对手盘 (BUY 吃 asks, SELL 吃 bids) 逐档成交 Quantity, 得到平均价和最差价,
+滑点 = 平均价相对最优价的偏离 (bps), 手续费按 taker 费率从到手资产中扣除.
+数量先按 Trade 的规则归一化 (stepSize / 精度), 估算和真实下单一致.
+*/
+
+// QuoteEstimate expected outcome of a MARKET order of Side for Quantity
+type QuoteEstimate struct {
+	Side          binance.SideType `json:"side"`
+	QuantityKind  QuantityKind     `json:"quantityKind"`
+	Quantity      Decimal          `json:"quantity"` // normalized request amount
+	Dust          Decimal          `json:"dust"`     // part of the request dropped by normalization
+	BaseQuantity  Decimal          `json:"baseQuantity"`
+	QuoteQuantity Decimal          `json:"quoteQuantity"`
+	AvgPrice      Decimal          `json:"avgPrice"`
+	BestPrice     Decimal          `json:"bestPrice"`
+	WorstPrice    Decimal          `json:"worstPrice"`
+	SlippageBps   Decimal          `json:"slippageBps"` // avgPrice vs bestPrice, always >= 0
+	Levels        int              `json:"levels"`      // book levels consumed
+	Complete      bool             `json:"complete"`    // false when the book is too thin for Quantity
+	FeeRate       Decimal          `json:"feeRate"`     // taker commission
+	Fee           Decimal          `json:"fee"`
+	FeeAsset      string           `json:"feeAsset"`
+	Spent         Decimal          `json:"spent"`
+	SpentAsset    string           `json:"spentAsset"`
+	NetReceived   Decimal          `json:"netReceived"` // received amount after the fee
+	ReceivedAsset string           `json:"receivedAsset"`
+}
+
+// estimate walk the order book of symbol for req.Quantity
+func (c *SpotClient) estimate(ctx context.Context, symbol *SymbolInfo, req *EstQuoteReq) (*QuoteEstimate, error) {
+	if req.Side != binance.SideTypeBuy && req.Side != binance.SideTypeSell {
+		return nil, errors.New(fmt.Sprintf("invalid side %q", req.Side))
+	}
+	normalizer, err := symbol.Normalizer()
+	if err != nil {
+		return nil, err
+	}
+	if req.Rounding != "" {
+		normalizer.QuantityMode = req.Rounding
+	}
+	kind := req.QuantityKind
+	if kind == "" {
+		kind = QuantityKindBase
+		if req.Side == binance.SideTypeBuy {
+			kind = QuantityKindQuote
+		}
+	}
+	quantity, err := ParseDecimal(req.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("quantity %w", err)
+	}
+	var normalized Normalized
+	switch kind {
+	case QuantityKindQuote:
+		normalized = normalizer.QuoteQuantity(quantity)
+	case QuantityKindBase:
+		normalized = normalizer.Quantity(quantity, binance.OrderTypeMarket)
+	default:
+		return nil, errors.New(fmt.Sprintf("invalid quantityKind %q", kind))
+	}
+
+	var fill *BookFill
+	if req.Book != nil {
+		if fill, err = req.Book.Fill(req.Side, normalized.Value, kind, symbol.BaseAssetPrecision); err != nil {
+			return nil, err
+		}
+	} else {
+		depth, err := c.exchange.Depth(ctx, symbol.Symbol, req.DepthLimit)
+		if err != nil {
+			return nil, err
+		}
+		raw, desc := depth.Asks, false
+		if req.Side == binance.SideTypeSell {
+			raw, desc = depth.Bids, true
+		}
+		levels, err := parseLevels(raw, desc)
+		if err != nil {
+			return nil, fmt.Errorf("%s depth %w", symbol.Symbol, err)
+		}
+		fill = walkLevels(levels, normalized.Value, kind, symbol.BaseAssetPrecision)
+	}
+
+	feeRate, err := c.takerFeeRate(ctx, symbol.Symbol, req.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	est := &QuoteEstimate{
+		Side:          req.Side,
+		QuantityKind:  kind,
+		Quantity:      normalized.Value,
+		Dust:          normalized.Dust,
+		BaseQuantity:  fill.Base,
+		QuoteQuantity: fill.Quote,
+		AvgPrice:      fill.AvgPrice,
+		BestPrice:     fill.BestPrice,
+		WorstPrice:    fill.WorstPrice,
+		Levels:        fill.Levels,
+		Complete:      fill.Complete,
+		FeeRate:       feeRate,
+	}
+	if !fill.BestPrice.IsZero() {
+		est.SlippageBps = est.AvgPrice.Sub(fill.BestPrice).Abs().Mul(NewDecimalFromInt(10000)).Div(fill.BestPrice, 4).Round(2, RoundHalfUp)
+	}
+	// without BNB fee discount the commission is taken from the received asset
+	if req.Side == binance.SideTypeBuy {
+		est.Spent, est.SpentAsset = fill.Quote, symbol.QuoteAsset
+		est.Fee = fill.Base.Mul(feeRate).Round(symbol.BaseAssetPrecision, RoundUp)
+		est.FeeAsset, est.ReceivedAsset = symbol.BaseAsset, symbol.BaseAsset
+		est.NetReceived = fill.Base.Sub(est.Fee)
+	} else {
+		est.Spent, est.SpentAsset = fill.Base, symbol.BaseAsset
+		est.Fee = fill.Quote.Mul(feeRate).Round(symbol.QuoteAssetPrecision, RoundUp)
+		est.FeeAsset, est.ReceivedAsset = symbol.QuoteAsset, symbol.QuoteAsset
+		est.NetReceived = fill.Quote.Sub(est.Fee)
+	}
+	return est, nil
+}
+
+// takerFeeRate override when set, the account's TradeFee otherwise
+func (c *SpotClient) takerFeeRate(ctx context.Context, symbol, override string) (Decimal, error) {
+	if override != "" {
+		rate, err := ParseDecimal(override)
+		if err != nil {
+			return Zero, fmt.Errorf("feeRate %w", err)
+		}
+		return rate, nil
+	}
+	fees, err := c.exchange.TradeFee(ctx, symbol)
+	if err != nil {
+		return Zero, err
+	}
+	for _, fee := range fees {
+		if fee.Symbol == symbol {
+			rate, err := ParseDecimal(fee.TakerCommission)
+			if err != nil {
+				return Zero, fmt.Errorf("%s takerCommission %w", symbol, err)
+			}
+			return rate, nil
+		}
+	}
+	return Zero, errors.New(fmt.Sprintf("%s has no trade fee", symbol))
+}
@@ -0,0 +1,75 @@
+package convert
+
+import (
+	"context"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestEstQuoteDepth(t *testing.T) {
+	convey.Convey("TestEstQuoteDepth", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		fake.SetDepth("LUNCBUSD", 10,
+			[]binance.Bid{{Price: "0.00020000", Quantity: "50000"}, {Price: "0.00019990", Quantity: "1000000"}},
+			[]binance.Ask{{Price: "0.00020010", Quantity: "100000"}, {Price: "0.00020020", Quantity: "100000"}, {Price: "0.00020050", Quantity: "1000000"}},
+		)
+		fake.SetTradeFee("LUNCBUSD", "0.001", "0.00075")
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+
+		resp, err := cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "30"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Data[0].AskPrice, convey.ShouldEqual, "0.00020010")
+		est := resp.Estimate
+		convCtx.So(est.QuantityKind, convey.ShouldEqual, QuantityKindQuote)
+		convCtx.So(est.Complete, convey.ShouldBeTrue)
+		convCtx.So(est.Levels, convey.ShouldEqual, 2)
+		convCtx.So(est.BaseQuantity.String(), convey.ShouldEqual, "149900.09990009")
+		convCtx.So(est.AvgPrice.String(), convey.ShouldEqual, "0.0002001332889037")
+		convCtx.So(est.WorstPrice.String(), convey.ShouldEqual, "0.0002002")
+		convCtx.So(est.SlippageBps.String(), convey.ShouldEqual, "1.66")
+		convCtx.So(est.Spent.String(), convey.ShouldEqual, "30")
+		convCtx.So(est.SpentAsset, convey.ShouldEqual, "BUSD")
+		convCtx.So(est.Fee.String(), convey.ShouldEqual, "112.42507493")
+		convCtx.So(est.FeeAsset, convey.ShouldEqual, "LUNC")
+		convCtx.So(est.NetReceived.String(), convey.ShouldEqual, "149787.67482516")
+
+		est, err = cli.estimate(ctx, mustSymbol(cli, "LUNCBUSD"), &EstQuoteReq{Side: "SELL", Quantity: "100000.9"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(est.Quantity.String(), convey.ShouldEqual, "100000")
+		convCtx.So(est.Dust.String(), convey.ShouldEqual, "0.9")
+		convCtx.So(est.QuoteQuantity.String(), convey.ShouldEqual, "19.995")
+		convCtx.So(est.AvgPrice.String(), convey.ShouldEqual, "0.00019995")
+		convCtx.So(est.SlippageBps.String(), convey.ShouldEqual, "2.5")
+		convCtx.So(est.Fee.String(), convey.ShouldEqual, "0.01499625")
+		convCtx.So(est.NetReceived.String(), convey.ShouldEqual, "19.98000375")
+		convCtx.So(est.ReceivedAsset, convey.ShouldEqual, "BUSD")
+
+		// the book is too thin
+		resp, err = cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "5000000", QuantityKind: QuantityKindBase, FeeRate: "0"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Estimate.Complete, convey.ShouldBeFalse)
+		convCtx.So(resp.Estimate.BaseQuantity.String(), convey.ShouldEqual, "1200000")
+		convCtx.So(resp.Estimate.Fee.IsZero(), convey.ShouldBeTrue)
+
+		// a local book instead of a depth snapshot
+		book := NewOrderBook(fake, "LUNCBUSD", 100)
+		convCtx.So(book.load(&binance.DepthResponse{LastUpdateID: 1, Asks: []binance.Ask{{Price: "0.0003", Quantity: "1000000"}}}), convey.ShouldBeNil)
+		resp, err = cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "30", Book: book})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Estimate.BaseQuantity.String(), convey.ShouldEqual, "100000")
+		convCtx.So(resp.Estimate.SlippageBps.IsZero(), convey.ShouldBeTrue)
+
+		_, err = cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD", Side: "HOLD", Quantity: "30"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func mustSymbol(cli *SpotClient, symbol string) *SymbolInfo {
+	info, err := cli.Registry().Symbol(context.Background(), symbol)
+	if err != nil {
+		panic(err)
+	}
+	return info
+}
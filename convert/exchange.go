@@ -0,0 +1,458 @@
+package convert
+
+import (
+	"context"
+	"github.com/pursonchen/go-binance/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Exchange is the set of exchange calls SpotClient depends on.
+// BinanceExchange talks to the real REST api, FakeExchange keeps everything in memory.
+type Exchange interface {
+	ListBookTickers(ctx context.Context, symbol string) ([]*binance.BookTicker, error)
+	ExchangeInfo(ctx context.Context, symbols ...string) (*binance.ExchangeInfo, error)
+	AveragePrice(ctx context.Context, symbol string) (*binance.AvgPrice, error)
+	CreateOrder(ctx context.Context, params *CreateOrderParams) (*binance.CreateOrderResponse, error)
+	GetOrder(ctx context.Context, params *QueryOrderParams) (*binance.Order, error)
+	CancelOrder(ctx context.Context, params *QueryOrderParams) (*binance.CancelOrderResponse, error)
+	ListOrders(ctx context.Context, params *ListOrdersParams) ([]*binance.Order, error)
+	ListOpenOrders(ctx context.Context, symbol string) ([]*binance.Order, error)
+	ListTrades(ctx context.Context, params *ListTradesParams) ([]*binance.TradeV3, error)
+	CreateWithdraw(ctx context.Context, params *WithdrawParams) (*binance.CreateWithdrawResponse, error)
+	ListWithdraws(ctx context.Context, coin string, withdrawOrderId string) ([]*binance.Withdraw, error)
+	TradeFee(ctx context.Context, symbol string) ([]*binance.TradeFeeDetails, error)
+	Klines(ctx context.Context, params *KlinesParams) ([]*binance.Kline, error)
+	ListPrices(ctx context.Context, symbols ...string) ([]*binance.SymbolPrice, error)
+	UserAsset(ctx context.Context, asset string) ([]*binance.UserAssetV3, error)
+	CreateOCO(ctx context.Context, params *CreateOCOParams) (*binance.CreateOCOResponse, error)
+	GetOrderList(ctx context.Context, params *QueryOrderListParams) (*binance.Oco, error)
+	ListOpenOrderLists(ctx context.Context) ([]*binance.Oco, error)
+	CancelOrderList(ctx context.Context, params *QueryOrderListParams) (*binance.CancelOCOResponse, error)
+	StartUserStream(ctx context.Context) (string, error)
+	KeepaliveUserStream(ctx context.Context, listenKey string) error
+	CloseUserStream(ctx context.Context, listenKey string) error
+	Depth(ctx context.Context, symbol string, limit int) (*binance.DepthResponse, error)
+	GetConvertQuote(ctx context.Context, params *ConvertQuoteParams) (*ConvertQuoteResponse, error)
+	AcceptConvertQuote(ctx context.Context, quoteId string) (*AcceptQuoteResponse, error)
+	GetConvertOrder(ctx context.Context, params *QueryConvertOrderParams) (*binance.ConvertTradeHistoryItem, error)
+	ListConvertTrades(ctx context.Context, params *ListConvertTradesParams) (*binance.ConvertTradeHistory, error)
+}
+
+// CreateOrderParams empty string fields are not sent
+type CreateOrderParams struct {
+	Symbol           string
+	Side             binance.SideType
+	Type             binance.OrderType
+	TimeInForce      binance.TimeInForceType
+	Quantity         string
+	QuoteOrderQty    string
+	Price            string
+	StopPrice        string
+	TrailingDelta    int64 // BIPS, 0 is not sent
+	NewClientOrderId string
+	NewOrderRespType binance.NewOrderRespType
+}
+
+// QueryOrderParams identify an order by OrderId or OrigClientOrderId
+type QueryOrderParams struct {
+	Symbol            string
+	OrderId           int64
+	OrigClientOrderId string
+}
+
+type ListOrdersParams struct {
+	Symbol    string
+	OrderId   int64
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// ListTradesParams FromId can not be combined with StartTime or EndTime
+type ListTradesParams struct {
+	Symbol    string
+	OrderId   int64
+	FromId    int64
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// CreateOCOParams limit maker leg at Price, stop leg at StopPrice (STOP_LOSS_LIMIT when StopLimitPrice is set)
+type CreateOCOParams struct {
+	Symbol               string
+	Side                 binance.SideType
+	Quantity             string
+	Price                string
+	StopPrice            string
+	StopLimitPrice       string
+	StopLimitTimeInForce binance.TimeInForceType
+	ListClientOrderId    string
+	LimitClientOrderId   string
+	StopClientOrderId    string
+	NewOrderRespType     binance.NewOrderRespType
+}
+
+// QueryOrderListParams identify an order list by OrderListId or ListClientOrderId, Symbol is needed to cancel
+type QueryOrderListParams struct {
+	Symbol            string
+	OrderListId       int64
+	ListClientOrderId string
+}
+
+type WithdrawParams struct {
+	Coin            string
+	Address         string
+	AddressTag      string
+	Amount          string
+	WithdrawOrderId string
+}
+
+type KlinesParams struct {
+	Symbol    string
+	Interval  string
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// ConvertQuoteParams exactly one of FromAmount and ToAmount is sent
+type ConvertQuoteParams struct {
+	FromAsset  string
+	ToAsset    string
+	FromAmount string
+	ToAmount   string
+	WalletType string // SPOT FUNDING, empty is SPOT
+	ValidTime  string // 10s 30s 1m 2m, empty is 10s
+}
+
+type ConvertQuoteResponse struct {
+	QuoteId        string `json:"quoteId"`
+	Ratio          string `json:"ratio"`
+	InverseRatio   string `json:"inverseRatio"`
+	ValidTimestamp int64  `json:"validTimestamp"`
+	ToAmount       string `json:"toAmount"`
+	FromAmount     string `json:"fromAmount"`
+}
+
+// AcceptQuoteResponse orderId is a string here but a number in orderStatus and tradeFlow
+type AcceptQuoteResponse struct {
+	OrderId     string `json:"orderId"`
+	CreateTime  int64  `json:"createTime"`
+	OrderStatus string `json:"orderStatus"`
+}
+
+// QueryConvertOrderParams identify a convert order by OrderId or QuoteId
+type QueryConvertOrderParams struct {
+	OrderId int64
+	QuoteId string
+}
+
+// ListConvertTradesParams both times are required, at most 30 days apart
+type ListConvertTradesParams struct {
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// BinanceExchange Exchange backed by the binance spot REST api
+type BinanceExchange struct {
+	client *binance.Client
+}
+
+func NewBinanceExchange(client *binance.Client) *BinanceExchange {
+	return &BinanceExchange{client: client}
+}
+
+func (e *BinanceExchange) ListBookTickers(ctx context.Context, symbol string) ([]*binance.BookTicker, error) {
+	return e.client.NewListBookTickersService().Symbol(symbol).Do(ctx)
+}
+
+func (e *BinanceExchange) ExchangeInfo(ctx context.Context, symbols ...string) (*binance.ExchangeInfo, error) {
+	srv := e.client.NewExchangeInfoService()
+	if len(symbols) == 1 {
+		srv.Symbol(symbols[0])
+	} else if len(symbols) > 1 {
+		srv.Symbols(symbols...)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) AveragePrice(ctx context.Context, symbol string) (*binance.AvgPrice, error) {
+	return e.client.NewAveragePriceService().Symbol(symbol).Do(ctx)
+}
+
+func (e *BinanceExchange) CreateOrder(ctx context.Context, params *CreateOrderParams) (*binance.CreateOrderResponse, error) {
+	srv := e.client.NewCreateOrderService().Symbol(params.Symbol).Side(params.Side).Type(params.Type)
+	if params.TimeInForce != "" {
+		srv.TimeInForce(params.TimeInForce)
+	}
+	if params.Quantity != "" {
+		srv.Quantity(params.Quantity)
+	}
+	if params.QuoteOrderQty != "" {
+		srv.QuoteOrderQty(params.QuoteOrderQty)
+	}
+	if params.Price != "" {
+		srv.Price(params.Price)
+	}
+	if params.StopPrice != "" {
+		srv.StopPrice(params.StopPrice)
+	}
+	if params.TrailingDelta != 0 {
+		srv.TrailingDelta(strconv.FormatInt(params.TrailingDelta, 10))
+	}
+	if params.NewClientOrderId != "" {
+		srv.NewClientOrderID(params.NewClientOrderId)
+	}
+	if params.NewOrderRespType != "" {
+		srv.NewOrderRespType(params.NewOrderRespType)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) GetOrder(ctx context.Context, params *QueryOrderParams) (*binance.Order, error) {
+	srv := e.client.NewGetOrderService().Symbol(params.Symbol)
+	if params.OrderId > 0 {
+		srv.OrderID(params.OrderId)
+	}
+	if params.OrigClientOrderId != "" {
+		srv.OrigClientOrderID(params.OrigClientOrderId)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) CancelOrder(ctx context.Context, params *QueryOrderParams) (*binance.CancelOrderResponse, error) {
+	srv := e.client.NewCancelOrderService().Symbol(params.Symbol)
+	if params.OrderId > 0 {
+		srv.OrderID(params.OrderId)
+	}
+	if params.OrigClientOrderId != "" {
+		srv.OrigClientOrderID(params.OrigClientOrderId)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) ListOrders(ctx context.Context, params *ListOrdersParams) ([]*binance.Order, error) {
+	srv := e.client.NewListOrdersService().Symbol(params.Symbol)
+	if params.OrderId > 0 {
+		srv.OrderID(params.OrderId)
+	}
+	if params.Limit > 0 {
+		srv.Limit(params.Limit)
+	}
+	if params.StartTime > 0 {
+		srv.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		srv.EndTime(params.EndTime)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) ListTrades(ctx context.Context, params *ListTradesParams) ([]*binance.TradeV3, error) {
+	srv := e.client.NewListTradesService().Symbol(params.Symbol)
+	if params.OrderId > 0 {
+		srv.OrderId(params.OrderId)
+	}
+	if params.FromId > 0 {
+		srv.FromID(params.FromId)
+	}
+	if params.StartTime > 0 {
+		srv.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		srv.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		srv.Limit(params.Limit)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) ListOpenOrders(ctx context.Context, symbol string) ([]*binance.Order, error) {
+	srv := e.client.NewListOpenOrdersService()
+	if symbol != "" {
+		srv.Symbol(symbol)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) CreateWithdraw(ctx context.Context, params *WithdrawParams) (*binance.CreateWithdrawResponse, error) {
+	return e.client.NewCreateWithdrawService().
+		Coin(params.Coin).Address(params.Address).AddressTag(params.AddressTag).
+		Amount(params.Amount).WithdrawOrderID(params.WithdrawOrderId).Do(ctx)
+}
+
+func (e *BinanceExchange) ListWithdraws(ctx context.Context, coin string, withdrawOrderId string) ([]*binance.Withdraw, error) {
+	return e.client.NewListWithdrawsService().Coin(coin).WithdrawOrderId(withdrawOrderId).Do(ctx)
+}
+
+func (e *BinanceExchange) TradeFee(ctx context.Context, symbol string) ([]*binance.TradeFeeDetails, error) {
+	return e.client.NewTradeFeeService().Symbol(symbol).Do(ctx)
+}
+
+func (e *BinanceExchange) Klines(ctx context.Context, params *KlinesParams) ([]*binance.Kline, error) {
+	srv := e.client.NewKlinesService().Symbol(params.Symbol).Interval(params.Interval)
+	if params.StartTime > 0 {
+		srv.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		srv.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		srv.Limit(params.Limit)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) ListPrices(ctx context.Context, symbols ...string) ([]*binance.SymbolPrice, error) {
+	srv := e.client.NewListPricesService()
+	if len(symbols) == 1 {
+		srv.Symbol(symbols[0])
+	} else if len(symbols) > 1 {
+		srv.Symbols(symbols)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) UserAsset(ctx context.Context, asset string) ([]*binance.UserAssetV3, error) {
+	return e.client.NewGetUserAssetService().Asset(asset).Do(ctx)
+}
+
+func (e *BinanceExchange) CreateOCO(ctx context.Context, params *CreateOCOParams) (*binance.CreateOCOResponse, error) {
+	srv := e.client.NewCreateOCOService().Symbol(params.Symbol).Side(params.Side).
+		Quantity(params.Quantity).Price(params.Price).StopPrice(params.StopPrice)
+	if params.StopLimitPrice != "" {
+		srv.StopLimitPrice(params.StopLimitPrice)
+	}
+	if params.StopLimitTimeInForce != "" {
+		srv.StopLimitTimeInForce(params.StopLimitTimeInForce)
+	}
+	if params.ListClientOrderId != "" {
+		srv.ListClientOrderID(params.ListClientOrderId)
+	}
+	if params.LimitClientOrderId != "" {
+		srv.LimitClientOrderID(params.LimitClientOrderId)
+	}
+	if params.StopClientOrderId != "" {
+		srv.StopClientOrderID(params.StopClientOrderId)
+	}
+	if params.NewOrderRespType != "" {
+		srv.NewOrderRespType(params.NewOrderRespType)
+	}
+	return srv.Do(ctx)
+}
+
+// GetOrderList go-binance has no GET /api/v3/orderList
+func (e *BinanceExchange) GetOrderList(ctx context.Context, params *QueryOrderListParams) (*binance.Oco, error) {
+	query := url.Values{}
+	if params.OrderListId > 0 {
+		query.Set("orderListId", strconv.FormatInt(params.OrderListId, 10))
+	}
+	if params.ListClientOrderId != "" {
+		query.Set("origClientOrderId", params.ListClientOrderId)
+	}
+	resp := new(binance.Oco)
+	if err := e.callSigned(ctx, http.MethodGet, "/api/v3/orderList", query, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListOpenOrderLists go-binance ListOpenOcoService requests "/api/v3/openOrderList " with a trailing space
+func (e *BinanceExchange) ListOpenOrderLists(ctx context.Context) ([]*binance.Oco, error) {
+	resp := make([]*binance.Oco, 0)
+	if err := e.callSigned(ctx, http.MethodGet, "/api/v3/openOrderList", url.Values{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (e *BinanceExchange) CancelOrderList(ctx context.Context, params *QueryOrderListParams) (*binance.CancelOCOResponse, error) {
+	srv := e.client.NewCancelOCOService().Symbol(params.Symbol)
+	if params.OrderListId > 0 {
+		srv.OrderListID(params.OrderListId)
+	}
+	if params.ListClientOrderId != "" {
+		srv.ListClientOrderID(params.ListClientOrderId)
+	}
+	return srv.Do(ctx)
+}
+
+func (e *BinanceExchange) StartUserStream(ctx context.Context) (string, error) {
+	return e.client.NewStartUserStreamService().Do(ctx)
+}
+
+func (e *BinanceExchange) KeepaliveUserStream(ctx context.Context, listenKey string) error {
+	return e.client.NewKeepaliveUserStreamService().ListenKey(listenKey).Do(ctx)
+}
+
+func (e *BinanceExchange) CloseUserStream(ctx context.Context, listenKey string) error {
+	return e.client.NewCloseUserStreamService().ListenKey(listenKey).Do(ctx)
+}
+
+func (e *BinanceExchange) Depth(ctx context.Context, symbol string, limit int) (*binance.DepthResponse, error) {
+	srv := e.client.NewDepthService().Symbol(symbol)
+	if limit > 0 {
+		srv.Limit(limit)
+	}
+	return srv.Do(ctx)
+}
+
+// GetConvertQuote go-binance has no convert quote service
+func (e *BinanceExchange) GetConvertQuote(ctx context.Context, params *ConvertQuoteParams) (*ConvertQuoteResponse, error) {
+	query := url.Values{}
+	query.Set("fromAsset", params.FromAsset)
+	query.Set("toAsset", params.ToAsset)
+	if params.FromAmount != "" {
+		query.Set("fromAmount", params.FromAmount)
+	}
+	if params.ToAmount != "" {
+		query.Set("toAmount", params.ToAmount)
+	}
+	if params.WalletType != "" {
+		query.Set("walletType", params.WalletType)
+	}
+	if params.ValidTime != "" {
+		query.Set("validTime", params.ValidTime)
+	}
+	resp := new(ConvertQuoteResponse)
+	if err := e.callSigned(ctx, http.MethodPost, "/sapi/v1/convert/getQuote", query, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (e *BinanceExchange) AcceptConvertQuote(ctx context.Context, quoteId string) (*AcceptQuoteResponse, error) {
+	resp := new(AcceptQuoteResponse)
+	if err := e.callSigned(ctx, http.MethodPost, "/sapi/v1/convert/acceptQuote", url.Values{"quoteId": {quoteId}}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (e *BinanceExchange) GetConvertOrder(ctx context.Context, params *QueryConvertOrderParams) (*binance.ConvertTradeHistoryItem, error) {
+	query := url.Values{}
+	if params.OrderId > 0 {
+		query.Set("orderId", strconv.FormatInt(params.OrderId, 10))
+	}
+	if params.QuoteId != "" {
+		query.Set("quoteId", params.QuoteId)
+	}
+	resp := new(binance.ConvertTradeHistoryItem)
+	if err := e.callSigned(ctx, http.MethodGet, "/sapi/v1/convert/orderStatus", query, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (e *BinanceExchange) ListConvertTrades(ctx context.Context, params *ListConvertTradesParams) (*binance.ConvertTradeHistory, error) {
+	srv := e.client.NewConvertTradeHistoryService().StartTime(params.StartTime).EndTime(params.EndTime)
+	if params.Limit > 0 {
+		srv.Limit(int32(params.Limit))
+	}
+	return srv.Do(ctx)
+}
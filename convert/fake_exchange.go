@@ -0,0 +1,1398 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FakeExchange in-memory Exchange for tests and offline runs.
+// Market orders fill immediately against the book ticker (or the average price when no ticker is set),
+// level by level when a SetDepth snapshot is set, limit orders fill when they cross the book and rest otherwise,
+// resting limit and stop orders fill when SetBookTicker moves the book through their price or stopPrice.
+type FakeExchange struct {
+	mu sync.Mutex
+
+	symbols     map[string]binance.Symbol
+	bookTickers map[string]*binance.BookTicker
+	avgPrices   map[string]string
+	tradeFees   map[string]*binance.TradeFeeDetails
+	klines      map[string][]*binance.Kline
+	balances    map[string]Decimal
+	orders      []*binance.Order
+	trades      []*binance.TradeV3
+	withdraws   []*binance.Withdraw
+	orderLists  []*binance.Oco
+	triggered   map[int64]bool // stop orders whose stopPrice was reached
+	listenKeys  map[string]bool
+	depths      map[string]*binance.DepthResponse
+	quotes      map[string]*fakeConvertQuote
+	conversions []*binance.ConvertTradeHistoryItem
+	spread      Decimal // fraction of toAmount withheld from convert quotes
+	rateLimits  []binance.RateLimit
+	chargeFees  bool // deduct TradeFee commission from fills
+	nextOrderId int64
+	nextListId  int64
+	now         func() time.Time
+}
+
+func NewFakeExchange() *FakeExchange {
+	return &FakeExchange{
+		symbols:     make(map[string]binance.Symbol),
+		bookTickers: make(map[string]*binance.BookTicker),
+		avgPrices:   make(map[string]string),
+		tradeFees:   make(map[string]*binance.TradeFeeDetails),
+		klines:      make(map[string][]*binance.Kline),
+		balances:    make(map[string]Decimal),
+		triggered:   make(map[int64]bool),
+		listenKeys:  make(map[string]bool),
+		depths:      make(map[string]*binance.DepthResponse),
+		quotes:      make(map[string]*fakeConvertQuote),
+		nextOrderId: 1,
+		nextListId:  1,
+		now:         time.Now,
+	}
+}
+
+func (e *FakeExchange) AddSymbol(symbol binance.Symbol) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.symbols[symbol.Symbol] = symbol
+}
+
+func (e *FakeExchange) SetBookTicker(symbol, bidPrice, bidQty, askPrice, askQty string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bookTickers[symbol] = &binance.BookTicker{
+		Symbol:      symbol,
+		BidPrice:    bidPrice,
+		BidQuantity: bidQty,
+		AskPrice:    askPrice,
+		AskQuantity: askQty,
+	}
+	e.matchOrders(symbol)
+}
+
+// stopTriggered stop losses trigger when the price moves against the order, take profits when it moves in favour
+func stopTriggered(orderType binance.OrderType, side binance.SideType, stopPrice, price Decimal) bool {
+	stopLoss := orderType == binance.OrderTypeStopLoss || orderType == binance.OrderTypeStopLossLimit
+	if (side == binance.SideTypeSell) == stopLoss {
+		return price.Cmp(stopPrice) <= 0
+	}
+	return price.Cmp(stopPrice) >= 0
+}
+
+// matchOrders fill the resting orders of symbol the book ticker has reached: limit orders once the book
+// crosses their price, stop orders once it passes their stopPrice (limit stops then wait for their price).
+// Trailing-only stops never trigger. The other leg of a filled or triggered OCO expires
+func (e *FakeExchange) matchOrders(symbol string) {
+	s, ok := e.symbols[symbol]
+	if !ok {
+		return
+	}
+	for _, order := range e.orders {
+		if order.Symbol != symbol || (order.Status != binance.OrderStatusTypeNew && order.Status != binance.OrderStatusTypePartiallyFilled) {
+			continue
+		}
+		marketPrice, err := e.marketPrice(symbol, order.Side)
+		if err != nil {
+			return
+		}
+		if isAlgoOrder(order.Type) && !e.triggered[order.OrderID] {
+			stopPrice, err := ParseDecimal(order.StopPrice)
+			if err != nil || !stopTriggered(order.Type, order.Side, stopPrice, marketPrice) {
+				continue
+			}
+			e.triggered[order.OrderID] = true
+			e.finishOrderList(order)
+		}
+		fillPrice := marketPrice
+		if !isMarketOrder(order.Type) {
+			price := MustDecimal(order.Price)
+			if (order.Side == binance.SideTypeBuy && price.LessThan(marketPrice)) || (order.Side == binance.SideTypeSell && price.GreaterThan(marketPrice)) {
+				continue
+			}
+			fillPrice = price
+		}
+		quantity := MustDecimal(order.OrigQuantity).Sub(MustDecimal(order.ExecutedQuantity))
+		if err := e.fill(s, order, quantity, fillPrice); err != nil {
+			order.Status = binance.OrderStatusTypeExpired
+			order.IsWorking = false
+		}
+		e.finishOrderList(order)
+	}
+}
+
+// fill execute quantity of order at price, FILLED once nothing is left
+func (e *FakeExchange) fill(s binance.Symbol, order *binance.Order, quantity, price Decimal) error {
+	order.UpdateTime = binance.FormatTimestamp(e.now())
+	commission, err := e.settle(s, order.Side, quantity, quantity.Mul(price), true)
+	if err != nil {
+		return err
+	}
+	executed := MustDecimal(order.ExecutedQuantity).Add(quantity)
+	order.ExecutedQuantity = executed.StringFixed(8)
+	order.CummulativeQuoteQuantity = MustDecimal(order.CummulativeQuoteQuantity).Add(quantity.Mul(price)).StringFixed(8)
+	e.addTrade(s, order, quantity, price, true, commission)
+	order.Status = binance.OrderStatusTypePartiallyFilled
+	if executed.Cmp(MustDecimal(order.OrigQuantity)) >= 0 {
+		order.Status = binance.OrderStatusTypeFilled
+	}
+	return nil
+}
+
+// addTrade record a fill of order for myTrades, commission is paid in the asset received
+func (e *FakeExchange) addTrade(s binance.Symbol, order *binance.Order, quantity, price Decimal, maker bool, commission Decimal) *binance.TradeV3 {
+	commissionAsset := s.QuoteAsset
+	if e.chargeFees && order.Side == binance.SideTypeBuy {
+		commissionAsset = s.BaseAsset
+	}
+	trade := &binance.TradeV3{
+		ID:              int64(len(e.trades) + 1),
+		Symbol:          order.Symbol,
+		OrderID:         order.OrderID,
+		OrderListId:     order.OrderListId,
+		Price:           price.StringFixed(8),
+		Quantity:        quantity.StringFixed(8),
+		QuoteQuantity:   quantity.Mul(price).StringFixed(8),
+		Commission:      commission.StringFixed(8),
+		CommissionAsset: commissionAsset,
+		Time:            binance.FormatTimestamp(e.now()),
+		IsBuyer:         order.Side == binance.SideTypeBuy,
+		IsMaker:         maker,
+		IsBestMatch:     true,
+	}
+	e.trades = append(e.trades, trade)
+	return trade
+}
+
+// FillOrder execute quantity of a resting limit order at its price, it stays PARTIALLY_FILLED until nothing is left
+func (e *FakeExchange) FillOrder(symbol string, orderId int64, quantity string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, err := e.symbol(symbol)
+	if err != nil {
+		return err
+	}
+	order, err := e.findOrder(&QueryOrderParams{Symbol: symbol, OrderId: orderId})
+	if err != nil {
+		return err
+	}
+	if order.Status != binance.OrderStatusTypeNew && order.Status != binance.OrderStatusTypePartiallyFilled {
+		return errors.New(fmt.Sprintf("order %d is %s", orderId, order.Status))
+	}
+	dQuantity, err := ParseDecimal(quantity)
+	if err != nil {
+		return fmt.Errorf("quantity %w", err)
+	}
+	if dQuantity.Sign() <= 0 || dQuantity.Add(MustDecimal(order.ExecutedQuantity)).GreaterThan(MustDecimal(order.OrigQuantity)) {
+		return errors.New(fmt.Sprintf("cannot fill %s of order %d", quantity, orderId))
+	}
+	return e.fill(s, order, dQuantity, MustDecimal(order.Price))
+}
+
+// SetDepth order book snapshot served by Depth, without one Depth serves the book ticker as a single level
+func (e *FakeExchange) SetDepth(symbol string, lastUpdateId int64, bids, asks []binance.Bid) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.depths[symbol] = &binance.DepthResponse{
+		LastUpdateID: lastUpdateId,
+		Bids:         append([]binance.Bid(nil), bids...),
+		Asks:         append([]binance.Ask(nil), asks...),
+	}
+}
+
+func (e *FakeExchange) SetAveragePrice(symbol, price string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.avgPrices[symbol] = price
+}
+
+func (e *FakeExchange) SetTradeFee(symbol, makerCommission, takerCommission string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tradeFees[symbol] = &binance.TradeFeeDetails{
+		Symbol:          symbol,
+		MakerCommission: makerCommission,
+		TakerCommission: takerCommission,
+	}
+}
+
+func (e *FakeExchange) AddKlines(symbol, interval string, klines ...*binance.Kline) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	key := symbol + "@" + interval
+	e.klines[key] = append(e.klines[key], klines...)
+	sort.Slice(e.klines[key], func(i, j int) bool {
+		return e.klines[key][i].OpenTime < e.klines[key][j].OpenTime
+	})
+}
+
+// SetChargeFees deduct the TradeFee commission from every fill, in the asset received (no BNB discount)
+func (e *FakeExchange) SetChargeFees(charge bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.chargeFees = charge
+}
+
+// SetRateLimits rateLimits reported by ExchangeInfo
+func (e *FakeExchange) SetRateLimits(limits ...binance.RateLimit) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rateLimits = limits
+}
+
+// SetBalance fund asset, balances of assets never set are not tracked
+func (e *FakeExchange) SetBalance(asset string, free string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.balances[asset] = MustDecimal(free)
+}
+
+func (e *FakeExchange) Balance(asset string) Decimal {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.balances[asset]
+}
+
+func (e *FakeExchange) symbol(symbol string) (binance.Symbol, error) {
+	s, ok := e.symbols[symbol]
+	if !ok {
+		return s, &common.APIError{Code: -1121, Message: "Invalid symbol."}
+	}
+	return s, nil
+}
+
+func (e *FakeExchange) ListBookTickers(ctx context.Context, symbol string) ([]*binance.BookTicker, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if symbol != "" {
+		if _, err := e.symbol(symbol); err != nil {
+			return nil, err
+		}
+		ticker, ok := e.bookTickers[symbol]
+		if !ok {
+			return []*binance.BookTicker{}, nil
+		}
+		copied := *ticker
+		return []*binance.BookTicker{&copied}, nil
+	}
+	var resp []*binance.BookTicker
+	for _, name := range e.sortedSymbols() {
+		if ticker, ok := e.bookTickers[name]; ok {
+			copied := *ticker
+			resp = append(resp, &copied)
+		}
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) ExchangeInfo(ctx context.Context, symbols ...string) (*binance.ExchangeInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info := &binance.ExchangeInfo{Timezone: "UTC", ServerTime: binance.FormatTimestamp(e.now()), RateLimits: e.rateLimits}
+	if len(symbols) == 0 {
+		symbols = e.sortedSymbols()
+	}
+	for _, name := range symbols {
+		s, err := e.symbol(name)
+		if err != nil {
+			return nil, err
+		}
+		info.Symbols = append(info.Symbols, s)
+	}
+	return info, nil
+}
+
+func (e *FakeExchange) AveragePrice(ctx context.Context, symbol string) (*binance.AvgPrice, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.symbol(symbol); err != nil {
+		return nil, err
+	}
+	return &binance.AvgPrice{Mins: 5, Price: e.avgPrices[symbol]}, nil
+}
+
+// newOrder NEW order for params with the next order id, the caller increments nextOrderId once stored
+func (e *FakeExchange) newOrder(params *CreateOrderParams, now int64) *binance.Order {
+	order := &binance.Order{
+		Symbol:                   params.Symbol,
+		OrderID:                  e.nextOrderId,
+		OrderListId:              -1,
+		ClientOrderID:            params.NewClientOrderId,
+		Price:                    "0",
+		OrigQuantity:             params.Quantity,
+		ExecutedQuantity:         "0",
+		CummulativeQuoteQuantity: "0",
+		Status:                   binance.OrderStatusTypeNew,
+		TimeInForce:              params.TimeInForce,
+		Type:                     params.Type,
+		Side:                     params.Side,
+		StopPrice:                params.StopPrice,
+		Time:                     now,
+		UpdateTime:               now,
+		IsWorking:                true,
+		OrigQuoteOrderQuantity:   params.QuoteOrderQty,
+	}
+	if order.ClientOrderID == "" {
+		order.ClientOrderID = fmt.Sprintf("fake%d", order.OrderID)
+	}
+	if params.Price != "" {
+		order.Price = params.Price
+	}
+
+	return order
+}
+
+func (e *FakeExchange) CreateOrder(ctx context.Context, params *CreateOrderParams) (*binance.CreateOrderResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, err := e.symbol(params.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// binance refuses a clientOrderId still used by an open order
+	for _, open := range e.orders {
+		if params.NewClientOrderId != "" && open.ClientOrderID == params.NewClientOrderId && open.Symbol == params.Symbol &&
+			(open.Status == binance.OrderStatusTypeNew || open.Status == binance.OrderStatusTypePartiallyFilled) {
+			return nil, &common.APIError{Code: -2010, Message: "Duplicate order sent."}
+		}
+	}
+
+	now := binance.FormatTimestamp(e.now())
+	order := e.newOrder(params, now)
+
+	var fills []*binance.Fill
+	switch params.Type {
+	case binance.OrderTypeMarket:
+		amount, kind, err := marketAmount(params.Quantity, params.QuoteOrderQty)
+		if err != nil {
+			return nil, err
+		}
+		levels, complete, err := e.takerLevels(s, params.Side, amount, kind, Zero)
+		if err != nil {
+			return nil, err
+		}
+		quantity, quoteQuantity := levelTotals(levels)
+		if quantity.IsZero() && kind != QuantityKindBase {
+			return nil, &common.APIError{Code: -2010, Message: "Order book liquidity is less than LOT_SIZE filter minimum quantity."}
+		}
+		if err = e.checkBalance(s, params.Side, quantity, quoteQuantity); err != nil {
+			return nil, err
+		}
+		order.OrigQuantity = quantity.StringFixed(8)
+		if kind == QuantityKindBase {
+			order.OrigQuantity = amount.StringFixed(8)
+		}
+		fills = e.takeLevels(s, order, levels)
+		order.Status = binance.OrderStatusTypeFilled
+		if !complete {
+			// the book ran out, binance expires the rest
+			order.Status = binance.OrderStatusTypeExpired
+			order.IsWorking = false
+		}
+	case binance.OrderTypeLimit, binance.OrderTypeLimitMaker:
+		price, err := ParseDecimal(params.Price)
+		if err != nil {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'price'."}
+		}
+		quantity, err := ParseDecimal(params.Quantity)
+		if err != nil {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'quantity'."}
+		}
+		// without a market the order rests
+		levels, complete, err := e.takerLevels(s, params.Side, quantity, QuantityKindBase, price)
+		if err != nil {
+			levels = nil
+		}
+		if len(levels) > 0 && params.Type == binance.OrderTypeLimitMaker {
+			return nil, &common.APIError{Code: -2010, Message: "Order would immediately match and take."}
+		}
+		if !complete && params.TimeInForce == binance.TimeInForceTypeFOK {
+			levels = nil
+		}
+		if len(levels) > 0 {
+			base, quote := levelTotals(levels)
+			if err = e.checkBalance(s, params.Side, base, quote); err != nil {
+				return nil, err
+			}
+			fills = e.takeLevels(s, order, levels)
+			order.Status = binance.OrderStatusTypePartiallyFilled
+			if complete {
+				order.Status = binance.OrderStatusTypeFilled
+			}
+		}
+		if order.Status != binance.OrderStatusTypeFilled && (params.TimeInForce == binance.TimeInForceTypeIOC || params.TimeInForce == binance.TimeInForceTypeFOK) {
+			order.Status = binance.OrderStatusTypeExpired
+			order.IsWorking = false
+		}
+	case binance.OrderTypeStopLoss, binance.OrderTypeStopLossLimit, binance.OrderTypeTakeProfit, binance.OrderTypeTakeProfitLimit:
+		if params.StopPrice == "" && params.TrailingDelta == 0 {
+			return nil, &common.APIError{Code: -1102, Message: "Mandatory parameter 'stopPrice' was not sent, was empty/null, or malformed."}
+		}
+		if _, err := ParseDecimal(params.Quantity); err != nil {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'quantity'."}
+		}
+		if !isMarketOrder(params.Type) {
+			if _, err := ParseDecimal(params.Price); err != nil {
+				return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'price'."}
+			}
+		}
+		if params.StopPrice != "" {
+			stopPrice, err := ParseDecimal(params.StopPrice)
+			if err != nil {
+				return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'stopPrice'."}
+			}
+			if marketPrice, err := e.marketPrice(params.Symbol, params.Side); err == nil && stopTriggered(params.Type, params.Side, stopPrice, marketPrice) {
+				return nil, &common.APIError{Code: -2010, Message: "Stop price would trigger immediately."}
+			}
+		}
+	default:
+		return nil, &common.APIError{Code: -1116, Message: "Invalid orderType."}
+	}
+
+	e.nextOrderId++
+	e.orders = append(e.orders, order)
+
+	return &binance.CreateOrderResponse{
+		Symbol:                   order.Symbol,
+		OrderID:                  order.OrderID,
+		ClientOrderID:            order.ClientOrderID,
+		TransactTime:             now,
+		Price:                    order.Price,
+		OrigQuantity:             order.OrigQuantity,
+		ExecutedQuantity:         order.ExecutedQuantity,
+		CummulativeQuoteQuantity: order.CummulativeQuoteQuantity,
+		Status:                   order.Status,
+		TimeInForce:              order.TimeInForce,
+		Type:                     order.Type,
+		Side:                     order.Side,
+		Fills:                    fills,
+	}, nil
+}
+
+// marketPrice price a taker on side would trade at
+func (e *FakeExchange) marketPrice(symbol string, side binance.SideType) (Decimal, error) {
+	price := e.avgPrices[symbol]
+	if ticker, ok := e.bookTickers[symbol]; ok {
+		if side == binance.SideTypeBuy {
+			price = ticker.AskPrice
+		} else {
+			price = ticker.BidPrice
+		}
+	}
+	dPrice, err := ParseDecimal(price)
+	if err != nil || dPrice.Sign() <= 0 {
+		return Zero, &common.APIError{Code: -1013, Message: "Market is closed."}
+	}
+	return dPrice, nil
+}
+
+// marketAmount amount of a market order and whether it is in base or quote
+func marketAmount(quantity, quoteOrderQty string) (Decimal, QuantityKind, error) {
+	if quantity != "" {
+		dQuantity, err := ParseDecimal(quantity)
+		if err != nil {
+			return Zero, "", &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'quantity'."}
+		}
+		return dQuantity, QuantityKindBase, nil
+	}
+	dQuoteQuantity, err := ParseDecimal(quoteOrderQty)
+	if err != nil {
+		return Zero, "", &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'quoteOrderQty'."}
+	}
+	return dQuoteQuantity, QuantityKindQuote, nil
+}
+
+// takerLevels the fills of a taker order for amount (base or quote) on side, best price first, and whether
+// the whole amount fills. They come from the SetDepth snapshot when there is one (an empty snapshot has no
+// liquidity), without it the order fills completely at the book ticker. A positive limit skips worse prices,
+// quote amounts buy as much base as the precision allows
+func (e *FakeExchange) takerLevels(s binance.Symbol, side binance.SideType, amount Decimal, kind QuantityKind, limit Decimal) ([]PriceLevel, bool, error) {
+	var book []PriceLevel
+	depth, ok := e.depths[s.Symbol]
+	if ok {
+		var err error
+		if side == binance.SideTypeBuy {
+			book, err = parseLevels(depth.Asks, false)
+		} else {
+			book, err = parseLevels(depth.Bids, true)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	precision := int32(s.BaseAssetPrecision)
+	if precision == 0 {
+		precision = 8
+	}
+	if !ok {
+		price, err := e.marketPrice(s.Symbol, side)
+		if err != nil {
+			return nil, false, err
+		}
+		if limit.Sign() > 0 && ((side == binance.SideTypeBuy && price.GreaterThan(limit)) || (side == binance.SideTypeSell && price.LessThan(limit))) {
+			return nil, false, nil
+		}
+		base := amount
+		if kind == QuantityKindQuote {
+			base = amount.Div(price, precision)
+		}
+		return []PriceLevel{{Price: price, Quantity: base}}, true, nil
+	}
+
+	var levels []PriceLevel
+	remaining := amount
+	for _, level := range book {
+		if remaining.Sign() <= 0 {
+			break
+		}
+		if limit.Sign() > 0 && ((side == binance.SideTypeBuy && level.Price.GreaterThan(limit)) || (side == binance.SideTypeSell && level.Price.LessThan(limit))) {
+			break
+		}
+		base := level.Quantity
+		if kind == QuantityKindQuote {
+			if base.Mul(level.Price).Cmp(remaining) >= 0 {
+				// the rest of the quote amount, base truncated to the precision
+				base = remaining.Div(level.Price, precision)
+				remaining = Zero
+			} else {
+				remaining = remaining.Sub(base.Mul(level.Price))
+			}
+		} else {
+			base = MinDecimal(base, remaining)
+			remaining = remaining.Sub(base)
+		}
+		if base.Sign() > 0 {
+			levels = append(levels, PriceLevel{Price: level.Price, Quantity: base})
+		}
+	}
+	return levels, remaining.Sign() <= 0, nil
+}
+
+func levelTotals(levels []PriceLevel) (base, quote Decimal) {
+	for _, level := range levels {
+		base = base.Add(level.Quantity)
+		quote = quote.Add(level.Quantity.Mul(level.Price))
+	}
+	return base, quote
+}
+
+// takeLevels settle every level as a taker trade of order, the balance must have been checked
+func (e *FakeExchange) takeLevels(s binance.Symbol, order *binance.Order, levels []PriceLevel) []*binance.Fill {
+	executed, cummulative := MustDecimal(order.ExecutedQuantity), MustDecimal(order.CummulativeQuoteQuantity)
+	var fills []*binance.Fill
+	for _, level := range levels {
+		commission, _ := e.settle(s, order.Side, level.Quantity, level.Quantity.Mul(level.Price), false)
+		trade := e.addTrade(s, order, level.Quantity, level.Price, false, commission)
+		executed, cummulative = executed.Add(level.Quantity), cummulative.Add(level.Quantity.Mul(level.Price))
+		fills = append(fills, &binance.Fill{
+			TradeID:         int(trade.ID),
+			Price:           trade.Price,
+			Quantity:        trade.Quantity,
+			Commission:      trade.Commission,
+			CommissionAsset: trade.CommissionAsset,
+		})
+	}
+	order.ExecutedQuantity = executed.StringFixed(8)
+	order.CummulativeQuoteQuantity = cummulative.StringFixed(8)
+	return fills
+}
+
+// settle move balances for a fill and return the commission kept from the asset received,
+// assets never funded are not checked
+func (e *FakeExchange) settle(s binance.Symbol, side binance.SideType, quantity, quoteQuantity Decimal, maker bool) (Decimal, error) {
+	payAsset, payAmount, getAsset, getAmount := s.QuoteAsset, quoteQuantity, s.BaseAsset, quantity
+	if side == binance.SideTypeSell {
+		payAsset, payAmount, getAsset, getAmount = s.BaseAsset, quantity, s.QuoteAsset, quoteQuantity
+	}
+	if err := e.checkBalance(s, side, quantity, quoteQuantity); err != nil {
+		return Zero, err
+	}
+	commission := e.commission(s.Symbol, getAmount, maker)
+	if balance, ok := e.balances[payAsset]; ok {
+		e.balances[payAsset] = balance.Sub(payAmount)
+	}
+	if balance, ok := e.balances[getAsset]; ok {
+		e.balances[getAsset] = balance.Add(getAmount).Sub(commission)
+	}
+	return commission, nil
+}
+
+// checkBalance the asset paid for a fill is funded, assets never funded are not checked
+func (e *FakeExchange) checkBalance(s binance.Symbol, side binance.SideType, quantity, quoteQuantity Decimal) error {
+	payAsset, payAmount := s.QuoteAsset, quoteQuantity
+	if side == binance.SideTypeSell {
+		payAsset, payAmount = s.BaseAsset, quantity
+	}
+	if balance, ok := e.balances[payAsset]; ok && balance.LessThan(payAmount) {
+		return &common.APIError{Code: -2010, Message: "Account has insufficient balance for requested action."}
+	}
+	return nil
+}
+
+// commission fee on amount received at the symbol's TradeFee rate, zero unless SetChargeFees
+func (e *FakeExchange) commission(symbol string, amount Decimal, maker bool) Decimal {
+	if !e.chargeFees {
+		return Zero
+	}
+	rate := "0.001"
+	if fee, ok := e.tradeFees[symbol]; ok {
+		rate = fee.TakerCommission
+		if maker {
+			rate = fee.MakerCommission
+		}
+	}
+	dRate, err := ParseDecimal(rate)
+	if err != nil {
+		return Zero
+	}
+	return amount.Mul(dRate).Round(8, RoundUp)
+}
+
+func (e *FakeExchange) findOrder(params *QueryOrderParams) (*binance.Order, error) {
+	// a clientOrderId may be reused once its order is closed, the latest order wins
+	for i := len(e.orders) - 1; i >= 0; i-- {
+		order := e.orders[i]
+		if order.Symbol != params.Symbol {
+			continue
+		}
+		if (params.OrderId > 0 && order.OrderID == params.OrderId) ||
+			(params.OrderId == 0 && params.OrigClientOrderId != "" && order.ClientOrderID == params.OrigClientOrderId) {
+			return order, nil
+		}
+	}
+	return nil, &common.APIError{Code: -2013, Message: "Order does not exist."}
+}
+
+func (e *FakeExchange) GetOrder(ctx context.Context, params *QueryOrderParams) (*binance.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	order, err := e.findOrder(params)
+	if err != nil {
+		return nil, err
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (e *FakeExchange) CancelOrder(ctx context.Context, params *QueryOrderParams) (*binance.CancelOrderResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	order, err := e.findOrder(params)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != binance.OrderStatusTypeNew && order.Status != binance.OrderStatusTypePartiallyFilled {
+		return nil, &common.APIError{Code: -2011, Message: "Unknown order sent."}
+	}
+	order.Status = binance.OrderStatusTypeCanceled
+	order.IsWorking = false
+	order.UpdateTime = binance.FormatTimestamp(e.now())
+	// canceling one leg cancels the whole order list
+	if list, err := e.findOrderList(&QueryOrderListParams{OrderListId: order.OrderListId}); order.OrderListId >= 0 && err == nil {
+		e.cancelOrderList(list)
+	}
+	return &binance.CancelOrderResponse{
+		Symbol:                   order.Symbol,
+		OrigClientOrderID:        order.ClientOrderID,
+		OrderID:                  order.OrderID,
+		OrderListID:              order.OrderListId,
+		ClientOrderID:            order.ClientOrderID,
+		TransactTime:             order.UpdateTime,
+		Price:                    order.Price,
+		OrigQuantity:             order.OrigQuantity,
+		ExecutedQuantity:         order.ExecutedQuantity,
+		CummulativeQuoteQuantity: order.CummulativeQuoteQuantity,
+		Status:                   order.Status,
+		TimeInForce:              order.TimeInForce,
+		Type:                     order.Type,
+		Side:                     order.Side,
+	}, nil
+}
+
+// ListOrders orders from OrderId or StartTime on, otherwise the most recent ones like binance
+func (e *FakeExchange) ListOrders(ctx context.Context, params *ListOrdersParams) ([]*binance.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.symbol(params.Symbol); err != nil {
+		return nil, err
+	}
+	limit, err := fakeHistoryLimit(params.StartTime, params.EndTime, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	var resp []*binance.Order
+	for _, order := range e.orders {
+		if order.Symbol != params.Symbol || order.OrderID < params.OrderId {
+			continue
+		}
+		if (params.StartTime > 0 && order.Time < params.StartTime) || (params.EndTime > 0 && order.Time > params.EndTime) {
+			continue
+		}
+		copied := *order
+		resp = append(resp, &copied)
+	}
+	if len(resp) > limit {
+		if params.OrderId > 0 || params.StartTime > 0 {
+			resp = resp[:limit]
+		} else {
+			resp = resp[len(resp)-limit:]
+		}
+	}
+	return resp, nil
+}
+
+// fakeHistoryLimit allOrders and myTrades accept at most 1000 rows and 24 hours at once
+func fakeHistoryLimit(startTime, endTime int64, limit int) (int, error) {
+	if startTime > 0 && endTime > 0 && endTime-startTime > 24*60*60*1000 {
+		return 0, &common.APIError{Code: -1127, Message: "More than 24 hours between startTime and endTime."}
+	}
+	if limit > 1000 {
+		return 0, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'limit'; legal range is '1000'."}
+	}
+	if limit <= 0 {
+		limit = 500
+	}
+	return limit, nil
+}
+
+// ListTrades fills from FromId or StartTime on, otherwise the most recent ones like binance.
+// Like binance it rejects FromId or OrderId together with StartTime / EndTime
+func (e *FakeExchange) ListTrades(ctx context.Context, params *ListTradesParams) ([]*binance.TradeV3, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.symbol(params.Symbol); err != nil {
+		return nil, err
+	}
+	if (params.FromId > 0 || params.OrderId > 0) && (params.StartTime > 0 || params.EndTime > 0) {
+		return nil, &common.APIError{Code: -1128, Message: "Combination of optional parameters invalid."}
+	}
+	limit, err := fakeHistoryLimit(params.StartTime, params.EndTime, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	var resp []*binance.TradeV3
+	for _, trade := range e.trades {
+		if trade.Symbol != params.Symbol || trade.ID < params.FromId || (params.OrderId > 0 && trade.OrderID != params.OrderId) {
+			continue
+		}
+		if (params.StartTime > 0 && trade.Time < params.StartTime) || (params.EndTime > 0 && trade.Time > params.EndTime) {
+			continue
+		}
+		copied := *trade
+		resp = append(resp, &copied)
+	}
+	if len(resp) > limit {
+		if params.FromId > 0 || params.StartTime > 0 {
+			resp = resp[:limit]
+		} else {
+			resp = resp[len(resp)-limit:]
+		}
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) ListOpenOrders(ctx context.Context, symbol string) ([]*binance.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var resp []*binance.Order
+	for _, order := range e.orders {
+		if symbol != "" && order.Symbol != symbol {
+			continue
+		}
+		if order.Status == binance.OrderStatusTypeNew || order.Status == binance.OrderStatusTypePartiallyFilled {
+			copied := *order
+			resp = append(resp, &copied)
+		}
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) CreateWithdraw(ctx context.Context, params *WithdrawParams) (*binance.CreateWithdrawResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	amount, err := ParseDecimal(params.Amount)
+	if err != nil {
+		return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'amount'."}
+	}
+	if balance, ok := e.balances[params.Coin]; ok {
+		if balance.LessThan(amount) {
+			return nil, &common.APIError{Code: -4026, Message: "User has insufficient balance"}
+		}
+		e.balances[params.Coin] = balance.Sub(amount)
+	}
+	id := fmt.Sprintf("fakewithdraw%d", len(e.withdraws)+1)
+	e.withdraws = append(e.withdraws, &binance.Withdraw{
+		Address:         params.Address,
+		Amount:          params.Amount,
+		ApplyTime:       e.now().UTC().Format("2006-01-02 15:04:05"),
+		Coin:            params.Coin,
+		ID:              id,
+		WithdrawOrderID: params.WithdrawOrderId,
+		TransactionFee:  "0",
+	})
+	return &binance.CreateWithdrawResponse{ID: id}, nil
+}
+
+func (e *FakeExchange) ListWithdraws(ctx context.Context, coin string, withdrawOrderId string) ([]*binance.Withdraw, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var resp []*binance.Withdraw
+	for i := len(e.withdraws) - 1; i >= 0; i-- {
+		withdraw := e.withdraws[i]
+		if (coin != "" && withdraw.Coin != coin) || (withdrawOrderId != "" && withdraw.WithdrawOrderID != withdrawOrderId) {
+			continue
+		}
+		copied := *withdraw
+		resp = append(resp, &copied)
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) TradeFee(ctx context.Context, symbol string) ([]*binance.TradeFeeDetails, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	symbols := []string{symbol}
+	if symbol == "" {
+		symbols = e.sortedSymbols()
+	}
+	var resp []*binance.TradeFeeDetails
+	for _, name := range symbols {
+		if _, err := e.symbol(name); err != nil {
+			return nil, err
+		}
+		fee, ok := e.tradeFees[name]
+		if !ok {
+			fee = &binance.TradeFeeDetails{Symbol: name, MakerCommission: "0.001", TakerCommission: "0.001"}
+		}
+		copied := *fee
+		resp = append(resp, &copied)
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) Klines(ctx context.Context, params *KlinesParams) ([]*binance.Kline, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.symbol(params.Symbol); err != nil {
+		return nil, err
+	}
+	limit := params.Limit
+	if limit > 1000 {
+		return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'limit'; legal range is '1000'."}
+	}
+	if limit <= 0 {
+		limit = 500
+	}
+	var resp []*binance.Kline
+	for _, kline := range e.klines[params.Symbol+"@"+params.Interval] {
+		if (params.StartTime > 0 && kline.OpenTime < params.StartTime) || (params.EndTime > 0 && kline.OpenTime > params.EndTime) {
+			continue
+		}
+		copied := *kline
+		resp = append(resp, &copied)
+	}
+	if len(resp) > limit {
+		if params.StartTime > 0 {
+			resp = resp[:limit]
+		} else {
+			resp = resp[len(resp)-limit:]
+		}
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) ListPrices(ctx context.Context, symbols ...string) ([]*binance.SymbolPrice, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(symbols) == 0 {
+		symbols = e.sortedSymbols()
+	}
+	var resp []*binance.SymbolPrice
+	for _, name := range symbols {
+		if _, err := e.symbol(name); err != nil {
+			return nil, err
+		}
+		price := e.avgPrices[name]
+		if ticker, ok := e.bookTickers[name]; ok {
+			price = ticker.BidPrice
+		}
+		resp = append(resp, &binance.SymbolPrice{Symbol: name, Price: price})
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) UserAsset(ctx context.Context, asset string) ([]*binance.UserAssetV3, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var assets []string
+	for name, free := range e.balances {
+		if (asset == "" && free.Sign() > 0) || name == asset {
+			assets = append(assets, name)
+		}
+	}
+	sort.Strings(assets)
+	var resp []*binance.UserAssetV3
+	for _, name := range assets {
+		resp = append(resp, &binance.UserAssetV3{
+			Asset:       name,
+			Free:        e.balances[name].StringFixed(8),
+			Locked:      "0",
+			Freeze:      "0",
+			Withdrawing: "0",
+			Ipoable:     "0",
+		})
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) sortedSymbols() []string {
+	var names []string
+	for name := range e.symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e *FakeExchange) findOrderList(params *QueryOrderListParams) (*binance.Oco, error) {
+	for _, list := range e.orderLists {
+		if (params.OrderListId > 0 && list.OrderListId == params.OrderListId) ||
+			(params.OrderListId == 0 && params.ListClientOrderId != "" && list.ListClientOrderID == params.ListClientOrderId) {
+			return list, nil
+		}
+	}
+	return nil, &common.APIError{Code: -2011, Message: "Order list does not exist."}
+}
+
+// listOrders the legs of list
+func (e *FakeExchange) listOrders(list *binance.Oco) []*binance.Order {
+	var resp []*binance.Order
+	for _, order := range e.orders {
+		if order.OrderListId == list.OrderListId {
+			resp = append(resp, order)
+		}
+	}
+	return resp
+}
+
+func copyOrderList(list *binance.Oco) *binance.Oco {
+	copied := *list
+	copied.Orders = nil
+	for _, order := range list.Orders {
+		o := *order
+		copied.Orders = append(copied.Orders, &o)
+	}
+	return &copied
+}
+
+// finishOrderList once one leg of an OCO fills or triggers the other legs expire
+func (e *FakeExchange) finishOrderList(order *binance.Order) {
+	if order.OrderListId < 0 {
+		return
+	}
+	list, err := e.findOrderList(&QueryOrderListParams{OrderListId: order.OrderListId})
+	if err != nil {
+		return
+	}
+	for _, leg := range e.listOrders(list) {
+		if leg != order && leg.Status == binance.OrderStatusTypeNew {
+			leg.Status = binance.OrderStatusTypeExpired
+			leg.IsWorking = false
+			leg.UpdateTime = binance.FormatTimestamp(e.now())
+		}
+	}
+	list.ListStatusType, list.ListOrderStatus = "ALL_DONE", "ALL_DONE"
+	list.TransactionTime = binance.FormatTimestamp(e.now())
+}
+
+// CreateOCO limit maker leg above (SELL) or below (BUY) the market and a stop leg on the other side
+func (e *FakeExchange) CreateOCO(ctx context.Context, params *CreateOCOParams) (*binance.CreateOCOResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.symbol(params.Symbol); err != nil {
+		return nil, err
+	}
+	values := map[string]Decimal{}
+	for name, value := range map[string]string{"quantity": params.Quantity, "price": params.Price, "stopPrice": params.StopPrice} {
+		d, err := ParseDecimal(value)
+		if err != nil {
+			return nil, &common.APIError{Code: -1100, Message: fmt.Sprintf("Illegal characters found in parameter '%s'.", name)}
+		}
+		values[name] = d
+	}
+	marketPrice, err := e.marketPrice(params.Symbol, params.Side)
+	if err != nil {
+		return nil, err
+	}
+	price, stopPrice := values["price"], values["stopPrice"]
+	if (params.Side == binance.SideTypeSell && !(price.GreaterThan(marketPrice) && stopPrice.LessThan(marketPrice))) ||
+		(params.Side == binance.SideTypeBuy && !(price.LessThan(marketPrice) && stopPrice.GreaterThan(marketPrice))) {
+		return nil, &common.APIError{Code: -2010, Message: "The relationship of the prices for the orders is not correct."}
+	}
+
+	now := binance.FormatTimestamp(e.now())
+	list := &binance.Oco{
+		Symbol:            params.Symbol,
+		OrderListId:       e.nextListId,
+		ContingencyType:   "OCO",
+		ListStatusType:    "EXEC_STARTED",
+		ListOrderStatus:   "EXECUTING",
+		ListClientOrderID: params.ListClientOrderId,
+		TransactionTime:   now,
+	}
+	if list.ListClientOrderID == "" {
+		list.ListClientOrderID = fmt.Sprintf("fakelist%d", list.OrderListId)
+	}
+
+	stopParams := &CreateOrderParams{
+		Symbol:           params.Symbol,
+		Side:             params.Side,
+		Type:             binance.OrderTypeStopLoss,
+		Quantity:         params.Quantity,
+		StopPrice:        params.StopPrice,
+		NewClientOrderId: params.StopClientOrderId,
+	}
+	if params.StopLimitPrice != "" {
+		stopParams.Type, stopParams.Price, stopParams.TimeInForce = binance.OrderTypeStopLossLimit, params.StopLimitPrice, params.StopLimitTimeInForce
+	}
+	legs := []*CreateOrderParams{stopParams, {
+		Symbol:           params.Symbol,
+		Side:             params.Side,
+		Type:             binance.OrderTypeLimitMaker,
+		Quantity:         params.Quantity,
+		Price:            params.Price,
+		NewClientOrderId: params.LimitClientOrderId,
+	}}
+
+	resp := &binance.CreateOCOResponse{
+		OrderListID:       list.OrderListId,
+		ContingencyType:   list.ContingencyType,
+		ListStatusType:    list.ListStatusType,
+		ListOrderStatus:   list.ListOrderStatus,
+		ListClientOrderID: list.ListClientOrderID,
+		TransactionTime:   now,
+		Symbol:            params.Symbol,
+	}
+	for _, leg := range legs {
+		order := e.newOrder(leg, now)
+		order.OrderListId = list.OrderListId
+		e.nextOrderId++
+		e.orders = append(e.orders, order)
+		list.Orders = append(list.Orders, &binance.Order{Symbol: order.Symbol, OrderID: order.OrderID, ClientOrderID: order.ClientOrderID})
+		resp.Orders = append(resp.Orders, &binance.OCOOrder{Symbol: order.Symbol, OrderID: order.OrderID, ClientOrderID: order.ClientOrderID})
+		resp.OrderReports = append(resp.OrderReports, ocoOrderReport(order, now))
+	}
+	e.nextListId++
+	e.orderLists = append(e.orderLists, list)
+	return resp, nil
+}
+
+func ocoOrderReport(order *binance.Order, now int64) *binance.OCOOrderReport {
+	return &binance.OCOOrderReport{
+		Symbol:                   order.Symbol,
+		OrderID:                  order.OrderID,
+		OrderListID:              order.OrderListId,
+		ClientOrderID:            order.ClientOrderID,
+		TransactionTime:          now,
+		Price:                    order.Price,
+		OrigQuantity:             order.OrigQuantity,
+		ExecutedQuantity:         order.ExecutedQuantity,
+		CummulativeQuoteQuantity: order.CummulativeQuoteQuantity,
+		Status:                   order.Status,
+		TimeInForce:              order.TimeInForce,
+		Type:                     order.Type,
+		Side:                     order.Side,
+		StopPrice:                order.StopPrice,
+	}
+}
+
+func (e *FakeExchange) GetOrderList(ctx context.Context, params *QueryOrderListParams) (*binance.Oco, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list, err := e.findOrderList(params)
+	if err != nil {
+		return nil, err
+	}
+	return copyOrderList(list), nil
+}
+
+func (e *FakeExchange) ListOpenOrderLists(ctx context.Context) ([]*binance.Oco, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	resp := make([]*binance.Oco, 0)
+	for _, list := range e.orderLists {
+		if list.ListStatusType != "ALL_DONE" {
+			resp = append(resp, copyOrderList(list))
+		}
+	}
+	return resp, nil
+}
+
+func (e *FakeExchange) CancelOrderList(ctx context.Context, params *QueryOrderListParams) (*binance.CancelOCOResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.symbol(params.Symbol); err != nil {
+		return nil, err
+	}
+	list, err := e.findOrderList(params)
+	if err != nil || list.Symbol != params.Symbol {
+		return nil, &common.APIError{Code: -2011, Message: "Order list does not exist."}
+	}
+	if list.ListStatusType == "ALL_DONE" {
+		return nil, &common.APIError{Code: -2011, Message: "Unknown order list sent."}
+	}
+	return e.cancelOrderList(list), nil
+}
+
+func (e *FakeExchange) cancelOrderList(list *binance.Oco) *binance.CancelOCOResponse {
+	now := binance.FormatTimestamp(e.now())
+	list.ListStatusType, list.ListOrderStatus, list.TransactionTime = "ALL_DONE", "ALL_DONE", now
+	resp := &binance.CancelOCOResponse{
+		OrderListID:       list.OrderListId,
+		ContingencyType:   list.ContingencyType,
+		ListStatusType:    list.ListStatusType,
+		ListOrderStatus:   list.ListOrderStatus,
+		ListClientOrderID: list.ListClientOrderID,
+		TransactionTime:   now,
+		Symbol:            list.Symbol,
+	}
+	for _, order := range e.listOrders(list) {
+		if order.Status == binance.OrderStatusTypeNew || order.Status == binance.OrderStatusTypePartiallyFilled {
+			order.Status = binance.OrderStatusTypeCanceled
+			order.IsWorking = false
+			order.UpdateTime = now
+		}
+		resp.Orders = append(resp.Orders, &binance.OCOOrder{Symbol: order.Symbol, OrderID: order.OrderID, ClientOrderID: order.ClientOrderID})
+		resp.OrderReports = append(resp.OrderReports, ocoOrderReport(order, now))
+	}
+	return resp
+}
+
+func (e *FakeExchange) StartUserStream(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	listenKey := fmt.Sprintf("fakelistenkey%d", len(e.listenKeys)+1)
+	e.listenKeys[listenKey] = true
+	return listenKey, nil
+}
+
+func (e *FakeExchange) KeepaliveUserStream(ctx context.Context, listenKey string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.listenKeys[listenKey] {
+		return &common.APIError{Code: -1125, Message: "This listenKey does not exist."}
+	}
+	return nil
+}
+
+func (e *FakeExchange) CloseUserStream(ctx context.Context, listenKey string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.listenKeys[listenKey] {
+		return &common.APIError{Code: -1125, Message: "This listenKey does not exist."}
+	}
+	e.listenKeys[listenKey] = false
+	return nil
+}
+
+// ExpireListenKey drop a listenKey the way binance does after 60 minutes without keepalive
+func (e *FakeExchange) ExpireListenKey(listenKey string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.listenKeys[listenKey]; ok {
+		e.listenKeys[listenKey] = false
+	}
+}
+
+func (e *FakeExchange) Depth(ctx context.Context, symbol string, limit int) (*binance.DepthResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if limit <= 0 {
+		limit = 100
+	}
+	if depth, ok := e.depths[symbol]; ok {
+		resp := &binance.DepthResponse{LastUpdateID: depth.LastUpdateID}
+		for i := 0; i < len(depth.Bids) && i < limit; i++ {
+			resp.Bids = append(resp.Bids, depth.Bids[i])
+		}
+		for i := 0; i < len(depth.Asks) && i < limit; i++ {
+			resp.Asks = append(resp.Asks, depth.Asks[i])
+		}
+		return resp, nil
+	}
+	ticker, ok := e.bookTickers[symbol]
+	if !ok {
+		return nil, &common.APIError{Code: -1121, Message: "Invalid symbol."}
+	}
+	return &binance.DepthResponse{
+		LastUpdateID: 1,
+		Bids:         []binance.Bid{{Price: ticker.BidPrice, Quantity: ticker.BidQuantity}},
+		Asks:         []binance.Ask{{Price: ticker.AskPrice, Quantity: ticker.AskQuantity}},
+	}, nil
+}
+
+type fakeConvertQuote struct {
+	*ConvertQuoteResponse
+	fromAsset string
+	toAsset   string
+	accepted  bool
+}
+
+// SetConvertSpread fraction of toAmount convert quotes keep, e.g. "0.002", the default is 0
+func (e *FakeExchange) SetConvertSpread(spread string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spread = MustDecimal(spread)
+}
+
+// convertPrice book ticker price of the symbol trading both assets, inverse when from is its quote asset
+func (e *FakeExchange) convertPrice(from, to string) (price Decimal, inverse bool, err error) {
+	for _, name := range e.sortedSymbols() {
+		s := e.symbols[name]
+		if s.BaseAsset == from && s.QuoteAsset == to {
+			price, err = e.marketPrice(name, binance.SideTypeSell)
+			return price, false, err
+		}
+		if s.BaseAsset == to && s.QuoteAsset == from {
+			price, err = e.marketPrice(name, binance.SideTypeBuy)
+			return price, true, err
+		}
+	}
+	return Zero, false, &common.APIError{Code: -1121, Message: "Invalid symbol."}
+}
+
+// GetConvertQuote quotes at the book ticker without fee, less the spread set by SetConvertSpread
+func (e *FakeExchange) GetConvertQuote(ctx context.Context, params *ConvertQuoteParams) (*ConvertQuoteResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	validTime := 10 * time.Second
+	if params.ValidTime != "" {
+		d, err := time.ParseDuration(params.ValidTime)
+		if err != nil || d < 10*time.Second || d > 2*time.Minute {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'validTime'."}
+		}
+		validTime = d
+	}
+	price, inverse, err := e.convertPrice(params.FromAsset, params.ToAsset)
+	if err != nil {
+		return nil, err
+	}
+	kept := NewDecimalFromInt(1).Sub(e.spread)
+
+	var fromAmount, toAmount Decimal
+	if params.FromAmount != "" {
+		if fromAmount, err = ParseDecimal(params.FromAmount); err != nil {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'fromAmount'."}
+		}
+		if inverse {
+			toAmount = fromAmount.Mul(kept).Div(price, 8)
+		} else {
+			toAmount = fromAmount.Mul(price).Mul(kept).Round(8, RoundDown)
+		}
+	} else {
+		if toAmount, err = ParseDecimal(params.ToAmount); err != nil {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'toAmount'."}
+		}
+		if inverse {
+			fromAmount = toAmount.Mul(price).Div(kept, 16).Round(8, RoundUp)
+		} else {
+			fromAmount = toAmount.Div(price.Mul(kept), 16).Round(8, RoundUp)
+		}
+	}
+	if fromAmount.Sign() <= 0 || toAmount.Sign() <= 0 {
+		return nil, &common.APIError{Code: -1013, Message: "Invalid quantity."}
+	}
+
+	quote := &ConvertQuoteResponse{
+		QuoteId:        fmt.Sprintf("fakequote%d", len(e.quotes)+1),
+		Ratio:          toAmount.Div(fromAmount, 8).String(),
+		InverseRatio:   fromAmount.Div(toAmount, 8).String(),
+		ValidTimestamp: binance.FormatTimestamp(e.now().Add(validTime)),
+		ToAmount:       toAmount.String(),
+		FromAmount:     fromAmount.String(),
+	}
+	e.quotes[quote.QuoteId] = &fakeConvertQuote{ConvertQuoteResponse: quote, fromAsset: params.FromAsset, toAsset: params.ToAsset}
+	copied := *quote
+	return &copied, nil
+}
+
+// AcceptConvertQuote settles at once, the order reads SUCCESS from the first status query
+func (e *FakeExchange) AcceptConvertQuote(ctx context.Context, quoteId string) (*AcceptQuoteResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	quote, ok := e.quotes[quoteId]
+	now := binance.FormatTimestamp(e.now())
+	if !ok || quote.accepted || now > quote.ValidTimestamp {
+		return nil, &common.APIError{Code: 345103, Message: "Quote expired. Please try again."}
+	}
+	fromAmount, toAmount := MustDecimal(quote.FromAmount), MustDecimal(quote.ToAmount)
+	if balance, ok := e.balances[quote.fromAsset]; ok {
+		if balance.LessThan(fromAmount) {
+			return nil, &common.APIError{Code: -2010, Message: "Account has insufficient balance for requested action."}
+		}
+		e.balances[quote.fromAsset] = balance.Sub(fromAmount)
+	}
+	if balance, ok := e.balances[quote.toAsset]; ok {
+		e.balances[quote.toAsset] = balance.Add(toAmount)
+	}
+	quote.accepted = true
+
+	order := &binance.ConvertTradeHistoryItem{
+		QuoteId:      quoteId,
+		OrderId:      e.nextOrderId,
+		OrderStatus:  "SUCCESS",
+		FromAsset:    quote.fromAsset,
+		FromAmount:   quote.FromAmount,
+		ToAsset:      quote.toAsset,
+		ToAmount:     quote.ToAmount,
+		Ratio:        quote.Ratio,
+		InverseRatio: quote.InverseRatio,
+		CreateTime:   now,
+	}
+	e.nextOrderId++
+	e.conversions = append(e.conversions, order)
+	return &AcceptQuoteResponse{OrderId: strconv.FormatInt(order.OrderId, 10), CreateTime: now, OrderStatus: "PROCESS"}, nil
+}
+
+func (e *FakeExchange) GetConvertOrder(ctx context.Context, params *QueryConvertOrderParams) (*binance.ConvertTradeHistoryItem, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, order := range e.conversions {
+		if (params.OrderId > 0 && order.OrderId == params.OrderId) || (params.OrderId == 0 && params.QuoteId != "" && order.QuoteId == params.QuoteId) {
+			copied := *order
+			copied.QuoteId = ""
+			return &copied, nil
+		}
+	}
+	return nil, &common.APIError{Code: -2013, Message: "Order does not exist."}
+}
+
+func (e *FakeExchange) ListConvertTrades(ctx context.Context, params *ListConvertTradesParams) (*binance.ConvertTradeHistory, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	resp := &binance.ConvertTradeHistory{StartTime: params.StartTime, EndTime: params.EndTime, Limit: int32(limit), List: []binance.ConvertTradeHistoryItem{}}
+	for _, order := range e.conversions {
+		if order.CreateTime < params.StartTime || order.CreateTime > params.EndTime {
+			continue
+		}
+		if len(resp.List) == limit {
+			resp.MoreData = true
+			break
+		}
+		resp.List = append(resp.List, *order)
+	}
+	return resp, nil
+}
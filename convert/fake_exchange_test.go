@@ -0,0 +1,244 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+// newTestFakeExchange LUNCBUSD and EOSBTC with filters copied from a real exchangeInfo
+func newTestFakeExchange() *FakeExchange {
+	fake := NewFakeExchange()
+	fake.AddSymbol(binance.Symbol{
+		Symbol:               "LUNCBUSD",
+		Status:               "TRADING",
+		BaseAsset:            "LUNC",
+		BaseAssetPrecision:   8,
+		QuoteAsset:           "BUSD",
+		QuotePrecision:       8,
+		QuoteAssetPrecision:  8,
+		OrderTypes:           []string{"LIMIT", "LIMIT_MAKER", "MARKET", "STOP_LOSS_LIMIT", "TAKE_PROFIT_LIMIT"},
+		IcebergAllowed:       true,
+		OcoAllowed:           true,
+		IsSpotTradingAllowed: true,
+		Filters: []map[string]interface{}{
+			{"filterType": "PRICE_FILTER", "minPrice": "0.00000001", "maxPrice": "1.00000000", "tickSize": "0.00000001"},
+			{"filterType": "LOT_SIZE", "minQty": "1.00", "maxQty": "92141578.00", "stepSize": "1.00"},
+			{"filterType": "MIN_NOTIONAL", "minNotional": "10.00000000", "applyToMarket": true, "avgPriceMins": float64(5)},
+			{"filterType": "ICEBERG_PARTS", "limit": float64(10)},
+			{"filterType": "MARKET_LOT_SIZE", "minQty": "0.00", "maxQty": "4687306.50", "stepSize": "0.00"},
+			{"filterType": "MAX_NUM_ORDERS", "maxNumOrders": float64(200)},
+		},
+		Permissions: []string{"SPOT"},
+	})
+	fake.AddSymbol(binance.Symbol{
+		Symbol:               "EOSBTC",
+		Status:               "TRADING",
+		BaseAsset:            "EOS",
+		BaseAssetPrecision:   8,
+		QuoteAsset:           "BTC",
+		QuotePrecision:       8,
+		QuoteAssetPrecision:  8,
+		OrderTypes:           []string{"LIMIT", "LIMIT_MAKER", "MARKET", "STOP_LOSS_LIMIT", "TAKE_PROFIT_LIMIT"},
+		IsSpotTradingAllowed: true,
+		Filters: []map[string]interface{}{
+			{"filterType": "PRICE_FILTER", "minPrice": "0.00000010", "maxPrice": "100.00000000", "tickSize": "0.00000010"},
+			{"filterType": "LOT_SIZE", "minQty": "0.10000000", "maxQty": "90000000.00000000", "stepSize": "0.10000000"},
+			{"filterType": "MIN_NOTIONAL", "minNotional": "0.00010000", "applyToMarket": true, "avgPriceMins": float64(5)},
+		},
+		Permissions: []string{"SPOT", "MARGIN"},
+	})
+	fake.SetBookTicker("LUNCBUSD", "0.00020000", "1000000.00", "0.00020010", "1000000.00")
+	fake.SetAveragePrice("LUNCBUSD", "0.00020005")
+	fake.SetBookTicker("EOSBTC", "0.00006390", "100.0", "0.00006400", "100.0")
+	fake.SetAveragePrice("EOSBTC", "0.00006395")
+	fake.SetBalance("BUSD", "100")
+	fake.SetBalance("LUNC", "0")
+	return fake
+}
+
+func TestFakeExchangeTrade(t *testing.T) {
+	convey.Convey("TestFakeExchangeTrade", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+
+		quote, err := cli.EstQuote(context.Background(), &EstQuoteReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(quote.MinNotional, convey.ShouldEqual, "10.00000000")
+		convCtx.So(quote.Data[0].AskPrice, convey.ShouldEqual, "0.00020010")
+
+		// a NOTIONAL filter without minNotional
+		fake.AddSymbol(binance.Symbol{
+			Symbol: "BTCUSDT", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDT", QuoteAssetPrecision: 8,
+			Filters: []map[string]interface{}{{"filterType": "NOTIONAL", "maxNotional": "9000000.00000000"}},
+		})
+		fake.SetBookTicker("BTCUSDT", "19000.00", "1.0", "19000.01", "1.0")
+		quote, err = NewSpotClientWithExchange(fake).EstQuote(context.Background(), &EstQuoteReq{Symbol: "BTCUSDT"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(quote.MinNotional, convey.ShouldEqual, "0.00000000")
+
+		resp, err := cli.Trade(context.Background(), &TradeReq{
+			Symbol:   "LUNCBUSD",
+			Side:     "BUY",
+			Quantity: "20.01",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(resp.ExecutedQuantity, convey.ShouldEqual, "100000.00000000")
+		convCtx.So(fake.Balance("BUSD").String(), convey.ShouldEqual, "79.99")
+
+		_, err = cli.Trade(context.Background(), &TradeReq{
+			Symbol:   "LUNCBUSD",
+			Side:     "BUY",
+			Quantity: "5",
+		})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		order, err := cli.GetOrder(context.Background(), &GetOrderReq{Symbol: "LUNCBUSD", OrderId: resp.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.CummulativeQuoteQuantity, convey.ShouldEqual, "20.01000000")
+
+		list, err := cli.OrderList(context.Background(), &OrderListReq{Symbol: "LUNCBUSD", Limit: 500})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(list.Data), convey.ShouldEqual, 1)
+	})
+}
+
+func TestFakeExchangeErrors(t *testing.T) {
+	convey.Convey("TestFakeExchangeErrors", t, func(convCtx convey.C) {
+		cli := NewSpotClientWithExchange(newTestFakeExchange())
+
+		_, err := cli.GetOrder(context.Background(), &GetOrderReq{Symbol: "LUNCBUSD", OrderId: 42})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -2013)
+		convCtx.So(errors.Is(err, ErrUnknownOrder), convey.ShouldBeTrue)
+
+		_, err = cli.Trade(context.Background(), &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "1000"})
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -2010)
+		convCtx.So(errors.Is(err, ErrInsufficientBalance), convey.ShouldBeTrue)
+
+		_, err = cli.EstQuote(context.Background(), &EstQuoteReq{Symbol: "NOPEBUSD"})
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -1121)
+		convCtx.So(errors.Is(err, ErrUnknownSymbol), convey.ShouldBeTrue)
+
+		_, err = cli.Trade(context.Background(), &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "1,000"})
+		convCtx.So(errors.Is(err, ErrInvalidDecimal), convey.ShouldBeTrue)
+	})
+}
+
+func TestFakeExchangeWithdraw(t *testing.T) {
+	convey.Convey("TestFakeExchangeWithdraw", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		fake.SetBalance("EOS", "1")
+		cli := NewSpotClientWithExchange(fake)
+
+		resp, err := cli.Withdraw(context.Background(), &WithdrawReq{
+			Coin:            "EOS",
+			Address:         "pursonpurson",
+			Amount:          "0.2",
+			WithdrawOrderId: "withdraw:order:1001",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Id, convey.ShouldNotBeEmpty)
+
+		history, err := cli.WithdrawHistory(context.Background(), &WithdrawHistoryReq{Coin: "EOS"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(history.Data[0].WithdrawOrderID, convey.ShouldEqual, "withdraw:order:1001")
+
+		assets, err := cli.GetUserAsset(context.Background(), &UserAssetReq{Asset: "EOS"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(assets.Data[0].Free, convey.ShouldEqual, "0.80000000")
+	})
+}
+
+func TestFakeExchangeLimitTrade(t *testing.T) {
+	convey.Convey("TestFakeExchangeLimitTrade", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+
+		resp, err := cli.Trade(ctx, &TradeReq{
+			Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000.7", Price: "0.000190005",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Status, convey.ShouldEqual, "NEW")
+		convCtx.So(resp.TimeInForce, convey.ShouldEqual, "GTC")
+		convCtx.So(resp.Price, convey.ShouldEqual, "0.00019")
+		convCtx.So(resp.OrigQuantity, convey.ShouldEqual, "100000")
+		convCtx.So(resp.Dust, convey.ShouldEqual, "0.7")
+
+		resp, err = cli.Trade(ctx, &TradeReq{
+			Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", TimeInForce: "IOC", Quantity: "100000", Price: "0.00019",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Status, convey.ShouldEqual, "EXPIRED")
+
+		resp, err = cli.Trade(ctx, &TradeReq{
+			Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", TimeInForce: "FOK", Quantity: "100000", Price: "0.0002001",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(fake.Balance("LUNC").String(), convey.ShouldEqual, "100000")
+
+		_, err = cli.Trade(ctx, &TradeReq{
+			Symbol: "LUNCBUSD", Side: "SELL", Type: "LIMIT_MAKER", Quantity: "60000", Price: "0.00019",
+		})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -2010)
+
+		resp, err = cli.Trade(ctx, &TradeReq{
+			Symbol: "LUNCBUSD", Side: "SELL", Type: "LIMIT_MAKER", Quantity: "50000", Price: "0.0003",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Status, convey.ShouldEqual, "NEW")
+		convCtx.So(resp.TimeInForce, convey.ShouldEqual, "")
+
+		_, err = cli.Trade(ctx, &TradeReq{
+			Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "10", Price: "0.0002",
+		})
+		convCtx.So(errors.Is(err, ErrFilterViolation), convey.ShouldBeTrue)
+
+		_, err = cli.Trade(ctx, &TradeReq{
+			Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000", Price: "",
+		})
+		convCtx.So(errors.Is(err, ErrInvalidDecimal), convey.ShouldBeTrue)
+	})
+}
+
+func TestFakeExchangeQuantityKind(t *testing.T) {
+	convey.Convey("TestFakeExchangeQuantityKind", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+
+		resp, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "100000", QuantityKind: QuantityKindBase})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.ExecutedQuantity, convey.ShouldEqual, "100000.00000000")
+		convCtx.So(resp.CummulativeQuoteQuantity, convey.ShouldEqual, "20.01000000")
+
+		resp, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "12", QuantityKind: QuantityKindQuote})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.ExecutedQuantity, convey.ShouldEqual, "60000.00000000")
+		convCtx.So(fake.Balance("LUNC").String(), convey.ShouldEqual, "40000")
+
+		resp, err = cli.Trade(ctx, &TradeReq{
+			Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Price: "0.00019", Quantity: "19.0001", QuantityKind: QuantityKindQuote,
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.OrigQuantity, convey.ShouldEqual, "100000")
+
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "40000", QuantityKind: "LOTS"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		// 40000 * 0.00020005 is below MIN_NOTIONAL
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "40000"})
+		convCtx.So(errors.Is(err, ErrFilterViolation), convey.ShouldBeTrue)
+	})
+}
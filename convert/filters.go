@@ -0,0 +1,549 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"strings"
+)
+
+// symbol filter types, see https://binance-docs.github.io/apidocs/spot/en/#filters
+const (
+	FilterTypePriceFilter         = "PRICE_FILTER"
+	FilterTypePercentPrice        = "PERCENT_PRICE"
+	FilterTypePercentPriceBySide  = "PERCENT_PRICE_BY_SIDE"
+	FilterTypeLotSize             = "LOT_SIZE"
+	FilterTypeMarketLotSize       = "MARKET_LOT_SIZE"
+	FilterTypeMinNotional         = "MIN_NOTIONAL"
+	FilterTypeNotional            = "NOTIONAL"
+	FilterTypeIcebergParts        = "ICEBERG_PARTS"
+	FilterTypeMaxNumOrders        = "MAX_NUM_ORDERS"
+	FilterTypeMaxNumAlgoOrders    = "MAX_NUM_ALGO_ORDERS"
+	FilterTypeMaxNumIcebergOrders = "MAX_NUM_ICEBERG_ORDERS"
+	FilterTypeMaxPosition         = "MAX_POSITION"
+	FilterTypeTrailingDelta       = "TRAILING_DELTA"
+)
+
+type PriceFilter struct {
+	MinPrice Decimal `json:"minPrice"`
+	MaxPrice Decimal `json:"maxPrice"`
+	TickSize Decimal `json:"tickSize"`
+}
+
+type PercentPriceFilter struct {
+	MultiplierUp   Decimal `json:"multiplierUp"`
+	MultiplierDown Decimal `json:"multiplierDown"`
+	AvgPriceMins   int     `json:"avgPriceMins"`
+}
+
+type PercentPriceBySideFilter struct {
+	BidMultiplierUp   Decimal `json:"bidMultiplierUp"`
+	BidMultiplierDown Decimal `json:"bidMultiplierDown"`
+	AskMultiplierUp   Decimal `json:"askMultiplierUp"`
+	AskMultiplierDown Decimal `json:"askMultiplierDown"`
+	AvgPriceMins      int     `json:"avgPriceMins"`
+}
+
+// LotSizeFilter used for both LOT_SIZE and MARKET_LOT_SIZE
+type LotSizeFilter struct {
+	MinQty   Decimal `json:"minQty"`
+	MaxQty   Decimal `json:"maxQty"`
+	StepSize Decimal `json:"stepSize"`
+}
+
+type MinNotionalFilter struct {
+	MinNotional   Decimal `json:"minNotional"`
+	ApplyToMarket bool    `json:"applyToMarket"`
+	AvgPriceMins  int     `json:"avgPriceMins"`
+}
+
+type NotionalFilter struct {
+	MinNotional      Decimal `json:"minNotional"`
+	ApplyMinToMarket bool    `json:"applyMinToMarket"`
+	MaxNotional      Decimal `json:"maxNotional"`
+	ApplyMaxToMarket bool    `json:"applyMaxToMarket"`
+	AvgPriceMins     int     `json:"avgPriceMins"`
+}
+
+type IcebergPartsFilter struct {
+	Limit int `json:"limit"`
+}
+
+type MaxNumOrdersFilter struct {
+	MaxNumOrders int `json:"maxNumOrders"`
+}
+
+type MaxNumAlgoOrdersFilter struct {
+	MaxNumAlgoOrders int `json:"maxNumAlgoOrders"`
+}
+
+type MaxNumIcebergOrdersFilter struct {
+	MaxNumIcebergOrders int `json:"maxNumIcebergOrders"`
+}
+
+type MaxPositionFilter struct {
+	MaxPosition Decimal `json:"maxPosition"`
+}
+
+type TrailingDeltaFilter struct {
+	MinTrailingAboveDelta int64 `json:"minTrailingAboveDelta"`
+	MaxTrailingAboveDelta int64 `json:"maxTrailingAboveDelta"`
+	MinTrailingBelowDelta int64 `json:"minTrailingBelowDelta"`
+	MaxTrailingBelowDelta int64 `json:"maxTrailingBelowDelta"`
+}
+
+// SymbolFilters typed exchangeInfo filters of one symbol, nil when the symbol does not have the filter
+type SymbolFilters struct {
+	Price               *PriceFilter
+	PercentPrice        *PercentPriceFilter
+	PercentPriceBySide  *PercentPriceBySideFilter
+	LotSize             *LotSizeFilter
+	MarketLotSize       *LotSizeFilter
+	MinNotional         *MinNotionalFilter
+	Notional            *NotionalFilter
+	IcebergParts        *IcebergPartsFilter
+	MaxNumOrders        *MaxNumOrdersFilter
+	MaxNumAlgoOrders    *MaxNumAlgoOrdersFilter
+	MaxNumIcebergOrders *MaxNumIcebergOrdersFilter
+	MaxPosition         *MaxPositionFilter
+	TrailingDelta       *TrailingDeltaFilter
+}
+
+// ParseSymbolFilters parse binance.Symbol.Filters, unknown filter types are ignored
+func ParseSymbolFilters(filters []map[string]interface{}) (*SymbolFilters, error) {
+	var resp SymbolFilters
+	for _, filter := range filters {
+		filterType, _ := filter["filterType"].(string)
+		var target interface{}
+		switch filterType {
+		case FilterTypePriceFilter:
+			resp.Price = new(PriceFilter)
+			target = resp.Price
+		case FilterTypePercentPrice:
+			resp.PercentPrice = new(PercentPriceFilter)
+			target = resp.PercentPrice
+		case FilterTypePercentPriceBySide:
+			resp.PercentPriceBySide = new(PercentPriceBySideFilter)
+			target = resp.PercentPriceBySide
+		case FilterTypeLotSize:
+			resp.LotSize = new(LotSizeFilter)
+			target = resp.LotSize
+		case FilterTypeMarketLotSize:
+			resp.MarketLotSize = new(LotSizeFilter)
+			target = resp.MarketLotSize
+		case FilterTypeMinNotional:
+			resp.MinNotional = new(MinNotionalFilter)
+			target = resp.MinNotional
+		case FilterTypeNotional:
+			resp.Notional = new(NotionalFilter)
+			target = resp.Notional
+		case FilterTypeIcebergParts:
+			resp.IcebergParts = new(IcebergPartsFilter)
+			target = resp.IcebergParts
+		case FilterTypeMaxNumOrders:
+			resp.MaxNumOrders = new(MaxNumOrdersFilter)
+			target = resp.MaxNumOrders
+		case FilterTypeMaxNumAlgoOrders:
+			resp.MaxNumAlgoOrders = new(MaxNumAlgoOrdersFilter)
+			target = resp.MaxNumAlgoOrders
+		case FilterTypeMaxNumIcebergOrders:
+			resp.MaxNumIcebergOrders = new(MaxNumIcebergOrdersFilter)
+			target = resp.MaxNumIcebergOrders
+		case FilterTypeMaxPosition:
+			resp.MaxPosition = new(MaxPositionFilter)
+			target = resp.MaxPosition
+		case FilterTypeTrailingDelta:
+			resp.TrailingDelta = new(TrailingDeltaFilter)
+			target = resp.TrailingDelta
+		default:
+			continue
+		}
+		// the generic map holds strings for decimals and float64 for integers, a json round trip types both
+		data, err := json.Marshal(filter)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, target); err != nil {
+			return nil, fmt.Errorf("filter %s %w", filterType, err)
+		}
+	}
+	return &resp, nil
+}
+
+// OrderCheck a proposed order, zero values mean "not set"
+type OrderCheck struct {
+	Side          binance.SideType
+	Type          binance.OrderType
+	Quantity      Decimal
+	QuoteOrderQty Decimal
+	Price         Decimal
+	StopPrice     Decimal
+	IcebergQty    Decimal
+	TrailingDelta int64
+	// AvgPrice is the reference price for market notional and percent price checks
+	AvgPrice Decimal
+	// account state for MAX_NUM_* and MAX_POSITION, including nothing of the proposed order
+	OpenOrders        int
+	OpenAlgoOrders    int
+	OpenIcebergOrders int
+	// Position is the base asset held (free and locked) plus the unfilled rest of the open BUY orders
+	Position Decimal
+}
+
+// FilterViolation one failed filter rule, Field is the order field that broke it
+type FilterViolation struct {
+	Filter string `json:"filter"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Limit  string `json:"limit"`
+	Reason string `json:"reason"`
+}
+
+func (v FilterViolation) Error() string {
+	return fmt.Sprintf("Filter failure: %s (%s %s %s %s)", v.Filter, v.Field, v.Value, v.Reason, v.Limit)
+}
+
+// FilterViolations every violation found for an order, returned as error by Trade
+type FilterViolations []FilterViolation
+
+func (v FilterViolations) Error() string {
+	var msgs []string
+	for _, violation := range v {
+		msgs = append(msgs, violation.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Has true when filter is violated
+func (v FilterViolations) Has(filter string) bool {
+	for _, violation := range v {
+		if violation.Filter == filter {
+			return true
+		}
+	}
+	return false
+}
+
+var ErrFilterViolation = errors.New("filter violation")
+
+func (v FilterViolations) Is(target error) bool {
+	return target == ErrFilterViolation
+}
+
+func isAlgoOrder(orderType binance.OrderType) bool {
+	switch orderType {
+	case binance.OrderTypeStopLoss, binance.OrderTypeStopLossLimit, binance.OrderTypeTakeProfit, binance.OrderTypeTakeProfitLimit:
+		return true
+	}
+	return false
+}
+
+func isMarketOrder(orderType binance.OrderType) bool {
+	switch orderType {
+	case binance.OrderTypeMarket, binance.OrderTypeStopLoss, binance.OrderTypeTakeProfit:
+		return true
+	}
+	return false
+}
+
+// Validate check o against every filter, nil when the order passes
+func (f *SymbolFilters) Validate(o *OrderCheck) FilterViolations {
+	var violations FilterViolations
+	add := func(filter, field string, value Decimal, reason string, limit Decimal) {
+		violations = append(violations, FilterViolation{
+			Filter: filter,
+			Field:  field,
+			Value:  value.String(),
+			Limit:  limit.String(),
+			Reason: reason,
+		})
+	}
+	market := isMarketOrder(o.Type)
+
+	if p := f.Price; p != nil {
+		for _, field := range []struct {
+			name  string
+			value Decimal
+		}{{"price", o.Price}, {"stopPrice", o.StopPrice}} {
+			if field.value.IsZero() {
+				continue
+			}
+			if p.MinPrice.Sign() > 0 && field.value.LessThan(p.MinPrice) {
+				add(FilterTypePriceFilter, field.name, field.value, "<", p.MinPrice)
+			}
+			if p.MaxPrice.Sign() > 0 && field.value.GreaterThan(p.MaxPrice) {
+				add(FilterTypePriceFilter, field.name, field.value, ">", p.MaxPrice)
+			}
+			if p.TickSize.Sign() > 0 && !isMultiple(field.value.Sub(p.MinPrice), p.TickSize) {
+				add(FilterTypePriceFilter, field.name, field.value, "not a multiple of tickSize", p.TickSize)
+			}
+		}
+	}
+
+	// stop orders are checked on their stopPrice too, it becomes the trading price once triggered
+	for _, field := range []struct {
+		name  string
+		value Decimal
+	}{{"price", o.Price}, {"stopPrice", o.StopPrice}} {
+		if field.value.IsZero() || o.AvgPrice.Sign() <= 0 {
+			continue
+		}
+		if p := f.PercentPrice; p != nil {
+			if up := o.AvgPrice.Mul(p.MultiplierUp); p.MultiplierUp.Sign() > 0 && field.value.GreaterThan(up) {
+				add(FilterTypePercentPrice, field.name, field.value, "> avgPrice * multiplierUp", up)
+			}
+			if down := o.AvgPrice.Mul(p.MultiplierDown); field.value.LessThan(down) {
+				add(FilterTypePercentPrice, field.name, field.value, "< avgPrice * multiplierDown", down)
+			}
+		}
+		if p := f.PercentPriceBySide; p != nil {
+			multiplierUp, multiplierDown := p.BidMultiplierUp, p.BidMultiplierDown
+			if o.Side == binance.SideTypeSell {
+				multiplierUp, multiplierDown = p.AskMultiplierUp, p.AskMultiplierDown
+			}
+			if up := o.AvgPrice.Mul(multiplierUp); multiplierUp.Sign() > 0 && field.value.GreaterThan(up) {
+				add(FilterTypePercentPriceBySide, field.name, field.value, "> avgPrice * multiplierUp", up)
+			}
+			if down := o.AvgPrice.Mul(multiplierDown); field.value.LessThan(down) {
+				add(FilterTypePercentPriceBySide, field.name, field.value, "< avgPrice * multiplierDown", down)
+			}
+		}
+	}
+
+	if !o.Quantity.IsZero() {
+		lotSizes := map[string]*LotSizeFilter{FilterTypeLotSize: f.LotSize}
+		if market {
+			lotSizes[FilterTypeMarketLotSize] = f.MarketLotSize
+		}
+		for _, filter := range []string{FilterTypeLotSize, FilterTypeMarketLotSize} {
+			l := lotSizes[filter]
+			if l == nil {
+				continue
+			}
+			if o.Quantity.LessThan(l.MinQty) {
+				add(filter, "quantity", o.Quantity, "<", l.MinQty)
+			}
+			if l.MaxQty.Sign() > 0 && o.Quantity.GreaterThan(l.MaxQty) {
+				add(filter, "quantity", o.Quantity, ">", l.MaxQty)
+			}
+			if l.StepSize.Sign() > 0 && !isMultiple(o.Quantity.Sub(l.MinQty), l.StepSize) {
+				add(filter, "quantity", o.Quantity, "not a multiple of stepSize", l.StepSize)
+			}
+		}
+	}
+
+	if notional, ok := orderNotional(o); ok {
+		if m := f.MinNotional; m != nil && (!market || m.ApplyToMarket) && notional.LessThan(m.MinNotional) {
+			add(FilterTypeMinNotional, "notional", notional, "<", m.MinNotional)
+		}
+		if n := f.Notional; n != nil {
+			if (!market || n.ApplyMinToMarket) && notional.LessThan(n.MinNotional) {
+				add(FilterTypeNotional, "notional", notional, "<", n.MinNotional)
+			}
+			if (!market || n.ApplyMaxToMarket) && n.MaxNotional.Sign() > 0 && notional.GreaterThan(n.MaxNotional) {
+				add(FilterTypeNotional, "notional", notional, ">", n.MaxNotional)
+			}
+		}
+	}
+
+	if !o.IcebergQty.IsZero() {
+		if i := f.IcebergParts; i != nil && !o.Quantity.IsZero() {
+			parts := o.Quantity.Div(o.IcebergQty, 0)
+			if !parts.Mul(o.IcebergQty).Equal(o.Quantity) {
+				parts = parts.Add(NewDecimalFromInt(1))
+			}
+			if limit := NewDecimalFromInt(int64(i.Limit)); parts.GreaterThan(limit) {
+				add(FilterTypeIcebergParts, "icebergQty", o.IcebergQty, "splits quantity into more parts than", limit)
+			}
+		}
+		if m := f.MaxNumIcebergOrders; m != nil && o.OpenIcebergOrders+1 > m.MaxNumIcebergOrders {
+			add(FilterTypeMaxNumIcebergOrders, "openIcebergOrders", NewDecimalFromInt(int64(o.OpenIcebergOrders)), "+ 1 >", NewDecimalFromInt(int64(m.MaxNumIcebergOrders)))
+		}
+	}
+
+	if m := f.MaxNumOrders; m != nil && o.OpenOrders+1 > m.MaxNumOrders {
+		add(FilterTypeMaxNumOrders, "openOrders", NewDecimalFromInt(int64(o.OpenOrders)), "+ 1 >", NewDecimalFromInt(int64(m.MaxNumOrders)))
+	}
+	if m := f.MaxNumAlgoOrders; m != nil && isAlgoOrder(o.Type) && o.OpenAlgoOrders+1 > m.MaxNumAlgoOrders {
+		add(FilterTypeMaxNumAlgoOrders, "openAlgoOrders", NewDecimalFromInt(int64(o.OpenAlgoOrders)), "+ 1 >", NewDecimalFromInt(int64(m.MaxNumAlgoOrders)))
+	}
+
+	if m := f.MaxPosition; m != nil && o.Side == binance.SideTypeBuy {
+		quantity := o.Quantity
+		if quantity.IsZero() && !o.QuoteOrderQty.IsZero() && o.AvgPrice.Sign() > 0 {
+			quantity = o.QuoteOrderQty.Div(o.AvgPrice, 8)
+		}
+		if position := o.Position.Add(quantity); position.GreaterThan(m.MaxPosition) {
+			add(FilterTypeMaxPosition, "position", position, ">", m.MaxPosition)
+		}
+	}
+
+	if t := f.TrailingDelta; t != nil && o.TrailingDelta != 0 {
+		// stop orders above the market (BUY STOP_LOSS, SELL TAKE_PROFIT) use the above delta range
+		above := (o.Side == binance.SideTypeBuy) == (o.Type == binance.OrderTypeStopLoss || o.Type == binance.OrderTypeStopLossLimit)
+		minDelta, maxDelta, name := t.MinTrailingBelowDelta, t.MaxTrailingBelowDelta, "trailingDelta below"
+		if above {
+			minDelta, maxDelta, name = t.MinTrailingAboveDelta, t.MaxTrailingAboveDelta, "trailingDelta above"
+		}
+		delta := NewDecimalFromInt(o.TrailingDelta)
+		if o.TrailingDelta < minDelta {
+			add(FilterTypeTrailingDelta, name, delta, "<", NewDecimalFromInt(minDelta))
+		}
+		if maxDelta > 0 && o.TrailingDelta > maxDelta {
+			add(FilterTypeTrailingDelta, name, delta, ">", NewDecimalFromInt(maxDelta))
+		}
+	}
+
+	return violations
+}
+
+// orderNotional price * quantity, market orders use the stop price, the average price or the quote quantity
+func orderNotional(o *OrderCheck) (Decimal, bool) {
+	if !o.QuoteOrderQty.IsZero() {
+		return o.QuoteOrderQty, true
+	}
+	if o.Quantity.IsZero() {
+		return Zero, false
+	}
+	price := o.Price
+	if isMarketOrder(o.Type) || price.IsZero() {
+		price = o.AvgPrice
+		if !o.StopPrice.IsZero() {
+			price = o.StopPrice
+		}
+	}
+	if price.IsZero() {
+		return Zero, false
+	}
+	return o.Quantity.Mul(price), true
+}
+
+func isMultiple(value, step Decimal) bool {
+	q := value.Div(step, 0)
+	return q.Mul(step).Equal(value)
+}
+
+type ValidateOrderReq struct {
+	Symbol        string            `json:"symbol"`
+	Side          binance.SideType  `json:"side"`
+	Type          binance.OrderType `json:"type"`
+	Quantity      string            `json:"quantity"`
+	QuoteOrderQty string            `json:"quoteOrderQty"`
+	Price         string            `json:"price"`
+	StopPrice     string            `json:"stopPrice"`
+	IcebergQty    string            `json:"icebergQty"`
+	TrailingDelta int64             `json:"trailingDelta"`
+}
+
+type ValidateOrderResp struct {
+	Valid      bool             `json:"valid"`
+	Violations FilterViolations `json:"violations"`
+}
+
+// ValidateOrder check an order against every symbol filter without placing it,
+// open orders and the base asset balance are fetched for MAX_NUM_* and MAX_POSITION
+func (c *SpotClient) ValidateOrder(ctx context.Context, req *ValidateOrderReq) (*ValidateOrderResp, error) {
+	symbol, err := c.registry.Symbol(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	filters := symbol.Filters
+
+	check := &OrderCheck{Side: req.Side, Type: req.Type, TrailingDelta: req.TrailingDelta}
+	for _, field := range []struct {
+		name   string
+		value  string
+		target *Decimal
+	}{
+		{"quantity", req.Quantity, &check.Quantity},
+		{"quoteOrderQty", req.QuoteOrderQty, &check.QuoteOrderQty},
+		{"price", req.Price, &check.Price},
+		{"stopPrice", req.StopPrice, &check.StopPrice},
+		{"icebergQty", req.IcebergQty, &check.IcebergQty},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if *field.target, err = ParseDecimal(field.value); err != nil {
+			return nil, fmt.Errorf("%s %w", field.name, err)
+		}
+	}
+
+	avgPrice, err := c.exchange.AveragePrice(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if check.AvgPrice, err = ParseDecimal(avgPrice.Price); err != nil {
+		return nil, fmt.Errorf("avgPrice %w", err)
+	}
+
+	if err = c.accountCheck(ctx, symbol, check); err != nil {
+		return nil, err
+	}
+
+	violations := filters.Validate(check)
+	return &ValidateOrderResp{Valid: len(violations) == 0, Violations: violations}, nil
+}
+
+// accountCheck fill the open order counts and base asset position of check,
+// each fetched only when symbol has a MAX_NUM_* or MAX_POSITION filter to check them against
+func (c *SpotClient) accountCheck(ctx context.Context, symbol *SymbolInfo, check *OrderCheck) error {
+	filters := symbol.Filters
+	var openOrders []*binance.Order
+	if filters.MaxNumOrders != nil || filters.MaxNumAlgoOrders != nil || filters.MaxNumIcebergOrders != nil || filters.MaxPosition != nil {
+		var err error
+		if openOrders, err = c.exchange.ListOpenOrders(ctx, symbol.Symbol); err != nil {
+			return err
+		}
+		check.OpenOrders, check.OpenAlgoOrders, check.OpenIcebergOrders = 0, 0, 0
+		for _, order := range openOrders {
+			check.OpenOrders++
+			if isAlgoOrder(order.Type) {
+				check.OpenAlgoOrders++
+			}
+			if order.IcebergQuantity != "" {
+				if icebergQty, err := ParseDecimal(order.IcebergQuantity); err == nil && !icebergQty.IsZero() {
+					check.OpenIcebergOrders++
+				}
+			}
+		}
+	}
+
+	if filters.MaxPosition != nil {
+		assets, err := c.exchange.UserAsset(ctx, symbol.BaseAsset)
+		if err != nil {
+			return err
+		}
+		check.Position = Zero
+		for _, asset := range assets {
+			for _, amount := range []string{asset.Free, asset.Locked} {
+				if amount == "" {
+					continue
+				}
+				dAmount, err := ParseDecimal(amount)
+				if err != nil {
+					return fmt.Errorf("%s balance %w", asset.Asset, err)
+				}
+				check.Position = check.Position.Add(dAmount)
+			}
+		}
+		// binance counts what the open buy orders may still buy as held
+		for _, order := range openOrders {
+			if order.Side != binance.SideTypeBuy {
+				continue
+			}
+			origQty, err := ParseDecimal(order.OrigQuantity)
+			if err != nil {
+				return fmt.Errorf("order %d origQty %w", order.OrderID, err)
+			}
+			executedQty, err := ParseDecimal(order.ExecutedQuantity)
+			if err != nil {
+				return fmt.Errorf("order %d executedQty %w", order.OrderID, err)
+			}
+			check.Position = check.Position.Add(origQty.Sub(executedQty))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+var testFilters = []map[string]interface{}{
+	{"filterType": "PRICE_FILTER", "minPrice": "0.01000000", "maxPrice": "1000000.00000000", "tickSize": "0.01000000"},
+	{"filterType": "LOT_SIZE", "minQty": "0.00010000", "maxQty": "9000.00000000", "stepSize": "0.00010000"},
+	{"filterType": "MARKET_LOT_SIZE", "minQty": "0.00000000", "maxQty": "100.00000000", "stepSize": "0.00000000"},
+	{"filterType": "PERCENT_PRICE_BY_SIDE", "bidMultiplierUp": "5", "bidMultiplierDown": "0.2", "askMultiplierUp": "5", "askMultiplierDown": "0.2", "avgPriceMins": float64(5)},
+	{"filterType": "NOTIONAL", "minNotional": "5.00000000", "applyMinToMarket": true, "maxNotional": "9000000.00000000", "applyMaxToMarket": false, "avgPriceMins": float64(5)},
+	{"filterType": "ICEBERG_PARTS", "limit": float64(10)},
+	{"filterType": "MAX_NUM_ORDERS", "maxNumOrders": float64(200)},
+	{"filterType": "MAX_NUM_ALGO_ORDERS", "maxNumAlgoOrders": float64(5)},
+	{"filterType": "MAX_POSITION", "maxPosition": "10.00000000"},
+	{"filterType": "TRAILING_DELTA", "minTrailingAboveDelta": float64(10), "maxTrailingAboveDelta": float64(2000), "minTrailingBelowDelta": float64(10), "maxTrailingBelowDelta": float64(2000)},
+}
+
+func TestParseSymbolFilters(t *testing.T) {
+	convey.Convey("TestParseSymbolFilters", t, func(convCtx convey.C) {
+		filters, err := ParseSymbolFilters(testFilters)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(filters.Price.TickSize.String(), convey.ShouldEqual, "0.01")
+		convCtx.So(filters.LotSize.StepSize.String(), convey.ShouldEqual, "0.0001")
+		convCtx.So(filters.MarketLotSize.MaxQty.String(), convey.ShouldEqual, "100")
+		convCtx.So(filters.PercentPriceBySide.AskMultiplierDown.String(), convey.ShouldEqual, "0.2")
+		convCtx.So(filters.Notional.ApplyMinToMarket, convey.ShouldBeTrue)
+		convCtx.So(filters.IcebergParts.Limit, convey.ShouldEqual, 10)
+		convCtx.So(filters.MaxNumOrders.MaxNumOrders, convey.ShouldEqual, 200)
+		convCtx.So(filters.MaxPosition.MaxPosition.String(), convey.ShouldEqual, "10")
+		convCtx.So(filters.TrailingDelta.MaxTrailingBelowDelta, convey.ShouldEqual, 2000)
+		convCtx.So(filters.MinNotional, convey.ShouldBeNil)
+
+		_, err = ParseSymbolFilters([]map[string]interface{}{{"filterType": "LOT_SIZE", "minQty": "abc"}})
+		convCtx.So(errors.Is(err, ErrInvalidDecimal), convey.ShouldBeTrue)
+	})
+}
+
+func TestSymbolFiltersValidate(t *testing.T) {
+	convey.Convey("TestSymbolFiltersValidate", t, func(convCtx convey.C) {
+		filters, _ := ParseSymbolFilters(testFilters)
+		avgPrice := MustDecimal("20000")
+
+		violations := filters.Validate(&OrderCheck{
+			Side: binance.SideTypeBuy, Type: binance.OrderTypeLimit,
+			Quantity: MustDecimal("0.001"), Price: MustDecimal("20000.01"), AvgPrice: avgPrice,
+		})
+		convCtx.So(violations, convey.ShouldBeEmpty)
+
+		violations = filters.Validate(&OrderCheck{
+			Side: binance.SideTypeBuy, Type: binance.OrderTypeLimit,
+			Quantity: MustDecimal("0.00035"), Price: MustDecimal("20000.001"), AvgPrice: avgPrice,
+		})
+		convCtx.So(violations.Has(FilterTypeLotSize), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypePriceFilter), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeNotional), convey.ShouldBeFalse)
+
+		violations = filters.Validate(&OrderCheck{
+			Side: binance.SideTypeSell, Type: binance.OrderTypeLimit,
+			Quantity: MustDecimal("0.0001"), Price: MustDecimal("3000"), AvgPrice: avgPrice,
+		})
+		convCtx.So(violations.Has(FilterTypePercentPriceBySide), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeNotional), convey.ShouldBeTrue)
+
+		violations = filters.Validate(&OrderCheck{
+			Side: binance.SideTypeBuy, Type: binance.OrderTypeMarket,
+			Quantity: MustDecimal("200"), AvgPrice: avgPrice,
+		})
+		convCtx.So(violations.Has(FilterTypeMarketLotSize), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeMaxPosition), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeNotional), convey.ShouldBeFalse)
+
+		// a stop loss triggers into a market order, so MARKET_LOT_SIZE applies too
+		violations = filters.Validate(&OrderCheck{
+			Side: binance.SideTypeSell, Type: binance.OrderTypeStopLoss,
+			Quantity: MustDecimal("200"), StopPrice: MustDecimal("19000"), AvgPrice: avgPrice,
+		})
+		convCtx.So(violations.Has(FilterTypeMarketLotSize), convey.ShouldBeTrue)
+
+		violations = filters.Validate(&OrderCheck{
+			Side: binance.SideTypeSell, Type: binance.OrderTypeStopLossLimit,
+			Quantity: MustDecimal("1"), Price: MustDecimal("19000"), StopPrice: MustDecimal("19000"),
+			IcebergQty: MustDecimal("0.05"), TrailingDelta: 5, AvgPrice: avgPrice,
+			OpenOrders: 200, OpenAlgoOrders: 5,
+		})
+		convCtx.So(violations.Has(FilterTypeIcebergParts), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeMaxNumOrders), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeMaxNumAlgoOrders), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeTrailingDelta), convey.ShouldBeTrue)
+
+		var err error = violations
+		convCtx.So(errors.Is(err, ErrFilterViolation), convey.ShouldBeTrue)
+	})
+}
+
+func TestValidateOrder(t *testing.T) {
+	convey.Convey("TestValidateOrder", t, func(convCtx convey.C) {
+		cli := NewSpotClientWithExchange(newTestFakeExchange())
+
+		resp, err := cli.ValidateOrder(context.Background(), &ValidateOrderReq{
+			Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000", Price: "0.00019000",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Valid, convey.ShouldBeTrue)
+
+		resp, err = cli.ValidateOrder(context.Background(), &ValidateOrderReq{
+			Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100.5", Price: "0.00019000",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Valid, convey.ShouldBeFalse)
+		convCtx.So(resp.Violations.Has(FilterTypeLotSize), convey.ShouldBeTrue)
+		convCtx.So(resp.Violations.Has(FilterTypeMinNotional), convey.ShouldBeTrue)
+
+		_, err = cli.Trade(context.Background(), &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "100"})
+		var violations FilterViolations
+		convCtx.So(errors.As(err, &violations), convey.ShouldBeTrue)
+		convCtx.So(violations[0].Filter, convey.ShouldEqual, FilterTypeMinNotional)
+	})
+}
+
+func TestTradeAccountFilters(t *testing.T) {
+	convey.Convey("TestTradeAccountFilters", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		lunc, err := fake.symbol("LUNCBUSD")
+		convCtx.So(err, convey.ShouldBeNil)
+		var filters []map[string]interface{}
+		for _, filter := range lunc.Filters {
+			if filter["filterType"] != "MAX_NUM_ORDERS" {
+				filters = append(filters, filter)
+			}
+		}
+		lunc.Filters = append(filters,
+			map[string]interface{}{"filterType": "MAX_NUM_ORDERS", "maxNumOrders": float64(2)},
+			map[string]interface{}{"filterType": "MAX_POSITION", "maxPosition": "155000.00000000"},
+		)
+		fake.AddSymbol(lunc)
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+
+		first, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "60000", Price: "0.00019"})
+		convCtx.So(err, convey.ShouldBeNil)
+
+		// the limit leg already fills the second slot
+		_, err = cli.CreateOCO(ctx, &OCOReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "100000", Price: "0.00025", StopPrice: "0.00015", StopLimitPrice: "0.00015"})
+		var violations FilterViolations
+		convCtx.So(errors.As(err, &violations), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeMaxNumOrders), convey.ShouldBeTrue)
+
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "60000", Price: "0.00019"})
+		convCtx.So(err, convey.ShouldBeNil)
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "60000", Price: "0.00019"})
+		convCtx.So(errors.Is(err, ErrFilterViolation), convey.ShouldBeTrue)
+		convCtx.So(errors.As(err, &violations), convey.ShouldBeTrue)
+		convCtx.So(violations[0].Filter, convey.ShouldEqual, FilterTypeMaxNumOrders)
+		_, err = cli.StopLoss(ctx, &ConditionalOrderReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "60000", Price: "0.00018", StopPrice: "0.00018"})
+		convCtx.So(errors.As(err, &violations), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeMaxNumOrders), convey.ShouldBeTrue)
+		orders, err := fake.ListOpenOrders(ctx, "LUNCBUSD")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(orders), convey.ShouldEqual, 2)
+
+		// a free slot, but the LUNC held and the open buy order plus the order exceed MAX_POSITION
+		_, err = cli.CancelOrder(ctx, &CancelReq{Symbol: "LUNCBUSD", OrderId: first.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		fake.SetBalance("LUNC", "40000")
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "60000", Price: "0.00019"})
+		convCtx.So(errors.As(err, &violations), convey.ShouldBeTrue)
+		convCtx.So(violations[0].Filter, convey.ShouldEqual, FilterTypeMaxPosition)
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "55000", Price: "0.00019"})
+		convCtx.So(err, convey.ShouldBeNil)
+	})
+}
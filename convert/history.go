@@ -0,0 +1,318 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"sort"
+	"time"
+)
+
+/*
+历史订单 / 成交分页:
+
+allOrders 和 myTrades 每次最多返回 1000 条, startTime 到 endTime 最多 24 小时,
+超出范围时 binance 只返回其中一部分 (哪一部分没有文档保证).
+按时间查询时把 [StartTime, EndTime] 切成 24 小时的窗口, 一个窗口返回满 Limit 条就把窗口二分再查,
+直到每个窗口都没有被截断, 所以结果不重不漏, 与返回最旧还是最新的一页无关.
+同一毫秒内超过 Limit 条时改用 fromId (订单是 orderId) 从上一条已返回的 id 往后翻页, 还没有返回过时从这一页最小的 id 开始.
+指定 FromId 时直接按 id 往后翻页, 到 EndTime 为止, 早于 StartTime 的丢弃.
+myTrades 不支持 orderId 和 startTime / endTime 一起用, 指定 OrderId 时总是按 orderId + fromId 翻页, 在本地按时间过滤.
+*/
+
+const (
+	historyMaxLimit = 1000
+	historyWindow   = 24 * time.Hour
+)
+
+// HistoryReq orders or fills of Symbol in [StartTime, EndTime], or from FromId on
+type HistoryReq struct {
+	Symbol    string `json:"symbol"`
+	OrderId   int64  `json:"orderId"`   // fills of this order only, trades only
+	FromId    int64  `json:"fromId"`    // first orderId / trade id, instead of StartTime
+	StartTime int64  `json:"startTime"` // ms, inclusive
+	EndTime   int64  `json:"endTime"`   // ms, inclusive, default now
+	Limit     int    `json:"limit"`     // rows per request, default and at most 1000
+}
+
+// historyRow an order or a fill, ordered by id
+type historyRow struct {
+	id    int64
+	time  int64
+	value interface{}
+}
+
+// historyFetch one request, fromId > 0 pages by id and leaves the times out
+type historyFetch func(ctx context.Context, fromId, startTime, endTime int64, limit int) ([]historyRow, error)
+
+type historyWindowRange struct {
+	start, end int64
+}
+
+// historyPager walks a HistoryReq one request at a time
+type historyPager struct {
+	req     HistoryReq
+	fetch   historyFetch
+	windows []historyWindowRange // time windows left, in order
+	fromId  int64                // next id when paging by id
+	idUntil int64                // paging by id stops after this time
+	lastId  int64                // id of the last row returned
+	rows    []historyRow
+	done    bool
+	err     error
+}
+
+func newHistoryPager(req *HistoryReq, now time.Time, fetch historyFetch) (*historyPager, error) {
+	if req.Symbol == "" {
+		return nil, errors.New("symbol is required")
+	}
+	if req.Limit < 0 || req.Limit > historyMaxLimit {
+		return nil, errors.New(fmt.Sprintf("limit %d out of range 1-%d", req.Limit, historyMaxLimit))
+	}
+	p := &historyPager{req: *req, fetch: fetch}
+	if p.req.Limit == 0 {
+		p.req.Limit = historyMaxLimit
+	}
+	if p.req.EndTime == 0 {
+		p.req.EndTime = now.UnixNano() / int64(time.Millisecond)
+	}
+	if p.req.StartTime > p.req.EndTime {
+		return nil, errors.New(fmt.Sprintf("startTime %d after endTime %d", p.req.StartTime, p.req.EndTime))
+	}
+	if p.req.FromId > 0 {
+		p.fromId, p.idUntil = p.req.FromId, p.req.EndTime
+		return p, nil
+	}
+	if p.req.StartTime == 0 {
+		return nil, errors.New("startTime or fromId is required")
+	}
+	window := historyWindow.Milliseconds()
+	for start := p.req.StartTime; start <= p.req.EndTime; start += window {
+		end := start + window - 1
+		if end > p.req.EndTime {
+			end = p.req.EndTime
+		}
+		p.windows = append(p.windows, historyWindowRange{start: start, end: end})
+	}
+	return p, nil
+}
+
+// next the next row, false when there are no more rows or a request failed
+func (p *historyPager) next(ctx context.Context) (historyRow, bool) {
+	for len(p.rows) == 0 {
+		if p.done || p.err != nil {
+			return historyRow{}, false
+		}
+		if p.err = p.load(ctx); p.err != nil {
+			return historyRow{}, false
+		}
+	}
+	row := p.rows[0]
+	p.rows = p.rows[1:]
+	p.lastId = row.id
+	return row, true
+}
+
+// load fetch the next page into rows
+func (p *historyPager) load(ctx context.Context) error {
+	if p.fromId > 0 {
+		rows, err := p.fetch(ctx, p.fromId, 0, 0, p.req.Limit)
+		if err != nil {
+			return err
+		}
+		sortHistoryRows(rows)
+		for _, row := range rows {
+			if row.time > p.idUntil {
+				p.fromId = 0
+				break
+			}
+			if row.time >= p.req.StartTime {
+				p.rows = append(p.rows, row)
+			}
+			p.fromId = row.id + 1
+		}
+		if len(rows) < p.req.Limit {
+			p.fromId = 0
+		}
+		if p.fromId == 0 && len(p.windows) == 0 {
+			p.done = true
+		}
+		return nil
+	}
+	if len(p.windows) == 0 {
+		p.done = true
+		return nil
+	}
+
+	window := p.windows[0]
+	rows, err := p.fetch(ctx, 0, window.start, window.end, p.req.Limit)
+	if err != nil {
+		return err
+	}
+	if len(rows) < p.req.Limit {
+		p.windows = p.windows[1:]
+		sortHistoryRows(rows)
+		p.rows = rows
+		return nil
+	}
+	// truncated: split the window, or page a single crowded millisecond by id
+	if window.end > window.start {
+		mid := window.start + (window.end-window.start)/2
+		p.windows = append([]historyWindowRange{{start: window.start, end: mid}, {start: mid + 1, end: window.end}}, p.windows[1:]...)
+		return nil
+	}
+	// the page may hold either end of the millisecond: continue after the last row returned,
+	// which leaves nothing out, or from the first row of the page when none was returned yet
+	// instead of scanning the account's history from id 1
+	p.windows = p.windows[1:]
+	sortHistoryRows(rows)
+	p.fromId, p.idUntil = rows[0].id, window.end
+	if p.lastId > 0 && p.lastId < rows[0].id {
+		p.fromId = p.lastId + 1
+	}
+	return nil
+}
+
+func sortHistoryRows(rows []historyRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].id < rows[j].id
+	})
+}
+
+// OrderIterator streams the orders of an OrderHistory:
+//
+//	for it.Next(ctx) { order := it.Order() }
+//	if err := it.Err(); err != nil { ... }
+type OrderIterator struct {
+	pager *historyPager
+	order *binance.Order
+}
+
+// OrderHistory every order of req.Symbol created in the range, oldest first. OrderId is ignored
+func (c *SpotClient) OrderHistory(req *HistoryReq) (*OrderIterator, error) {
+	pager, err := newHistoryPager(req, time.Now(), func(ctx context.Context, fromId, startTime, endTime int64, limit int) ([]historyRow, error) {
+		orders, err := c.exchange.ListOrders(ctx, &ListOrdersParams{Symbol: req.Symbol, OrderId: fromId, StartTime: startTime, EndTime: endTime, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]historyRow, 0, len(orders))
+		for _, order := range orders {
+			rows = append(rows, historyRow{id: order.OrderID, time: order.Time, value: order})
+		}
+		return rows, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &OrderIterator{pager: pager}, nil
+}
+
+// Next advance to the next order, false at the end or on an error
+func (it *OrderIterator) Next(ctx context.Context) bool {
+	row, ok := it.pager.next(ctx)
+	if !ok {
+		it.order = nil
+		return false
+	}
+	it.order = row.value.(*binance.Order)
+	return true
+}
+
+// Order the current order
+func (it *OrderIterator) Order() *binance.Order {
+	return it.order
+}
+
+// Err the error that stopped Next, nil at the end
+func (it *OrderIterator) Err() error {
+	return it.pager.err
+}
+
+// TradeIterator streams the fills of a TradeHistory, used like OrderIterator
+type TradeIterator struct {
+	pager *historyPager
+	trade *binance.TradeV3
+}
+
+// TradeHistory every fill of req.Symbol (of req.OrderId when set) in the range, oldest first
+func (c *SpotClient) TradeHistory(req *HistoryReq) (*TradeIterator, error) {
+	if req.OrderId > 0 && req.FromId == 0 {
+		// myTrades rejects orderId with startTime / endTime, the fills of an order are paged by id
+		byOrder := *req
+		byOrder.FromId = 1
+		req = &byOrder
+	}
+	pager, err := newHistoryPager(req, time.Now(), func(ctx context.Context, fromId, startTime, endTime int64, limit int) ([]historyRow, error) {
+		trades, err := c.exchange.ListTrades(ctx, &ListTradesParams{Symbol: req.Symbol, OrderId: req.OrderId, FromId: fromId, StartTime: startTime, EndTime: endTime, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]historyRow, 0, len(trades))
+		for _, trade := range trades {
+			rows = append(rows, historyRow{id: trade.ID, time: trade.Time, value: trade})
+		}
+		return rows, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TradeIterator{pager: pager}, nil
+}
+
+// Next advance to the next fill, false at the end or on an error
+func (it *TradeIterator) Next(ctx context.Context) bool {
+	row, ok := it.pager.next(ctx)
+	if !ok {
+		it.trade = nil
+		return false
+	}
+	it.trade = row.value.(*binance.TradeV3)
+	return true
+}
+
+// Trade the current fill
+func (it *TradeIterator) Trade() *binance.TradeV3 {
+	return it.trade
+}
+
+// Err the error that stopped Next, nil at the end
+func (it *TradeIterator) Err() error {
+	return it.pager.err
+}
+
+// AllOrders collect an OrderHistory
+func (c *SpotClient) AllOrders(ctx context.Context, req *HistoryReq) (*OrderListResp, error) {
+	it, err := c.OrderHistory(req)
+	if err != nil {
+		return nil, err
+	}
+	var resp []*binance.Order
+	for it.Next(ctx) {
+		resp = append(resp, it.Order())
+	}
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+	return &OrderListResp{Data: resp}, nil
+}
+
+type TradeListResp struct {
+	Data []*binance.TradeV3 `json:"data"`
+}
+
+// AllTrades collect a TradeHistory
+func (c *SpotClient) AllTrades(ctx context.Context, req *HistoryReq) (*TradeListResp, error) {
+	it, err := c.TradeHistory(req)
+	if err != nil {
+		return nil, err
+	}
+	var resp []*binance.TradeV3
+	for it.Next(ctx) {
+		resp = append(resp, it.Trade())
+	}
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+	return &TradeListResp{Data: resp}, nil
+}
@@ -0,0 +1,155 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestOrderHistory(t *testing.T) {
+	convey.Convey("TestOrderHistory", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		cli := NewSpotClient(server.Client())
+		ctx := context.Background()
+		fake.SetBalance("BUSD", "10000")
+
+		start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+		clock := start
+		fake.now = func() time.Time { return clock }
+		var placed []int64
+		for i := 0; i < 12; i++ {
+			clock = start.Add(time.Duration(i) * 7 * time.Hour)
+			resp, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "10.01"})
+			convCtx.So(err, convey.ShouldBeNil)
+			placed = append(placed, resp.OrderID)
+			if i != 7 {
+				continue
+			}
+			// a crowded millisecond
+			for j := 0; j < 5; j++ {
+				resp, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000", Price: "0.00019"})
+				convCtx.So(err, convey.ShouldBeNil)
+				placed = append(placed, resp.OrderID)
+			}
+		}
+		last := start.Add(77*time.Hour).UnixNano() / int64(time.Millisecond)
+		begin := start.UnixNano() / int64(time.Millisecond)
+
+		orders, err := cli.AllOrders(ctx, &HistoryReq{Symbol: "LUNCBUSD", StartTime: begin, EndTime: last, Limit: 2})
+		convCtx.So(err, convey.ShouldBeNil)
+		var ids []int64
+		for _, order := range orders.Data {
+			ids = append(ids, order.OrderID)
+		}
+		convCtx.So(ids, convey.ShouldResemble, placed)
+
+		// the first window is a crowded millisecond, paging by id starts at its first order
+		crowded := start.Add(49*time.Hour).UnixNano() / int64(time.Millisecond)
+		requests := len(server.Requests())
+		orders, err = cli.AllOrders(ctx, &HistoryReq{Symbol: "LUNCBUSD", StartTime: crowded, EndTime: crowded, Limit: 2})
+		convCtx.So(err, convey.ShouldBeNil)
+		ids = nil
+		for _, order := range orders.Data {
+			ids = append(ids, order.OrderID)
+		}
+		convCtx.So(ids, convey.ShouldResemble, placed[7:13])
+		for _, request := range server.Requests()[requests:] {
+			if orderId := request.Params.Get("orderId"); orderId != "" {
+				convCtx.So(orderId, convey.ShouldNotEqual, "1")
+			}
+		}
+
+		// by id, up to EndTime
+		it, err := cli.OrderHistory(&HistoryReq{Symbol: "LUNCBUSD", FromId: placed[3], EndTime: begin + int64(30*time.Hour/time.Millisecond), Limit: 3})
+		convCtx.So(err, convey.ShouldBeNil)
+		ids = nil
+		for it.Next(ctx) {
+			ids = append(ids, it.Order().OrderID)
+		}
+		convCtx.So(it.Err(), convey.ShouldBeNil)
+		convCtx.So(ids, convey.ShouldResemble, []int64{placed[3], placed[4]})
+
+		// a failing request stops the iteration
+		requests = len(server.Requests())
+		server.ScriptError("GET", "/api/v3/allOrders", 500, -1001, "Internal error; unable to process your request. Please try again.")
+		it, err = cli.OrderHistory(&HistoryReq{Symbol: "LUNCBUSD", StartTime: begin})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(it.Next(ctx), convey.ShouldBeFalse)
+		convCtx.So(it.Err(), convey.ShouldNotBeNil)
+		convCtx.So(it.Next(ctx), convey.ShouldBeFalse)
+		convCtx.So(len(server.Requests()), convey.ShouldEqual, requests+1)
+
+		_, err = cli.OrderHistory(&HistoryReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+		_, err = cli.OrderHistory(&HistoryReq{Symbol: "LUNCBUSD", StartTime: begin, Limit: 1001})
+		convCtx.So(err, convey.ShouldNotBeNil)
+		_, err = cli.OrderHistory(&HistoryReq{Symbol: "LUNCBUSD", StartTime: last, EndTime: begin})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		// a single page keeps to the 24 hour window
+		page, err := cli.OrderList(ctx, &OrderListReq{Symbol: "LUNCBUSD", StartTime: begin, EndTime: begin + int64(24*time.Hour/time.Millisecond)})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(page.Data), convey.ShouldEqual, 4)
+		_, err = cli.OrderList(ctx, &OrderListReq{Symbol: "LUNCBUSD", StartTime: begin, EndTime: last})
+		convCtx.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestTradeHistory(t *testing.T) {
+	convey.Convey("TestTradeHistory", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+		fake.SetBalance("BUSD", "10000")
+
+		start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+		clock := start
+		fake.now = func() time.Time { return clock }
+		for i := 0; i < 10; i++ {
+			clock = start.Add(time.Duration(i) * 11 * time.Hour)
+			_, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "10.01"})
+			convCtx.So(err, convey.ShouldBeNil)
+		}
+		// a resting order filled in two parts
+		resting, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000", Price: "0.00019"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(fake.FillOrder("LUNCBUSD", resting.OrderID, "30000"), convey.ShouldBeNil)
+		clock = clock.Add(time.Hour)
+		convCtx.So(fake.FillOrder("LUNCBUSD", resting.OrderID, "70000"), convey.ShouldBeNil)
+		clock = clock.Add(time.Hour)
+		begin := start.UnixNano() / int64(time.Millisecond)
+
+		trades, err := cli.AllTrades(ctx, &HistoryReq{Symbol: "LUNCBUSD", StartTime: begin, EndTime: clock.UnixNano() / int64(time.Millisecond), Limit: 3})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(trades.Data), convey.ShouldEqual, 12)
+		for i, trade := range trades.Data {
+			convCtx.So(trade.ID, convey.ShouldEqual, i+1)
+		}
+		convCtx.So(trades.Data[11].IsMaker, convey.ShouldBeTrue)
+
+		fills, err := cli.AllTrades(ctx, &HistoryReq{Symbol: "LUNCBUSD", OrderId: resting.OrderID, FromId: 1})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(fills.Data), convey.ShouldEqual, 2)
+		convCtx.So(fills.Data[0].Quantity, convey.ShouldEqual, "30000.00000000")
+		convCtx.So(fills.Data[1].Quantity, convey.ShouldEqual, "70000.00000000")
+
+		// myTrades takes no time range with orderId, the fills are filtered here
+		fills, err = cli.AllTrades(ctx, &HistoryReq{Symbol: "LUNCBUSD", OrderId: resting.OrderID, StartTime: clock.Add(-90*time.Minute).UnixNano() / int64(time.Millisecond)})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(fills.Data), convey.ShouldEqual, 1)
+		convCtx.So(fills.Data[0].Quantity, convey.ShouldEqual, "70000.00000000")
+		_, err = fake.ListTrades(ctx, &ListTradesParams{Symbol: "LUNCBUSD", OrderId: resting.OrderID, StartTime: begin})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -1128)
+
+		order, err := cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: resting.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(order.CummulativeQuoteQuantity, convey.ShouldEqual, "19.00000000")
+	})
+}
@@ -0,0 +1,251 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"sort"
+	"time"
+)
+
+/*
+K 线回补:
+
+klines 每次最多 1000 根, 带 startTime 时从 startTime 起按开盘时间升序返回.
+BackfillKlines 把 [StartTime, EndTime] 减去已经下载过的区间 (Have), 剩下的每段从头往后翻页,
+每页交给 sink 保存, 返回新的已下载区间 (还没收盘的那根不算). 中途失败时把返回的 Ranges 作为下次的 Have 即可续传.
+*/
+
+const klinesMaxLimit = 1000
+
+// klineIntervals interval lengths, 1M is the shortest month
+var klineIntervals = map[string]time.Duration{
+	"1s":  time.Second,
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+	"1M":  28 * 24 * time.Hour,
+}
+
+// Kline a candle with decimal prices and volumes
+type Kline struct {
+	OpenTime                 int64   `json:"openTime"`
+	Open                     Decimal `json:"open"`
+	High                     Decimal `json:"high"`
+	Low                      Decimal `json:"low"`
+	Close                    Decimal `json:"close"`
+	Volume                   Decimal `json:"volume"`
+	CloseTime                int64   `json:"closeTime"`
+	QuoteAssetVolume         Decimal `json:"quoteAssetVolume"`
+	TradeNum                 int64   `json:"tradeNum"`
+	TakerBuyBaseAssetVolume  Decimal `json:"takerBuyBaseAssetVolume"`
+	TakerBuyQuoteAssetVolume Decimal `json:"takerBuyQuoteAssetVolume"`
+}
+
+// NewKline parse the decimal fields of k
+func NewKline(k *binance.Kline) (*Kline, error) {
+	kline := &Kline{OpenTime: k.OpenTime, CloseTime: k.CloseTime, TradeNum: k.TradeNum}
+	fields := []struct {
+		name     string
+		value    string
+		dst      *Decimal
+		optional bool // empty is zero
+	}{
+		{"open", k.Open, &kline.Open, false},
+		{"high", k.High, &kline.High, false},
+		{"low", k.Low, &kline.Low, false},
+		{"close", k.Close, &kline.Close, false},
+		{"volume", k.Volume, &kline.Volume, true},
+		{"quoteAssetVolume", k.QuoteAssetVolume, &kline.QuoteAssetVolume, true},
+		{"takerBuyBaseAssetVolume", k.TakerBuyBaseAssetVolume, &kline.TakerBuyBaseAssetVolume, true},
+		{"takerBuyQuoteAssetVolume", k.TakerBuyQuoteAssetVolume, &kline.TakerBuyQuoteAssetVolume, true},
+	}
+	for _, field := range fields {
+		if field.value == "" && field.optional {
+			continue
+		}
+		d, err := ParseDecimal(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("kline %d %s %w", k.OpenTime, field.name, err)
+		}
+		*field.dst = d
+	}
+	return kline, nil
+}
+
+func NewKlines(klines []*binance.Kline) ([]*Kline, error) {
+	resp := make([]*Kline, 0, len(klines))
+	for _, k := range klines {
+		kline, err := NewKline(k)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, kline)
+	}
+	return resp, nil
+}
+
+// KlineInterval length of interval, false when binance has no such interval
+func KlineInterval(interval string) (time.Duration, bool) {
+	d, ok := klineIntervals[interval]
+	return d, ok
+}
+
+func klinesParams(req *KlinesReq) (*KlinesParams, error) {
+	if req.Symbol == "" {
+		return nil, errors.New("symbol is required")
+	}
+	interval := req.Interval
+	if interval == "" {
+		interval = "1s"
+	}
+	if _, ok := klineIntervals[interval]; !ok {
+		return nil, errors.New(fmt.Sprintf("invalid kline interval %q", interval))
+	}
+	if req.Limit < 0 || req.Limit > klinesMaxLimit {
+		return nil, errors.New(fmt.Sprintf("limit %d out of range 1-%d", req.Limit, klinesMaxLimit))
+	}
+	if req.StartTime > 0 && req.EndTime > 0 && req.StartTime > req.EndTime {
+		return nil, errors.New(fmt.Sprintf("startTime %d after endTime %d", req.StartTime, req.EndTime))
+	}
+	return &KlinesParams{Symbol: req.Symbol, Interval: interval, StartTime: req.StartTime, EndTime: req.EndTime, Limit: req.Limit}, nil
+}
+
+// KlineRange open times Start to End, both inclusive, in ms
+type KlineRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// MergeKlineRanges sorted, overlapping and adjacent ranges joined
+func MergeKlineRanges(ranges []KlineRange) []KlineRange {
+	sorted := make([]KlineRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.End >= r.Start {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	var merged []KlineRange
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].End+1 {
+			if r.End > merged[n-1].End {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// missingKlineRanges parts of want not covered by have
+func missingKlineRanges(want KlineRange, have []KlineRange) []KlineRange {
+	var missing []KlineRange
+	next := want.Start
+	for _, r := range MergeKlineRanges(have) {
+		if r.End < next {
+			continue
+		}
+		if r.Start > want.End {
+			break
+		}
+		if r.Start > next {
+			missing = append(missing, KlineRange{Start: next, End: r.Start - 1})
+		}
+		next = r.End + 1
+	}
+	if next <= want.End {
+		missing = append(missing, KlineRange{Start: next, End: want.End})
+	}
+	return missing
+}
+
+type BackfillKlinesReq struct {
+	Symbol    string       `json:"symbol"`
+	Interval  string       `json:"interval"`  // required
+	StartTime int64        `json:"startTime"` // open time, ms, required
+	EndTime   int64        `json:"endTime"`   // open time, ms, default now
+	Limit     int          `json:"limit"`     // candles per request, default and at most 1000
+	Have      []KlineRange `json:"have"`      // already downloaded, not requested again
+}
+
+type BackfillKlinesResp struct {
+	Klines   int          `json:"klines"`   // candles passed to sink
+	Requests int          `json:"requests"` // klines requests sent
+	Ranges   []KlineRange `json:"ranges"`   // Have and every range fetched, merged
+}
+
+// BackfillKlines page through [StartTime, EndTime] minus Have, oldest first, handing each page to sink.
+// On an error the returned resp still holds the ranges completed so far
+func (c *SpotClient) BackfillKlines(ctx context.Context, req *BackfillKlinesReq, sink func(klines []*Kline) error) (*BackfillKlinesResp, error) {
+	if req.Interval == "" {
+		return nil, errors.New("interval is required")
+	}
+	if req.StartTime <= 0 {
+		return nil, errors.New("startTime is required")
+	}
+	want := KlineRange{Start: req.StartTime, End: req.EndTime}
+	if want.End == 0 {
+		want.End = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = klinesMaxLimit
+	}
+	if _, err := klinesParams(&KlinesReq{Symbol: req.Symbol, Interval: req.Interval, StartTime: want.Start, EndTime: want.End, Limit: limit}); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	resp := &BackfillKlinesResp{Ranges: MergeKlineRanges(req.Have)}
+	for _, missing := range missingKlineRanges(want, req.Have) {
+		for start, open := missing.Start, false; start <= missing.End && !open; {
+			klines, err := c.exchange.Klines(ctx, &KlinesParams{Symbol: req.Symbol, Interval: req.Interval, StartTime: start, EndTime: missing.End, Limit: limit})
+			resp.Requests++
+			if err != nil {
+				return resp, err
+			}
+			typed, err := NewKlines(klines)
+			if err != nil {
+				return resp, err
+			}
+			end := missing.End
+			if len(typed) == limit {
+				end = typed[len(typed)-1].OpenTime
+			}
+			// the candle still open is neither kept nor counted as fetched
+			for i, k := range typed {
+				if k.CloseTime >= now {
+					typed, end, open = typed[:i], k.OpenTime-1, true
+					break
+				}
+			}
+			if len(typed) > 0 {
+				if err = sink(typed); err != nil {
+					return resp, err
+				}
+				resp.Klines += len(typed)
+			}
+			if end >= start {
+				resp.Ranges = MergeKlineRanges(append(resp.Ranges, KlineRange{Start: start, End: end}))
+			}
+			start = end + 1
+		}
+	}
+	return resp, nil
+}
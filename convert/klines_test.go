@@ -0,0 +1,126 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+// addTestKlines n 1m candles of symbol from start, the close price is the minute number
+func addTestKlines(fake *FakeExchange, symbol string, start int64, n int) {
+	minute := int64(time.Minute / time.Millisecond)
+	for i := 0; i < n; i++ {
+		price := fmt.Sprintf("%d.5", i)
+		fake.AddKlines(symbol, "1m", &binance.Kline{
+			OpenTime: start + int64(i)*minute, Open: price, High: price, Low: price, Close: price, Volume: "1",
+			CloseTime: start + int64(i+1)*minute - 1, QuoteAssetVolume: price, TradeNum: 1,
+		})
+	}
+}
+
+func TestKlinesRange(t *testing.T) {
+	convey.Convey("TestKlinesRange", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		cli := NewSpotClient(server.Client())
+		ctx := context.Background()
+		start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+		addTestKlines(fake, "EOSBTC", start, 1500)
+
+		resp, err := cli.Klines(ctx, &KlinesReq{Symbol: "EOSBTC", Interval: "1m", StartTime: start + 60000, Limit: 3})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(resp.Klines), convey.ShouldEqual, 3)
+		convCtx.So(resp.Klines[0].OpenTime, convey.ShouldEqual, start+60000)
+		convCtx.So(resp.Klines[0].Close.String(), convey.ShouldEqual, "1.5")
+		convCtx.So(resp.Klines[2].QuoteAssetVolume.String(), convey.ShouldEqual, "3.5")
+		convCtx.So(resp.Data[0].Close, convey.ShouldEqual, "1.5")
+
+		resp, err = cli.Klines(ctx, &KlinesReq{Symbol: "EOSBTC", Interval: "1m"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(resp.Klines), convey.ShouldEqual, 500)
+		convCtx.So(resp.Klines[499].Close.String(), convey.ShouldEqual, "1499.5")
+
+		_, err = cli.Klines(ctx, &KlinesReq{Symbol: "EOSBTC", Interval: "2m"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+		_, err = cli.Klines(ctx, &KlinesReq{Symbol: "EOSBTC", Interval: "1m", Limit: 1001})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		_, err = NewKline(&binance.Kline{OpenTime: 1, Open: "1", High: "x", Low: "1", Close: "1"})
+		convCtx.So(errors.Is(err, ErrInvalidDecimal), convey.ShouldBeTrue)
+	})
+}
+
+func TestBackfillKlines(t *testing.T) {
+	convey.Convey("TestBackfillKlines", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+		minute := int64(time.Minute / time.Millisecond)
+		start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+		addTestKlines(fake, "EOSBTC", start, 2500)
+		end := start + 2499*minute
+
+		// interrupted after two pages
+		var got []*Kline
+		stop := errors.New("disk full")
+		req := &BackfillKlinesReq{Symbol: "EOSBTC", Interval: "1m", StartTime: start, EndTime: end, Limit: 1000}
+		resp, err := cli.BackfillKlines(ctx, req, func(klines []*Kline) error {
+			if len(got) >= 2000 {
+				return stop
+			}
+			got = append(got, klines...)
+			return nil
+		})
+		convCtx.So(err, convey.ShouldEqual, stop)
+		convCtx.So(resp.Klines, convey.ShouldEqual, 2000)
+		convCtx.So(resp.Ranges, convey.ShouldResemble, []KlineRange{{Start: start, End: start + 1999*minute}})
+
+		// resumed: only the rest is requested
+		req.Have = resp.Ranges
+		resp, err = cli.BackfillKlines(ctx, req, func(klines []*Kline) error {
+			got = append(got, klines...)
+			return nil
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Requests, convey.ShouldEqual, 1)
+		convCtx.So(resp.Klines, convey.ShouldEqual, 500)
+		convCtx.So(resp.Ranges, convey.ShouldResemble, []KlineRange{{Start: start, End: end}})
+		convCtx.So(len(got), convey.ShouldEqual, 2500)
+		for i, k := range got {
+			convCtx.So(k.OpenTime, convey.ShouldEqual, start+int64(i)*minute)
+		}
+
+		// a hole in the middle
+		got = nil
+		req.Have = []KlineRange{{Start: start, End: start + 99*minute}, {Start: start + 200*minute, End: end}}
+		resp, err = cli.BackfillKlines(ctx, req, func(klines []*Kline) error {
+			got = append(got, klines...)
+			return nil
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(got), convey.ShouldEqual, 100)
+		convCtx.So(got[0].OpenTime, convey.ShouldEqual, start+100*minute)
+		convCtx.So(resp.Ranges, convey.ShouldResemble, []KlineRange{{Start: start, End: end}})
+
+		// the open candle is left for later
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		recent := now - 2*minute
+		addTestKlines(fake, "LUNCBUSD", recent, 3)
+		got = nil
+		resp, err = cli.BackfillKlines(ctx, &BackfillKlinesReq{Symbol: "LUNCBUSD", Interval: "1m", StartTime: recent}, func(klines []*Kline) error {
+			got = append(got, klines...)
+			return nil
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(got), convey.ShouldEqual, 2)
+		convCtx.So(resp.Ranges, convey.ShouldResemble, []KlineRange{{Start: recent, End: recent + 2*minute - 1}})
+
+		_, err = cli.BackfillKlines(ctx, &BackfillKlinesReq{Symbol: "EOSBTC", StartTime: start}, nil)
+		convCtx.So(err, convey.ShouldNotBeNil)
+		convCtx.So(MergeKlineRanges([]KlineRange{{5, 9}, {1, 3}, {4, 4}, {20, 30}, {8, 12}}), convey.ShouldResemble, []KlineRange{{1, 12}, {20, 30}})
+	})
+}
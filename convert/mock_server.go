@@ -0,0 +1,523 @@
+package convert
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockServer httptest stand-in for the binance spot REST endpoints SpotClient touches.
+// Requests are answered from an Exchange (usually a FakeExchange) unless a scripted response is queued,
+// signed endpoints check X-MBX-APIKEY, the HMAC signature and the recvWindow like the real api.
+type MockServer struct {
+	Server    *httptest.Server
+	APIKey    string
+	SecretKey string
+
+	exchange Exchange
+	routes   map[string]mockRoute
+
+	mu       sync.Mutex
+	scripts  map[string][]MockResponse
+	requests []*MockRequest
+}
+
+// MockResponse scripted reply, served once in queue order
+type MockResponse struct {
+	Status int
+	Body   string
+	Header http.Header
+}
+
+// MockRequest a request the server received, params merge query string and form body
+type MockRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Params url.Values
+}
+
+type mockRoute struct {
+	signed  bool
+	apiKey  bool // USER_STREAM endpoints only need X-MBX-APIKEY
+	handler func(ctx context.Context, params url.Values) (interface{}, error)
+}
+
+func NewMockServer(exchange Exchange, apiKey, secretKey string) *MockServer {
+	s := &MockServer{
+		APIKey:    apiKey,
+		SecretKey: secretKey,
+		exchange:  exchange,
+		scripts:   make(map[string][]MockResponse),
+	}
+	s.routes = s.defaultRoutes()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client binance client pointed at the mock server
+func (s *MockServer) Client() *binance.Client {
+	client := binance.NewClient(s.APIKey, s.SecretKey)
+	client.BaseURL = s.Server.URL
+	client.HTTPClient = s.Server.Client()
+	return client
+}
+
+func (s *MockServer) URL() string {
+	return s.Server.URL
+}
+
+func (s *MockServer) Close() {
+	s.Server.Close()
+}
+
+// Script queue responses for method and path, e.g. ("POST", "/api/v3/order")
+func (s *MockServer) Script(method, path string, responses ...MockResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := method + " " + path
+	s.scripts[key] = append(s.scripts[key], responses...)
+}
+
+// ScriptError queue a binance api error reply
+func (s *MockServer) ScriptError(method, path string, status int, code int64, msg string) {
+	body, _ := json.Marshal(&common.APIError{Code: code, Message: msg})
+	s.Script(method, path, MockResponse{Status: status, Body: string(body)})
+}
+
+// Requests every request received so far
+func (s *MockServer) Requests() []*MockRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*MockRequest(nil), s.requests...)
+}
+
+func (s *MockServer) defaultRoutes() map[string]mockRoute {
+	return map[string]mockRoute{
+		"GET /api/v3/ticker/bookTicker": {handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			list, err := s.exchange.ListBookTickers(ctx, params.Get("symbol"))
+			if err != nil || params.Get("symbol") == "" {
+				return list, err
+			}
+			if len(list) == 0 {
+				return nil, &common.APIError{Code: -1121, Message: "Invalid symbol."}
+			}
+			return list[0], nil
+		}},
+		"GET /api/v3/depth": {handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "symbol"); err != nil {
+				return nil, err
+			}
+			depth, err := s.exchange.Depth(ctx, params.Get("symbol"), int(mockInt64Param(params, "limit")))
+			if err != nil {
+				return nil, err
+			}
+			levels := func(list []binance.Bid) [][2]string {
+				rows := make([][2]string, 0, len(list))
+				for _, level := range list {
+					rows = append(rows, [2]string{level.Price, level.Quantity})
+				}
+				return rows
+			}
+			return map[string]interface{}{"lastUpdateId": depth.LastUpdateID, "bids": levels(depth.Bids), "asks": levels(depth.Asks)}, nil
+		}},
+		"GET /api/v3/exchangeInfo": {handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			symbols, err := mockSymbolsParam(params)
+			if err != nil {
+				return nil, err
+			}
+			return s.exchange.ExchangeInfo(ctx, symbols...)
+		}},
+		"GET /api/v3/avgPrice": {handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "symbol"); err != nil {
+				return nil, err
+			}
+			return s.exchange.AveragePrice(ctx, params.Get("symbol"))
+		}},
+		"POST /api/v3/order": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "symbol", "side", "type"); err != nil {
+				return nil, err
+			}
+			return s.exchange.CreateOrder(ctx, &CreateOrderParams{
+				Symbol:           params.Get("symbol"),
+				Side:             binance.SideType(params.Get("side")),
+				Type:             binance.OrderType(params.Get("type")),
+				TimeInForce:      binance.TimeInForceType(params.Get("timeInForce")),
+				Quantity:         params.Get("quantity"),
+				QuoteOrderQty:    params.Get("quoteOrderQty"),
+				Price:            params.Get("price"),
+				StopPrice:        params.Get("stopPrice"),
+				TrailingDelta:    mockInt64Param(params, "trailingDelta"),
+				NewClientOrderId: params.Get("newClientOrderId"),
+				NewOrderRespType: binance.NewOrderRespType(params.Get("newOrderRespType")),
+			})
+		}},
+		"GET /api/v3/order": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			query, err := mockQueryOrderParams(params)
+			if err != nil {
+				return nil, err
+			}
+			return s.exchange.GetOrder(ctx, query)
+		}},
+		"DELETE /api/v3/order": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			query, err := mockQueryOrderParams(params)
+			if err != nil {
+				return nil, err
+			}
+			return s.exchange.CancelOrder(ctx, query)
+		}},
+		"POST /api/v3/order/oco": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "symbol", "side", "quantity", "price", "stopPrice"); err != nil {
+				return nil, err
+			}
+			return s.exchange.CreateOCO(ctx, &CreateOCOParams{
+				Symbol:               params.Get("symbol"),
+				Side:                 binance.SideType(params.Get("side")),
+				Quantity:             params.Get("quantity"),
+				Price:                params.Get("price"),
+				StopPrice:            params.Get("stopPrice"),
+				StopLimitPrice:       params.Get("stopLimitPrice"),
+				StopLimitTimeInForce: binance.TimeInForceType(params.Get("stopLimitTimeInForce")),
+				ListClientOrderId:    params.Get("listClientOrderId"),
+				LimitClientOrderId:   params.Get("limitClientOrderId"),
+				StopClientOrderId:    params.Get("stopClientOrderId"),
+				NewOrderRespType:     binance.NewOrderRespType(params.Get("newOrderRespType")),
+			})
+		}},
+		"GET /api/v3/orderList": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			query := &QueryOrderListParams{OrderListId: mockInt64Param(params, "orderListId"), ListClientOrderId: params.Get("origClientOrderId")}
+			if query.OrderListId == 0 && query.ListClientOrderId == "" {
+				return nil, &common.APIError{Code: -1102, Message: "Param 'origClientOrderId' or 'orderListId' must be sent, but both were empty/null!"}
+			}
+			return s.exchange.GetOrderList(ctx, query)
+		}},
+		"GET /api/v3/openOrderList": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			return s.exchange.ListOpenOrderLists(ctx)
+		}},
+		"DELETE /api/v3/orderList": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "symbol"); err != nil {
+				return nil, err
+			}
+			query := &QueryOrderListParams{Symbol: params.Get("symbol"), OrderListId: mockInt64Param(params, "orderListId"), ListClientOrderId: params.Get("listClientOrderId")}
+			if query.OrderListId == 0 && query.ListClientOrderId == "" {
+				return nil, &common.APIError{Code: -1102, Message: "Param 'listClientOrderId' or 'orderListId' must be sent, but both were empty/null!"}
+			}
+			return s.exchange.CancelOrderList(ctx, query)
+		}},
+		"GET /api/v3/openOrders": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			return s.exchange.ListOpenOrders(ctx, params.Get("symbol"))
+		}},
+		"GET /api/v3/allOrders": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "symbol"); err != nil {
+				return nil, err
+			}
+			return s.exchange.ListOrders(ctx, &ListOrdersParams{
+				Symbol:    params.Get("symbol"),
+				OrderId:   mockInt64Param(params, "orderId"),
+				StartTime: mockInt64Param(params, "startTime"),
+				EndTime:   mockInt64Param(params, "endTime"),
+				Limit:     int(mockInt64Param(params, "limit")),
+			})
+		}},
+		"GET /api/v3/myTrades": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "symbol"); err != nil {
+				return nil, err
+			}
+			return s.exchange.ListTrades(ctx, &ListTradesParams{
+				Symbol:    params.Get("symbol"),
+				OrderId:   mockInt64Param(params, "orderId"),
+				FromId:    mockInt64Param(params, "fromId"),
+				StartTime: mockInt64Param(params, "startTime"),
+				EndTime:   mockInt64Param(params, "endTime"),
+				Limit:     int(mockInt64Param(params, "limit")),
+			})
+		}},
+		"GET /api/v3/klines": {handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "symbol", "interval"); err != nil {
+				return nil, err
+			}
+			klines, err := s.exchange.Klines(ctx, &KlinesParams{
+				Symbol:    params.Get("symbol"),
+				Interval:  params.Get("interval"),
+				StartTime: mockInt64Param(params, "startTime"),
+				EndTime:   mockInt64Param(params, "endTime"),
+				Limit:     int(mockInt64Param(params, "limit")),
+			})
+			if err != nil {
+				return nil, err
+			}
+			rows := make([][]interface{}, 0, len(klines))
+			for _, k := range klines {
+				rows = append(rows, []interface{}{
+					k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume, k.CloseTime,
+					k.QuoteAssetVolume, k.TradeNum, k.TakerBuyBaseAssetVolume, k.TakerBuyQuoteAssetVolume, "0",
+				})
+			}
+			return rows, nil
+		}},
+		"GET /api/v3/ticker/price": {handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			symbols, err := mockSymbolsParam(params)
+			if err != nil {
+				return nil, err
+			}
+			list, err := s.exchange.ListPrices(ctx, symbols...)
+			if err != nil || params.Get("symbol") == "" {
+				return list, err
+			}
+			return list[0], nil
+		}},
+		"GET /sapi/v1/asset/tradeFee": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			return s.exchange.TradeFee(ctx, params.Get("symbol"))
+		}},
+		"POST /sapi/v1/capital/withdraw/apply": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "coin", "address", "amount"); err != nil {
+				return nil, err
+			}
+			return s.exchange.CreateWithdraw(ctx, &WithdrawParams{
+				Coin:            params.Get("coin"),
+				Address:         params.Get("address"),
+				AddressTag:      params.Get("addressTag"),
+				Amount:          params.Get("amount"),
+				WithdrawOrderId: params.Get("withdrawOrderId"),
+			})
+		}},
+		"GET /sapi/v1/capital/withdraw/history": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			return s.exchange.ListWithdraws(ctx, params.Get("coin"), params.Get("withdrawOrderId"))
+		}},
+		"POST /sapi/v3/asset/getUserAsset": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			return s.exchange.UserAsset(ctx, params.Get("asset"))
+		}},
+		"POST /sapi/v1/convert/getQuote": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "fromAsset", "toAsset"); err != nil {
+				return nil, err
+			}
+			if (params.Get("fromAmount") == "") == (params.Get("toAmount") == "") {
+				return nil, &common.APIError{Code: -1102, Message: "Exactly one of 'fromAmount' and 'toAmount' must be sent."}
+			}
+			return s.exchange.GetConvertQuote(ctx, &ConvertQuoteParams{
+				FromAsset:  params.Get("fromAsset"),
+				ToAsset:    params.Get("toAsset"),
+				FromAmount: params.Get("fromAmount"),
+				ToAmount:   params.Get("toAmount"),
+				WalletType: params.Get("walletType"),
+				ValidTime:  params.Get("validTime"),
+			})
+		}},
+		"POST /sapi/v1/convert/acceptQuote": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "quoteId"); err != nil {
+				return nil, err
+			}
+			return s.exchange.AcceptConvertQuote(ctx, params.Get("quoteId"))
+		}},
+		"GET /sapi/v1/convert/orderStatus": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			query := &QueryConvertOrderParams{OrderId: mockInt64Param(params, "orderId"), QuoteId: params.Get("quoteId")}
+			if query.OrderId == 0 && query.QuoteId == "" {
+				return nil, &common.APIError{Code: -1102, Message: "Param 'orderId' or 'quoteId' must be sent, but both were empty/null!"}
+			}
+			return s.exchange.GetConvertOrder(ctx, query)
+		}},
+		"GET /sapi/v1/convert/tradeFlow": {signed: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "startTime", "endTime"); err != nil {
+				return nil, err
+			}
+			return s.exchange.ListConvertTrades(ctx, &ListConvertTradesParams{
+				StartTime: mockInt64Param(params, "startTime"),
+				EndTime:   mockInt64Param(params, "endTime"),
+				Limit:     int(mockInt64Param(params, "limit")),
+			})
+		}},
+		"POST /api/v3/userDataStream": {apiKey: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			listenKey, err := s.exchange.StartUserStream(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"listenKey": listenKey}, nil
+		}},
+		"PUT /api/v3/userDataStream": {apiKey: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "listenKey"); err != nil {
+				return nil, err
+			}
+			return struct{}{}, s.exchange.KeepaliveUserStream(ctx, params.Get("listenKey"))
+		}},
+		"DELETE /api/v3/userDataStream": {apiKey: true, handler: func(ctx context.Context, params url.Values) (interface{}, error) {
+			if err := mockRequire(params, "listenKey"); err != nil {
+				return nil, err
+			}
+			return struct{}{}, s.exchange.CloseUserStream(ctx, params.Get("listenKey"))
+		}},
+	}
+}
+
+func (s *MockServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		mockWriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	params, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		mockWriteError(w, http.StatusBadRequest, &common.APIError{Code: -1100, Message: "Illegal characters found in a parameter."})
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		mockWriteError(w, http.StatusBadRequest, &common.APIError{Code: -1100, Message: "Illegal characters found in a parameter."})
+		return
+	}
+	for k, v := range form {
+		params[k] = append(params[k], v...)
+	}
+
+	key := r.Method + " " + r.URL.Path
+	s.mu.Lock()
+	s.requests = append(s.requests, &MockRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Params: params})
+	route, ok := s.routes[key]
+	var scripted *MockResponse
+	if queue := s.scripts[key]; len(queue) > 0 {
+		scripted = &queue[0]
+		s.scripts[key] = queue[1:]
+	}
+	s.mu.Unlock()
+
+	if !ok && scripted == nil {
+		mockWriteError(w, http.StatusNotFound, &common.APIError{Code: -1000, Message: fmt.Sprintf("mock server has no route for %s", key)})
+		return
+	}
+	if route.signed {
+		if status, err := s.verify(r, string(body), params); err != nil {
+			mockWriteError(w, status, err)
+			return
+		}
+	} else if route.apiKey && r.Header.Get("X-MBX-APIKEY") != s.APIKey {
+		mockWriteError(w, http.StatusUnauthorized, &common.APIError{Code: -2014, Message: "API-key format invalid."})
+		return
+	}
+	if scripted != nil {
+		for k, v := range scripted.Header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		status := scripted.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(scripted.Body))
+		return
+	}
+
+	resp, err := route.handler(r.Context(), params)
+	if err != nil {
+		status := http.StatusBadRequest
+		if !common.IsAPIError(err) {
+			status = http.StatusInternalServerError
+		}
+		mockWriteError(w, status, err)
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		mockWriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// verify api key, signature and timestamp the same way binance does for USER_DATA/TRADE endpoints
+func (s *MockServer) verify(r *http.Request, body string, params url.Values) (int, error) {
+	if r.Header.Get("X-MBX-APIKEY") != s.APIKey {
+		return http.StatusUnauthorized, &common.APIError{Code: -2014, Message: "API-key format invalid."}
+	}
+	rawQuery := r.URL.RawQuery
+	idx := strings.LastIndex(rawQuery, "signature=")
+	if idx < 0 {
+		return http.StatusBadRequest, &common.APIError{Code: -1102, Message: "Mandatory parameter 'signature' was not sent, was empty/null, or malformed."}
+	}
+	payload := strings.TrimSuffix(rawQuery[:idx], "&") + body
+	mac := hmac.New(sha256.New, []byte(s.SecretKey))
+	mac.Write([]byte(payload))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(rawQuery[idx+len("signature="):])) {
+		return http.StatusBadRequest, &common.APIError{Code: -1022, Message: "Signature for this request is not valid."}
+	}
+
+	timestamp := mockInt64Param(params, "timestamp")
+	if timestamp == 0 {
+		return http.StatusBadRequest, &common.APIError{Code: -1102, Message: "Mandatory parameter 'timestamp' was not sent, was empty/null, or malformed."}
+	}
+	recvWindow := mockInt64Param(params, "recvWindow")
+	if recvWindow == 0 {
+		recvWindow = 5000
+	}
+	now := binance.FormatTimestamp(time.Now())
+	if timestamp > now+1000 || now-timestamp > recvWindow {
+		return http.StatusBadRequest, &common.APIError{Code: -1021, Message: "Timestamp for this request is outside of the recvWindow."}
+	}
+	return 0, nil
+}
+
+func mockWriteError(w http.ResponseWriter, status int, err error) {
+	apiErr := &common.APIError{Code: -1000, Message: err.Error()}
+	errors.As(err, &apiErr)
+	data, _ := json.Marshal(apiErr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func mockRequire(params url.Values, keys ...string) error {
+	for _, key := range keys {
+		if params.Get(key) == "" {
+			return &common.APIError{Code: -1102, Message: fmt.Sprintf("Mandatory parameter '%s' was not sent, was empty/null, or malformed.", key)}
+		}
+	}
+	return nil
+}
+
+func mockInt64Param(params url.Values, key string) int64 {
+	v, _ := strconv.ParseInt(params.Get(key), 10, 64)
+	return v
+}
+
+// mockSymbolsParam symbol=X or symbols=["X","Y"]
+func mockSymbolsParam(params url.Values) ([]string, error) {
+	if symbol := params.Get("symbol"); symbol != "" {
+		return []string{symbol}, nil
+	}
+	var symbols []string
+	if raw := params.Get("symbols"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &symbols); err != nil {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'symbols'."}
+		}
+	}
+	return symbols, nil
+}
+
+func mockQueryOrderParams(params url.Values) (*QueryOrderParams, error) {
+	if err := mockRequire(params, "symbol"); err != nil {
+		return nil, err
+	}
+	query := &QueryOrderParams{
+		Symbol:            params.Get("symbol"),
+		OrderId:           mockInt64Param(params, "orderId"),
+		OrigClientOrderId: params.Get("origClientOrderId"),
+	}
+	if query.OrderId == 0 && query.OrigClientOrderId == "" {
+		return nil, &common.APIError{Code: -1102, Message: "Param 'origClientOrderId' or 'orderId' must be sent, but both were empty/null!"}
+	}
+	return query, nil
+}
@@ -0,0 +1,192 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"testing"
+)
+
+func newTestMockServer() (*MockServer, *FakeExchange) {
+	fake := newTestFakeExchange()
+	return NewMockServer(fake, "mockApiKey", "mockSecretKey"), fake
+}
+
+func TestMockServerSpotClient(t *testing.T) {
+	convey.Convey("TestMockServerSpotClient", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		fake.SetBalance("EOS", "1")
+		fake.AddKlines("EOSUSDT", "1s", &binance.Kline{OpenTime: 1000, Open: "1.1", High: "1.2", Low: "1.0", Close: "1.15", Volume: "10", CloseTime: 1999})
+		fake.AddSymbol(binance.Symbol{Symbol: "EOSUSDT", Status: "TRADING", BaseAsset: "EOS", QuoteAsset: "USDT"})
+		cli := NewSpotClient(server.Client())
+		ctx := context.Background()
+
+		quote, err := cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(quote.Data[0].Symbol, convey.ShouldEqual, "LUNCBUSD")
+		convCtx.So(quote.MinNotional, convey.ShouldEqual, "10.00000000")
+
+		buy, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(buy.Status, convey.ShouldEqual, "FILLED")
+
+		sell, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "100000"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(sell.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(sell.ExecutedQuantity, convey.ShouldEqual, "100000.00000000")
+		convCtx.So(fake.Balance("LUNC").IsZero(), convey.ShouldBeTrue)
+
+		order, err := cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: buy.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.CummulativeQuoteQuantity, convey.ShouldEqual, "20.01000000")
+
+		list, err := cli.OrderList(ctx, &OrderListReq{Symbol: "LUNCBUSD", Limit: 500})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(list.Data), convey.ShouldEqual, 2)
+
+		_, err = fake.CreateOrder(ctx, &CreateOrderParams{Symbol: "EOSBTC", Side: "BUY", Type: "LIMIT", TimeInForce: "GTC", Quantity: "1", Price: "0.00005000"})
+		convCtx.So(err, convey.ShouldBeNil)
+		hang, err := cli.HangOrderList(ctx)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(hang.Data), convey.ShouldEqual, 1)
+		canceled, err := cli.CancelOrder(ctx, &CancelReq{Symbol: "EOSBTC", OrderId: hang.Data[0].OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(canceled.Status, convey.ShouldEqual, "CANCELED")
+
+		fee, err := cli.TradeFee(ctx, &TradeFeeReq{Symbol: "EOSBTC"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(fee.Data[0].Symbol, convey.ShouldEqual, "EOSBTC")
+
+		withdraw, err := cli.Withdraw(ctx, &WithdrawReq{Coin: "EOS", Address: "pursonpurson", Amount: "0.2", WithdrawOrderId: "withdraw:order:1001"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(withdraw.Id, convey.ShouldNotBeEmpty)
+		history, err := cli.WithdrawHistory(ctx, &WithdrawHistoryReq{Coin: "EOS"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(history.Data[0].Coin, convey.ShouldEqual, "EOS")
+
+		klines, err := cli.Klines(ctx, &KlinesOneSecReq{Symbol: "EOSUSDT"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(klines.Data[0].Close, convey.ShouldEqual, "1.15")
+
+		price, err := cli.GetTickerPrice(ctx, &NewPriceReq{Symbol: "EOSBTC"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(price.Data[0].Symbol, convey.ShouldEqual, "EOSBTC")
+		price, err = cli.GetTickerPrice(ctx, &NewPriceReq{Symbols: []string{"EOSBTC", "LUNCBUSD"}})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(price.Data), convey.ShouldEqual, 2)
+
+		assets, err := cli.GetUserAsset(ctx, &UserAssetReq{Asset: "EOS"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(assets.Data[0].Free, convey.ShouldEqual, "0.80000000")
+	})
+}
+
+func TestMockServerSignature(t *testing.T) {
+	convey.Convey("TestMockServerSignature", t, func(convCtx convey.C) {
+		server, _ := newTestMockServer()
+		defer server.Close()
+
+		client := server.Client()
+		client.SecretKey = "wrong"
+		_, err := NewSpotClient(client).TradeFee(context.Background(), &TradeFeeReq{Symbol: "EOSBTC"})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -1022)
+		convCtx.So(errors.Is(err, ErrInvalidSignature), convey.ShouldBeTrue)
+
+		client = server.Client()
+		client.APIKey = "wrong"
+		_, err = NewSpotClient(client).TradeFee(context.Background(), &TradeFeeReq{Symbol: "EOSBTC"})
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -2014)
+		convCtx.So(errors.Is(err, ErrInvalidAPIKey), convey.ShouldBeTrue)
+
+		client = server.Client()
+		client.TimeOffset = 60000
+		_, err = NewSpotClient(client).TradeFee(context.Background(), &TradeFeeReq{Symbol: "EOSBTC"})
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -1021)
+		convCtx.So(errors.Is(err, ErrTimestampOutsideRecvWindow), convey.ShouldBeTrue)
+	})
+}
+
+func TestMockServerScript(t *testing.T) {
+	convey.Convey("TestMockServerScript", t, func(convCtx convey.C) {
+		server, _ := newTestMockServer()
+		defer server.Close()
+		cli := NewSpotClient(server.Client())
+
+		server.ScriptError(http.MethodPost, "/api/v3/order", http.StatusBadRequest, -1013, "Filter failure: LOT_SIZE")
+		_, err := cli.Trade(context.Background(), &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20"})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -1013)
+		convCtx.So(errors.Is(err, ErrFilterViolation), convey.ShouldBeTrue)
+
+		resp, err := cli.Trade(context.Background(), &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Status, convey.ShouldEqual, "FILLED")
+
+		var orders int
+		for _, req := range server.Requests() {
+			if req.Path == "/api/v3/order" {
+				orders++
+				convCtx.So(req.Params.Get("quoteOrderQty"), convey.ShouldEqual, "20")
+			}
+		}
+		convCtx.So(orders, convey.ShouldEqual, 2)
+	})
+}
+
+func TestMockServerClientOrderId(t *testing.T) {
+	convey.Convey("TestMockServerClientOrderId", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		cli := NewSpotClient(server.Client())
+		ctx := context.Background()
+		fake.SetBalance("BUSD", "1000")
+
+		placed, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000", Price: "0.00019", NewClientOrderId: "grid-1"})
+		convCtx.So(err, convey.ShouldBeNil)
+		order, err := cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrigClientOrderId: "grid-1"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.OrderID, convey.ShouldEqual, placed.OrderID)
+		_, err = cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+		_, err = cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrigClientOrderId: "grid-2"})
+		convCtx.So(errors.Is(err, ErrUnknownOrder), convey.ShouldBeTrue)
+
+		// partially filled orders cancel the rest
+		convCtx.So(fake.FillOrder("LUNCBUSD", placed.OrderID, "40000"), convey.ShouldBeNil)
+		canceled, err := cli.CancelByClientId(ctx, &CancelByClientIdReq{Symbol: "LUNCBUSD", ClientOrderId: "grid-1"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(canceled.Canceled, convey.ShouldBeTrue)
+		convCtx.So(canceled.Status, convey.ShouldEqual, "CANCELED")
+		convCtx.So(canceled.ExecutedQty, convey.ShouldEqual, "40000.00000000")
+		convCtx.So(canceled.Remaining, convey.ShouldEqual, "60000")
+		convCtx.So(fake.Balance("LUNC").String(), convey.ShouldEqual, "40000")
+
+		// already closed: its final state, not an error
+		canceled, err = cli.CancelByClientId(ctx, &CancelByClientIdReq{Symbol: "LUNCBUSD", ClientOrderId: "grid-1"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(canceled.Canceled, convey.ShouldBeFalse)
+		convCtx.So(canceled.Status, convey.ShouldEqual, "CANCELED")
+		convCtx.So(canceled.OrderId, convey.ShouldEqual, placed.OrderID)
+
+		// the id is free again, the latest order wins
+		again, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000", Price: "0.00019", NewClientOrderId: "grid-1"})
+		convCtx.So(err, convey.ShouldBeNil)
+		cancel, err := cli.CancelOrder(ctx, &CancelReq{Symbol: "LUNCBUSD", OrigClientOrderId: "grid-1"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(cancel.OrderId, convey.ShouldEqual, again.OrderID)
+
+		_, err = cli.CancelByClientId(ctx, &CancelByClientIdReq{Symbol: "LUNCBUSD", ClientOrderId: "grid-2"})
+		convCtx.So(errors.Is(err, ErrUnknownOrder), convey.ShouldBeTrue)
+		_, err = cli.CancelOrder(ctx, &CancelReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,145 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+)
+
+// Normalizer bring quantities and prices of one symbol onto its LOT_SIZE / MARKET_LOT_SIZE
+// stepSize and PRICE_FILTER tickSize so binance does not reject them for precision
+type Normalizer struct {
+	Filters        *SymbolFilters
+	BasePrecision  int32
+	QuotePrecision int32
+	QuantityMode   RoundingMode
+	PriceMode      RoundingMode
+}
+
+// Normalized rounded value and the dust left over, Value + Dust == original value.
+// Dust is negative when rounding went up
+type Normalized struct {
+	Value Decimal `json:"value"`
+	Dust  Decimal `json:"dust"`
+}
+
+func NewNormalizer(symbol *binance.Symbol) (*Normalizer, error) {
+	filters, err := ParseSymbolFilters(symbol.Filters)
+	if err != nil {
+		return nil, err
+	}
+	n := &Normalizer{
+		Filters:        filters,
+		BasePrecision:  int32(symbol.BaseAssetPrecision),
+		QuotePrecision: int32(symbol.QuoteAssetPrecision),
+		QuantityMode:   RoundDown,
+		PriceMode:      RoundDown,
+	}
+	if n.BasePrecision == 0 {
+		n.BasePrecision = 8
+	}
+	if n.QuotePrecision == 0 {
+		n.QuotePrecision = 8
+	}
+	return n, nil
+}
+
+func normalized(value, rounded Decimal) Normalized {
+	return Normalized{Value: rounded, Dust: value.Sub(rounded)}
+}
+
+// QuantityStep stepSize used for base quantities of orderType,
+// market orders use MARKET_LOT_SIZE unless its stepSize is 0
+func (n *Normalizer) QuantityStep(orderType binance.OrderType) Decimal {
+	if isMarketOrder(orderType) && n.Filters.MarketLotSize != nil && !n.Filters.MarketLotSize.StepSize.IsZero() {
+		return n.Filters.MarketLotSize.StepSize
+	}
+	if n.Filters.LotSize != nil && !n.Filters.LotSize.StepSize.IsZero() {
+		return n.Filters.LotSize.StepSize
+	}
+	return n.precisionStep(n.BasePrecision)
+}
+
+func (n *Normalizer) precisionStep(places int32) Decimal {
+	return MustDecimal(fmt.Sprintf("1e-%d", places))
+}
+
+// TickSize PRICE_FILTER tickSize, quote precision when the symbol has none
+func (n *Normalizer) TickSize() Decimal {
+	if n.Filters.Price != nil && !n.Filters.Price.TickSize.IsZero() {
+		return n.Filters.Price.TickSize
+	}
+	return n.precisionStep(n.QuotePrecision)
+}
+
+// Quantity round a base asset quantity for orderType
+func (n *Normalizer) Quantity(quantity Decimal, orderType binance.OrderType) Normalized {
+	return normalized(quantity, quantity.RoundStep(n.QuantityStep(orderType), n.QuantityMode))
+}
+
+// QuoteQuantity round a quoteOrderQty to the quote asset precision
+func (n *Normalizer) QuoteQuantity(quantity Decimal) Normalized {
+	return normalized(quantity, quantity.Round(n.QuotePrecision, n.QuantityMode))
+}
+
+// Price round a price, stopPrice or icebergQty price to tickSize
+func (n *Normalizer) Price(price Decimal) Normalized {
+	return normalized(price, price.RoundStep(n.TickSize(), n.PriceMode))
+}
+
+type NormalizeReq struct {
+	Symbol           string            `json:"symbol"`
+	Type             binance.OrderType `json:"type"`
+	Quantity         string            `json:"quantity"`
+	QuoteOrderQty    string            `json:"quoteOrderQty"`
+	Price            string            `json:"price"`
+	QuantityRounding RoundingMode      `json:"quantityRounding"` // default DOWN
+	PriceRounding    RoundingMode      `json:"priceRounding"`    // default DOWN
+}
+
+type NormalizeResp struct {
+	Quantity      *Normalized `json:"quantity,omitempty"`
+	QuoteOrderQty *Normalized `json:"quoteOrderQty,omitempty"`
+	Price         *Normalized `json:"price,omitempty"`
+}
+
+// Normalize round the non empty quantity, quoteOrderQty and price of req for req.Symbol
+func (c *SpotClient) Normalize(ctx context.Context, req *NormalizeReq) (*NormalizeResp, error) {
+	symbol, err := c.registry.Symbol(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	n, err := symbol.Normalizer()
+	if err != nil {
+		return nil, err
+	}
+	if req.QuantityRounding != "" {
+		n.QuantityMode = req.QuantityRounding
+	}
+	if req.PriceRounding != "" {
+		n.PriceMode = req.PriceRounding
+	}
+
+	var resp NormalizeResp
+	for _, field := range []struct {
+		name   string
+		value  string
+		round  func(Decimal) Normalized
+		target **Normalized
+	}{
+		{"quantity", req.Quantity, func(d Decimal) Normalized { return n.Quantity(d, req.Type) }, &resp.Quantity},
+		{"quoteOrderQty", req.QuoteOrderQty, n.QuoteQuantity, &resp.QuoteOrderQty},
+		{"price", req.Price, n.Price, &resp.Price},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := ParseDecimal(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", field.name, err)
+		}
+		rounded := field.round(d)
+		*field.target = &rounded
+	}
+	return &resp, nil
+}
@@ -0,0 +1,80 @@
+package convert
+
+import (
+	"context"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestDecimalRoundStep(t *testing.T) {
+	convey.Convey("TestDecimalRoundStep", t, func(convCtx convey.C) {
+		step := MustDecimal("0.05")
+		for _, c := range []struct {
+			value string
+			mode  RoundingMode
+			want  string
+		}{
+			{"1.23", RoundDown, "1.2"},
+			{"1.23", "", "1.2"},
+			{"1.23", RoundUp, "1.25"},
+			{"1.23", RoundHalfUp, "1.25"},
+			{"1.225", RoundHalfUp, "1.25"},
+			{"1.225", RoundHalfEven, "1.2"},
+			{"1.275", RoundHalfEven, "1.3"},
+			{"1.20", RoundUp, "1.2"},
+			{"-1.23", RoundDown, "-1.2"},
+			{"-1.23", RoundUp, "-1.25"},
+		} {
+			convCtx.So(MustDecimal(c.value).RoundStep(step, c.mode).String(), convey.ShouldEqual, c.want)
+		}
+		convCtx.So(MustDecimal("1.23").RoundStep(Zero, RoundUp).String(), convey.ShouldEqual, "1.23")
+		convCtx.So(MustDecimal("0.123456789").Round(8, RoundHalfUp).String(), convey.ShouldEqual, "0.12345679")
+	})
+}
+
+func TestNormalizer(t *testing.T) {
+	convey.Convey("TestNormalizer", t, func(convCtx convey.C) {
+		n, err := NewNormalizer(&binance.Symbol{Symbol: "BTCUSDT", Filters: testFilters})
+		convCtx.So(err, convey.ShouldBeNil)
+
+		q := n.Quantity(MustDecimal("0.123456"), binance.OrderTypeLimit)
+		convCtx.So(q.Value.String(), convey.ShouldEqual, "0.1234")
+		convCtx.So(q.Dust.String(), convey.ShouldEqual, "0.000056")
+
+		// MARKET_LOT_SIZE stepSize is 0, fall back to LOT_SIZE
+		convCtx.So(n.QuantityStep(binance.OrderTypeMarket).String(), convey.ShouldEqual, "0.0001")
+
+		n.PriceMode = RoundHalfUp
+		p := n.Price(MustDecimal("20000.005"))
+		convCtx.So(p.Value.String(), convey.ShouldEqual, "20000.01")
+		convCtx.So(p.Dust.String(), convey.ShouldEqual, "-0.005")
+		convCtx.So(n.QuoteQuantity(MustDecimal("1.123456789")).Value.String(), convey.ShouldEqual, "1.12345678")
+
+		cli := NewSpotClientWithExchange(newTestFakeExchange())
+		resp, err := cli.Normalize(context.Background(), &NormalizeReq{
+			Symbol: "LUNCBUSD", Type: "LIMIT", Quantity: "100000.6", Price: "0.000200015", QuantityRounding: RoundHalfUp,
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Quantity.Value.String(), convey.ShouldEqual, "100001")
+		convCtx.So(resp.Price.Value.String(), convey.ShouldEqual, "0.00020001")
+		convCtx.So(resp.Price.Dust.String(), convey.ShouldEqual, "0.000000005")
+		convCtx.So(resp.QuoteOrderQty, convey.ShouldBeNil)
+	})
+}
+
+func TestTradeRounding(t *testing.T) {
+	convey.Convey("TestTradeRounding", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+
+		_, err := cli.Trade(context.Background(), &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(err, convey.ShouldBeNil)
+
+		resp, err := cli.Trade(context.Background(), &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "60000.75"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Dust, convey.ShouldEqual, "0.75")
+		convCtx.So(resp.ExecutedQuantity, convey.ShouldEqual, "60000.00000000")
+		convCtx.So(resp.CummulativeQuoteQuantity, convey.ShouldEqual, "12.00000000")
+	})
+}
@@ -0,0 +1,296 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+)
+
+/*
+OCO (One-Cancels-the-Other) 订单列表:
+
+一个 LIMIT_MAKER 止盈单 (price) 加一个 STOP_LOSS / STOP_LOSS_LIMIT 止损单 (stopPrice, stopLimitPrice),
+其中一个成交或触发后另一个自动过期.
+SELL: price > 市价 > stopPrice
+BUY:  price < 市价 < stopPrice
+*/
+
+type OCOReq struct {
+	Symbol               string                   `json:"symbol"`
+	Side                 binance.SideType         `json:"side"`
+	Quantity             string                   `json:"quantity"`       // base asset amount of both legs
+	Price                string                   `json:"price"`          // limit maker leg
+	StopPrice            string                   `json:"stopPrice"`      // stop leg trigger
+	StopLimitPrice       string                   `json:"stopLimitPrice"` // empty places a STOP_LOSS stop leg
+	StopLimitTimeInForce binance.TimeInForceType  `json:"stopLimitTimeInForce"`
+	ListClientOrderId    string                   `json:"listClientOrderId"`
+	LimitClientOrderId   string                   `json:"limitClientOrderId"`
+	StopClientOrderId    string                   `json:"stopClientOrderId"`
+	NewOrderRespType     binance.NewOrderRespType `json:"newOrderRespType"`
+	Rounding             RoundingMode             `json:"rounding"`      // quantity rounding, default DOWN
+	PriceRounding        RoundingMode             `json:"priceRounding"` // price rounding, default DOWN
+}
+
+type OCOOrder struct {
+	Symbol        string `json:"symbol"`
+	OrderID       int64  `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+}
+
+type OCOOrderReport struct {
+	Symbol                   string                  `json:"symbol"`
+	OrderID                  int64                   `json:"orderId"`
+	OrderListID              int64                   `json:"orderListId"`
+	ClientOrderID            string                  `json:"clientOrderId"`
+	TransactionTime          int64                   `json:"transactionTime"`
+	Price                    string                  `json:"price"`
+	OrigQuantity             string                  `json:"origQty"`
+	ExecutedQuantity         string                  `json:"executedQty"`
+	CummulativeQuoteQuantity string                  `json:"cummulativeQuoteQty"`
+	Status                   binance.OrderStatusType `json:"status"`
+	TimeInForce              binance.TimeInForceType `json:"timeInForce"`
+	Type                     binance.OrderType       `json:"type"`
+	Side                     binance.SideType        `json:"side"`
+	StopPrice                string                  `json:"stopPrice"`
+}
+
+// OCOResp order list, OrderReports is only filled by place and cancel
+type OCOResp struct {
+	OrderListId       int64             `json:"orderListId"`
+	ContingencyType   string            `json:"contingencyType"`
+	ListStatusType    string            `json:"listStatusType"`  // RESPONSE EXEC_STARTED ALL_DONE
+	ListOrderStatus   string            `json:"listOrderStatus"` // EXECUTING ALL_DONE REJECT
+	ListClientOrderId string            `json:"listClientOrderId"`
+	TransactionTime   int64             `json:"transactionTime"`
+	Symbol            string            `json:"symbol"`
+	Orders            []*OCOOrder       `json:"orders"`
+	OrderReports      []*OCOOrderReport `json:"orderReports"`
+	Dust              string            `json:"dust,omitempty"` // part of req.Quantity dropped by rounding
+}
+
+func newOCOOrders(orders []*binance.OCOOrder) []*OCOOrder {
+	var resp []*OCOOrder
+	for _, order := range orders {
+		resp = append(resp, &OCOOrder{Symbol: order.Symbol, OrderID: order.OrderID, ClientOrderID: order.ClientOrderID})
+	}
+	return resp
+}
+
+func newOCOOrderReports(reports []*binance.OCOOrderReport) []*OCOOrderReport {
+	var resp []*OCOOrderReport
+	for _, r := range reports {
+		resp = append(resp, &OCOOrderReport{
+			Symbol:                   r.Symbol,
+			OrderID:                  r.OrderID,
+			OrderListID:              r.OrderListID,
+			ClientOrderID:            r.ClientOrderID,
+			TransactionTime:          r.TransactionTime,
+			Price:                    r.Price,
+			OrigQuantity:             r.OrigQuantity,
+			ExecutedQuantity:         r.ExecutedQuantity,
+			CummulativeQuoteQuantity: r.CummulativeQuoteQuantity,
+			Status:                   r.Status,
+			TimeInForce:              r.TimeInForce,
+			Type:                     r.Type,
+			Side:                     r.Side,
+			StopPrice:                r.StopPrice,
+		})
+	}
+	return resp
+}
+
+func newOCOResp(list *binance.Oco) *OCOResp {
+	resp := &OCOResp{
+		OrderListId:       list.OrderListId,
+		ContingencyType:   list.ContingencyType,
+		ListStatusType:    list.ListStatusType,
+		ListOrderStatus:   list.ListOrderStatus,
+		ListClientOrderId: list.ListClientOrderID,
+		TransactionTime:   list.TransactionTime,
+		Symbol:            list.Symbol,
+	}
+	for _, order := range list.Orders {
+		resp.Orders = append(resp.Orders, &OCOOrder{Symbol: order.Symbol, OrderID: order.OrderID, ClientOrderID: order.ClientOrderID})
+	}
+	return resp
+}
+
+// CreateOCO place a take-profit / stop-loss bracket, both legs are normalized and checked against the symbol filters
+func (c *SpotClient) CreateOCO(ctx context.Context, req *OCOReq) (*OCOResp, error) {
+	symbol, err := c.registry.Symbol(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if err = symbol.checkTrading(); err != nil {
+		return nil, err
+	}
+	if !symbol.OcoAllowed {
+		return nil, errors.New(fmt.Sprintf("%s does not allow OCO orders", req.Symbol))
+	}
+	normalizer, err := symbol.Normalizer()
+	if err != nil {
+		return nil, err
+	}
+	if req.Rounding != "" {
+		normalizer.QuantityMode = req.Rounding
+	}
+	if req.PriceRounding != "" {
+		normalizer.PriceMode = req.PriceRounding
+	}
+
+	var prices [3]Decimal
+	for i, field := range []struct {
+		name  string
+		value string
+	}{{"price", req.Price}, {"stopPrice", req.StopPrice}, {"stopLimitPrice", req.StopLimitPrice}} {
+		if field.value == "" {
+			if field.name == "stopLimitPrice" {
+				continue
+			}
+			return nil, errors.New(fmt.Sprintf("%s is required", field.name))
+		}
+		d, err := ParseDecimal(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", field.name, err)
+		}
+		prices[i] = normalizer.Price(d).Value
+	}
+	price, stopPrice, stopLimitPrice := prices[0], prices[1], prices[2]
+
+	dQuantity, err := ParseDecimal(req.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("quantity %w", err)
+	}
+	quantity := normalizer.Quantity(dQuantity, binance.OrderTypeLimit)
+
+	avgPrice, err := c.avgPrice(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	stopType := binance.OrderTypeStopLoss
+	if !stopLimitPrice.IsZero() {
+		stopType = binance.OrderTypeStopLossLimit
+	}
+	limitCheck := &OrderCheck{Side: req.Side, Type: binance.OrderTypeLimitMaker, Quantity: quantity.Value, Price: price, AvgPrice: avgPrice}
+	if err = c.accountCheck(ctx, symbol, limitCheck); err != nil {
+		return nil, err
+	}
+	// the stop leg is the second order of the list, the limit leg already counts toward MAX_NUM_ORDERS
+	stopCheck := *limitCheck
+	stopCheck.Type, stopCheck.Price, stopCheck.StopPrice = stopType, stopLimitPrice, stopPrice
+	stopCheck.OpenOrders++
+	var violations FilterViolations
+	violations = append(violations, normalizer.Filters.Validate(limitCheck)...)
+	violations = append(violations, normalizer.Filters.Validate(&stopCheck)...)
+	if len(violations) > 0 {
+		return nil, violations
+	}
+
+	params := &CreateOCOParams{
+		Symbol:             req.Symbol,
+		Side:               req.Side,
+		Quantity:           quantity.Value.String(),
+		Price:              price.String(),
+		StopPrice:          stopPrice.String(),
+		ListClientOrderId:  req.ListClientOrderId,
+		LimitClientOrderId: req.LimitClientOrderId,
+		StopClientOrderId:  req.StopClientOrderId,
+		NewOrderRespType:   req.NewOrderRespType,
+	}
+	if !stopLimitPrice.IsZero() {
+		params.StopLimitPrice = stopLimitPrice.String()
+		params.StopLimitTimeInForce = req.StopLimitTimeInForce
+		if params.StopLimitTimeInForce == "" {
+			params.StopLimitTimeInForce = binance.TimeInForceTypeGTC
+		}
+	}
+
+	res, err := c.exchange.CreateOCO(ctx, params)
+	if err != nil {
+		c.registry.InvalidateOnError(err)
+		return nil, err
+	}
+
+	return &OCOResp{
+		OrderListId:       res.OrderListID,
+		ContingencyType:   res.ContingencyType,
+		ListStatusType:    res.ListStatusType,
+		ListOrderStatus:   res.ListOrderStatus,
+		ListClientOrderId: res.ListClientOrderID,
+		TransactionTime:   res.TransactionTime,
+		Symbol:            res.Symbol,
+		Orders:            newOCOOrders(res.Orders),
+		OrderReports:      newOCOOrderReports(res.OrderReports),
+		Dust:              quantity.Dust.String(),
+	}, nil
+}
+
+type GetOCOReq struct {
+	OrderListId       int64  `json:"orderListId"`
+	ListClientOrderId string `json:"listClientOrderId"`
+}
+
+// GetOCO query an order list by orderListId (GetOrderResp.OrderListId) or listClientOrderId
+func (c *SpotClient) GetOCO(ctx context.Context, req *GetOCOReq) (*OCOResp, error) {
+	if req.OrderListId <= 0 && req.ListClientOrderId == "" {
+		return nil, errors.New("orderListId or listClientOrderId is required")
+	}
+	list, err := c.exchange.GetOrderList(ctx, &QueryOrderListParams{OrderListId: req.OrderListId, ListClientOrderId: req.ListClientOrderId})
+	if err != nil {
+		return nil, err
+	}
+	return newOCOResp(list), nil
+}
+
+type OpenOCOListResp struct {
+	Data []*OCOResp `json:"data"`
+}
+
+// OpenOCOList every open order list of the account
+func (c *SpotClient) OpenOCOList(ctx context.Context) (*OpenOCOListResp, error) {
+	lists, err := c.exchange.ListOpenOrderLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []*OCOResp
+	for _, list := range lists {
+		resp = append(resp, newOCOResp(list))
+	}
+
+	return &OpenOCOListResp{Data: resp}, nil
+}
+
+type CancelOCOReq struct {
+	Symbol            string `json:"symbol"`
+	OrderListId       int64  `json:"orderListId"`
+	ListClientOrderId string `json:"listClientOrderId"`
+}
+
+// CancelOCO cancel every leg of an order list
+func (c *SpotClient) CancelOCO(ctx context.Context, req *CancelOCOReq) (*OCOResp, error) {
+	if req.OrderListId <= 0 && req.ListClientOrderId == "" {
+		return nil, errors.New("orderListId or listClientOrderId is required")
+	}
+	res, err := c.exchange.CancelOrderList(ctx, &QueryOrderListParams{
+		Symbol:            req.Symbol,
+		OrderListId:       req.OrderListId,
+		ListClientOrderId: req.ListClientOrderId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OCOResp{
+		OrderListId:       res.OrderListID,
+		ContingencyType:   res.ContingencyType,
+		ListStatusType:    res.ListStatusType,
+		ListOrderStatus:   res.ListOrderStatus,
+		ListClientOrderId: res.ListClientOrderID,
+		TransactionTime:   res.TransactionTime,
+		Symbol:            res.Symbol,
+		Orders:            newOCOOrders(res.Orders),
+		OrderReports:      newOCOOrderReports(res.OrderReports),
+	}, nil
+}
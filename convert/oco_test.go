@@ -0,0 +1,97 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestOCO(t *testing.T) {
+	convey.Convey("TestOCO", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		cli := NewSpotClient(server.Client())
+		ctx := context.Background()
+
+		_, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(err, convey.ShouldBeNil)
+
+		oco, err := cli.CreateOCO(ctx, &OCOReq{
+			Symbol: "LUNCBUSD", Side: "SELL", Quantity: "100000.3",
+			Price: "0.00025", StopPrice: "0.00018", StopLimitPrice: "0.000179",
+			ListClientOrderId: "bracket-1",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(oco.ListStatusType, convey.ShouldEqual, "EXEC_STARTED")
+		convCtx.So(oco.Dust, convey.ShouldEqual, "0.3")
+		convCtx.So(len(oco.Orders), convey.ShouldEqual, 2)
+		convCtx.So(oco.OrderReports[0].Type, convey.ShouldEqual, "STOP_LOSS_LIMIT")
+		convCtx.So(oco.OrderReports[0].TimeInForce, convey.ShouldEqual, "GTC")
+		convCtx.So(oco.OrderReports[1].Type, convey.ShouldEqual, "LIMIT_MAKER")
+
+		order, err := cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: oco.Orders[1].OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.OrderListId, convey.ShouldEqual, oco.OrderListId)
+
+		list, err := cli.GetOCO(ctx, &GetOCOReq{OrderListId: order.OrderListId})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(list.ListClientOrderId, convey.ShouldEqual, "bracket-1")
+		convCtx.So(list.Orders[0].OrderID, convey.ShouldEqual, oco.Orders[0].OrderID)
+
+		list, err = cli.GetOCO(ctx, &GetOCOReq{ListClientOrderId: "bracket-1"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(list.OrderListId, convey.ShouldEqual, oco.OrderListId)
+
+		open, err := cli.OpenOCOList(ctx)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(open.Data), convey.ShouldEqual, 1)
+
+		canceled, err := cli.CancelOCO(ctx, &CancelOCOReq{Symbol: "LUNCBUSD", OrderListId: oco.OrderListId})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(canceled.ListOrderStatus, convey.ShouldEqual, "ALL_DONE")
+		convCtx.So(canceled.OrderReports[0].Status, convey.ShouldEqual, "CANCELED")
+		convCtx.So(canceled.OrderReports[1].Status, convey.ShouldEqual, "CANCELED")
+
+		open, err = cli.OpenOCOList(ctx)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(open.Data), convey.ShouldEqual, 0)
+
+		_, err = cli.CancelOCO(ctx, &CancelOCOReq{Symbol: "LUNCBUSD", OrderListId: oco.OrderListId})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -2011)
+
+		// the take profit leg fills, the stop leg expires
+		oco, err = cli.CreateOCO(ctx, &OCOReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "100000", Price: "0.00025", StopPrice: "0.00018"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(oco.OrderReports[0].Type, convey.ShouldEqual, "STOP_LOSS")
+		fake.SetBookTicker("LUNCBUSD", "0.00026000", "1000000.00", "0.00026010", "1000000.00")
+		list, err = cli.GetOCO(ctx, &GetOCOReq{OrderListId: oco.OrderListId})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(list.ListStatusType, convey.ShouldEqual, "ALL_DONE")
+		stop, _ := cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: oco.Orders[0].OrderID})
+		convCtx.So(stop.Status, convey.ShouldEqual, "EXPIRED")
+		limit, _ := cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: oco.Orders[1].OrderID})
+		convCtx.So(limit.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(fake.Balance("BUSD").String(), convey.ShouldEqual, "104.99")
+
+		_, err = cli.CreateOCO(ctx, &OCOReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "100000", Price: "0.00027", StopPrice: "0.00028"})
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -2010)
+
+		_, err = cli.CreateOCO(ctx, &OCOReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "10", Price: "0.0003", StopPrice: "0.0002"})
+		convCtx.So(errors.Is(err, ErrFilterViolation), convey.ShouldBeTrue)
+
+		_, err = cli.CreateOCO(ctx, &OCOReq{Symbol: "EOSBTC", Side: "SELL", Quantity: "1", Price: "0.0001", StopPrice: "0.00005"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		var paths []string
+		for _, req := range server.Requests() {
+			paths = append(paths, req.Method+" "+req.Path)
+		}
+		convCtx.So(paths, convey.ShouldContain, "GET /api/v3/openOrderList")
+		convCtx.So(paths, convey.ShouldContain, "GET /api/v3/orderList")
+	})
+}
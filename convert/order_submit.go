@@ -0,0 +1,259 @@
+package convert
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+幂等下单:
+
+每个订单都带 newClientOrderId (没有就自动生成), 下单结果不明确时 (超时, 连接断开, 5xx, -1007 等)
+先用 origClientOrderId 查询订单是否已经存在: 存在则直接返回这个订单 (Recovered).
+查不到 (-2013) 不代表没下单, 请求可能还在路上, binance 在 timestamp + recvWindow 之前都可能执行它;
+所以要等到发出请求后 RecvWindow (recvWindow 加上允许的时钟偏差) 之后再查一次, 仍然查不到才用同一个 clientOrderId 重新下单.
+查询本身失败, 或者等待期间 ctx 结束时不再重试, 返回 ErrOrderStatusUnknown, 保证同一个请求最多下一单.
+同一个 clientOrderId 的订单仍在挂单时 binance 会拒绝重复下单 (Duplicate order sent), 也按已存在处理.
+ctx 在请求途中被取消时仍然 (不等 QueryDelay) 查一次, 已经下了的单能找回来.
+*/
+
+var ErrOrderStatusUnknown = errors.New("order status unknown")
+
+// OrderStatusUnknownError the order may or may not exist, look it up by ClientOrderId before placing it again
+type OrderStatusUnknownError struct {
+	Symbol        string `json:"symbol"`
+	ClientOrderId string `json:"clientOrderId"`
+	Err           error  `json:"-"` // the ambiguous placement error
+	QueryErr      error  `json:"-"` // why the lookup did not settle it
+}
+
+func (e *OrderStatusUnknownError) Error() string {
+	return fmt.Sprintf("%s order %s status unknown: %v, lookup: %v", e.Symbol, e.ClientOrderId, e.Err, e.QueryErr)
+}
+
+func (e *OrderStatusUnknownError) Is(target error) bool {
+	return target == ErrOrderStatusUnknown
+}
+
+func (e *OrderStatusUnknownError) Unwrap() error {
+	return e.Err
+}
+
+type OrderRetryConfig struct {
+	MaxAttempts         int           // placements, default 3, 1 never places again
+	RetryDelay          time.Duration // before placing again, default 500ms
+	QueryDelay          time.Duration // before looking an ambiguous order up, default 1s
+	QueryAttempts       int           // lookups before the status is declared unknown, default 3
+	QueryTimeout        time.Duration // per lookup, the first lookup still runs once ctx is canceled, default 10s
+	RecvWindow          time.Duration // how long after sending binance may still execute a placement, default 6s (recvWindow 5s plus 1s clock skew)
+	ClientOrderIdPrefix string        // generated clientOrderIds, default "bt", at most 10 of [a-zA-Z0-9-_]
+}
+
+var DefaultOrderRetry = OrderRetryConfig{
+	MaxAttempts:         3,
+	RetryDelay:          500 * time.Millisecond,
+	QueryDelay:          time.Second,
+	QueryAttempts:       3,
+	QueryTimeout:        10 * time.Second,
+	RecvWindow:          6 * time.Second,
+	ClientOrderIdPrefix: "bt",
+}
+
+var clientOrderIdPrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9-_]{0,10}$`)
+
+// ambiguousOrderCodes binance errors after which the order may exist. 5xx gateway pages carry no json, code 0
+var ambiguousOrderCodes = map[int64]bool{
+	0:     true,
+	-1000: true, // UNKNOWN
+	-1001: true, // DISCONNECTED
+	-1006: true, // UNEXPECTED_RESP, execution status unknown
+	-1007: true, // TIMEOUT, execution status unknown
+}
+
+// SetOrderRetry zero fields keep their DefaultOrderRetry value
+func (c *SpotClient) SetOrderRetry(cfg OrderRetryConfig) error {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultOrderRetry.MaxAttempts
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultOrderRetry.RetryDelay
+	}
+	if cfg.QueryDelay <= 0 {
+		cfg.QueryDelay = DefaultOrderRetry.QueryDelay
+	}
+	if cfg.QueryAttempts <= 0 {
+		cfg.QueryAttempts = DefaultOrderRetry.QueryAttempts
+	}
+	if cfg.QueryTimeout <= 0 {
+		cfg.QueryTimeout = DefaultOrderRetry.QueryTimeout
+	}
+	if cfg.RecvWindow <= 0 {
+		cfg.RecvWindow = DefaultOrderRetry.RecvWindow
+	}
+	if cfg.ClientOrderIdPrefix == "" {
+		cfg.ClientOrderIdPrefix = DefaultOrderRetry.ClientOrderIdPrefix
+	}
+	if !clientOrderIdPrefixPattern.MatchString(cfg.ClientOrderIdPrefix) {
+		return errors.New(fmt.Sprintf("invalid clientOrderIdPrefix %q", cfg.ClientOrderIdPrefix))
+	}
+	c.retry = cfg
+	return nil
+}
+
+// NewClientOrderId prefix, base 36 milliseconds and 64 random bits, at most 35 characters
+func (c *SpotClient) NewClientOrderId() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("clientOrderId %w", err)
+	}
+	return c.retry.ClientOrderIdPrefix + strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 36) + hex.EncodeToString(b[:]), nil
+}
+
+func isAmbiguousOrderError(err error) bool {
+	// refused by the limiter, never sent
+	if errors.Is(err, ErrRateLimited) {
+		return false
+	}
+	var apiErr *common.APIError
+	if errors.As(err, &apiErr) {
+		return ambiguousOrderCodes[apiErr.Code]
+	}
+	// timeouts, resets, a ctx canceled in flight
+	return true
+}
+
+func isDuplicateOrder(err error) bool {
+	var apiErr *common.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == -2010 && strings.Contains(apiErr.Message, "Duplicate order")
+}
+
+func isOrderNotFound(err error) bool {
+	var apiErr *common.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == -2013
+}
+
+// orderPlacement result of placeOrder
+type orderPlacement struct {
+	order     *binance.CreateOrderResponse
+	attempts  int
+	recovered bool // found by origClientOrderId after an ambiguous failure, without fills
+}
+
+// placeOrder create params at most once, see the comment at the top of the file
+func (c *SpotClient) placeOrder(ctx context.Context, params *CreateOrderParams) (*orderPlacement, error) {
+	if params.NewClientOrderId == "" {
+		clientOrderId, err := c.NewClientOrderId()
+		if err != nil {
+			return nil, err
+		}
+		params.NewClientOrderId = clientOrderId
+	}
+	for attempt := 1; ; attempt++ {
+		sentAt := time.Now()
+		order, err := c.exchange.CreateOrder(ctx, params)
+		if err == nil {
+			return &orderPlacement{order: order, attempts: attempt}, nil
+		}
+		// a resting order with our id is the one an earlier attempt placed
+		duplicate := attempt > 1 && isDuplicateOrder(err)
+		if !duplicate && !isAmbiguousOrderError(err) {
+			c.registry.InvalidateOnError(err)
+			return nil, err
+		}
+
+		var existing *binance.Order
+		var queryErr error
+		if duplicate {
+			existing, queryErr = c.lookupOrder(ctx, params)
+		} else {
+			existing, queryErr = c.settleOrder(ctx, params, sentAt)
+		}
+		if queryErr == nil {
+			return &orderPlacement{order: orderResponse(existing), attempts: attempt, recovered: true}, nil
+		}
+		if !isOrderNotFound(queryErr) || duplicate {
+			return nil, &OrderStatusUnknownError{Symbol: params.Symbol, ClientOrderId: params.NewClientOrderId, Err: err, QueryErr: queryErr}
+		}
+		// definitely not placed
+		if attempt >= c.retry.MaxAttempts {
+			return nil, fmt.Errorf("order %s not placed after %d attempts %w", params.NewClientOrderId, attempt, err)
+		}
+		timer := time.NewTimer(c.retry.RetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("order %s not placed %w", params.NewClientOrderId, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// settleOrder look up an ambiguous placement sent at sentAt. Not found (-2013) is only returned
+// by a lookup made after RecvWindow, when binance no longer executes the request
+func (c *SpotClient) settleOrder(ctx context.Context, params *CreateOrderParams, sentAt time.Time) (*binance.Order, error) {
+	order, err := c.lookupOrder(ctx, params)
+	if !isOrderNotFound(err) {
+		return order, err
+	}
+	wait := time.Until(sentAt.Add(c.retry.RecvWindow))
+	if wait <= 0 {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(wait):
+	}
+	return c.lookupOrder(ctx, params)
+}
+
+// lookupOrder query params.NewClientOrderId after QueryDelay, on a context of its own so a placement
+// canceled in flight is still looked up once, right away. Lookups failing ambiguously are tried
+// QueryAttempts times while ctx is not done
+func (c *SpotClient) lookupOrder(ctx context.Context, params *CreateOrderParams) (*binance.Order, error) {
+	var err error
+	for i := 0; i < c.retry.QueryAttempts; i++ {
+		select {
+		case <-ctx.Done():
+			if i > 0 {
+				return nil, ctx.Err()
+			}
+		case <-time.After(c.retry.QueryDelay):
+		}
+		queryCtx, cancel := context.WithTimeout(context.Background(), c.retry.QueryTimeout)
+		var order *binance.Order
+		order, err = c.exchange.GetOrder(queryCtx, &QueryOrderParams{Symbol: params.Symbol, OrigClientOrderId: params.NewClientOrderId})
+		cancel()
+		if err == nil || isOrderNotFound(err) || !isAmbiguousOrderError(err) {
+			return order, err
+		}
+	}
+	return nil, err
+}
+
+func orderResponse(order *binance.Order) *binance.CreateOrderResponse {
+	return &binance.CreateOrderResponse{
+		Symbol:                   order.Symbol,
+		OrderID:                  order.OrderID,
+		ClientOrderID:            order.ClientOrderID,
+		TransactTime:             order.Time,
+		Price:                    order.Price,
+		OrigQuantity:             order.OrigQuantity,
+		ExecutedQuantity:         order.ExecutedQuantity,
+		CummulativeQuoteQuantity: order.CummulativeQuoteQuantity,
+		IsIsolated:               order.IsIsolated,
+		Status:                   order.Status,
+		TimeInForce:              order.TimeInForce,
+		Type:                     order.Type,
+		Side:                     order.Side,
+	}
+}
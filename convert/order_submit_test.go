@@ -0,0 +1,231 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyExchange fails CreateOrder and GetOrder with the queued errors, placed=true still creates the order first,
+// late=true creates it just before the second lookup
+type flakyExchange struct {
+	Exchange
+
+	mu      sync.Mutex
+	creates []flakyCall
+	gets    []error
+	late    *CreateOrderParams
+	created int
+	queried int
+}
+
+type flakyCall struct {
+	err    error
+	placed bool
+	late   bool
+}
+
+func (e *flakyExchange) CreateOrder(ctx context.Context, params *CreateOrderParams) (*binance.CreateOrderResponse, error) {
+	e.mu.Lock()
+	e.created++
+	var call *flakyCall
+	if len(e.creates) > 0 {
+		call = &e.creates[0]
+		e.creates = e.creates[1:]
+	}
+	e.mu.Unlock()
+	if call == nil {
+		return e.Exchange.CreateOrder(ctx, params)
+	}
+	if call.placed {
+		if _, err := e.Exchange.CreateOrder(ctx, params); err != nil {
+			return nil, err
+		}
+	}
+	if call.late {
+		e.mu.Lock()
+		e.late = params
+		e.mu.Unlock()
+	}
+	return nil, call.err
+}
+
+func (e *flakyExchange) GetOrder(ctx context.Context, params *QueryOrderParams) (*binance.Order, error) {
+	e.mu.Lock()
+	e.queried++
+	var err error
+	if len(e.gets) > 0 {
+		err, e.gets = e.gets[0], e.gets[1:]
+	}
+	late := e.late
+	if late != nil && e.queried == 2 {
+		e.late = nil
+	} else {
+		late = nil
+	}
+	e.mu.Unlock()
+	if late != nil {
+		if _, err := e.Exchange.CreateOrder(ctx, late); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e.Exchange.GetOrder(ctx, params)
+}
+
+func newTestFlakyClient() (*SpotClient, *flakyExchange, *FakeExchange) {
+	fake := newTestFakeExchange()
+	flaky := &flakyExchange{Exchange: fake}
+	cli := NewSpotClientWithExchange(flaky)
+	if err := cli.SetOrderRetry(OrderRetryConfig{RetryDelay: time.Millisecond, QueryDelay: time.Millisecond, RecvWindow: time.Millisecond}); err != nil {
+		panic(err)
+	}
+	return cli, flaky, fake
+}
+
+func TestPlaceOrder(t *testing.T) {
+	convey.Convey("TestPlaceOrder", t, func(convCtx convey.C) {
+		ctx := context.Background()
+		timeout := &common.APIError{Code: -1007, Message: "Timeout waiting for response from backend server. Send status unknown; execution status unknown."}
+
+		// placed, but the response was lost
+		cli, flaky, fake := newTestFlakyClient()
+		flaky.creates = []flakyCall{{err: context.DeadlineExceeded, placed: true}}
+		resp, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Recovered, convey.ShouldBeTrue)
+		convCtx.So(resp.Attempts, convey.ShouldEqual, 1)
+		convCtx.So(resp.Status, convey.ShouldEqual, binance.OrderStatusTypeFilled)
+		convCtx.So(strings.HasPrefix(resp.ClientOrderID, "bt"), convey.ShouldBeTrue)
+		convCtx.So(flaky.created, convey.ShouldEqual, 1)
+		convCtx.So(fake.Balance("BUSD").String(), convey.ShouldEqual, "79.99")
+
+		// rejected: no lookup, no retry
+		cli, flaky, _ = newTestFlakyClient()
+		flaky.creates = []flakyCall{{err: &common.APIError{Code: -2010, Message: "Account has insufficient balance for requested action."}}}
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -2010)
+		convCtx.So(flaky.created, convey.ShouldEqual, 1)
+		convCtx.So(flaky.queried, convey.ShouldEqual, 0)
+
+		// the lookup never settles: no second placement
+		cli, flaky, _ = newTestFlakyClient()
+		flaky.creates = []flakyCall{{err: timeout}}
+		flaky.gets = []error{timeout, timeout, timeout}
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01", NewClientOrderId: "my-order-1"})
+		convCtx.So(errors.Is(err, ErrOrderStatusUnknown), convey.ShouldBeTrue)
+		var unknown *OrderStatusUnknownError
+		convCtx.So(errors.As(err, &unknown), convey.ShouldBeTrue)
+		convCtx.So(unknown.ClientOrderId, convey.ShouldEqual, "my-order-1")
+		convCtx.So(flaky.created, convey.ShouldEqual, 1)
+		convCtx.So(flaky.queried, convey.ShouldEqual, 3)
+
+		// a canceled ctx skips QueryDelay, looks up once and does not wait out the recvWindow
+		cli, flaky, _ = newTestFlakyClient()
+		convCtx.So(cli.SetOrderRetry(OrderRetryConfig{QueryDelay: time.Hour, RecvWindow: time.Hour}), convey.ShouldBeNil)
+		flaky.creates = []flakyCall{{err: timeout}}
+		cancelCtx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(10*time.Millisecond, cancel)
+		began := time.Now()
+		_, err = cli.Trade(cancelCtx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(time.Since(began), convey.ShouldBeLessThan, time.Minute)
+		convCtx.So(errors.As(err, &unknown), convey.ShouldBeTrue)
+		convCtx.So(errors.Is(unknown.QueryErr, context.Canceled), convey.ShouldBeTrue)
+		convCtx.So(flaky.queried, convey.ShouldEqual, 1)
+		convCtx.So(flaky.created, convey.ShouldEqual, 1)
+
+		// canceled in flight after the order was placed: the lookup on its own context recovers it
+		cli, flaky, fake = newTestFlakyClient()
+		convCtx.So(cli.SetOrderRetry(OrderRetryConfig{QueryDelay: time.Hour}), convey.ShouldBeNil)
+		flaky.creates = []flakyCall{{err: context.Canceled, placed: true}}
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		resp, err = cli.Trade(canceled, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Recovered, convey.ShouldBeTrue)
+		convCtx.So(flaky.queried, convey.ShouldEqual, 1)
+
+		// the order shows up after a lookup found nothing: the lookup after the recvWindow finds it, no second order
+		cli, flaky, fake = newTestFlakyClient()
+		convCtx.So(cli.SetOrderRetry(OrderRetryConfig{QueryDelay: time.Millisecond, RecvWindow: 50 * time.Millisecond}), convey.ShouldBeNil)
+		flaky.creates = []flakyCall{{err: timeout, late: true}}
+		resp, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Recovered, convey.ShouldBeTrue)
+		convCtx.So(resp.Attempts, convey.ShouldEqual, 1)
+		convCtx.So(flaky.created, convey.ShouldEqual, 1)
+		convCtx.So(flaky.queried, convey.ShouldEqual, 2)
+		convCtx.So(fake.Balance("BUSD").String(), convey.ShouldEqual, "79.99")
+
+		// never placed within MaxAttempts
+		cli, flaky, _ = newTestFlakyClient()
+		convCtx.So(cli.SetOrderRetry(OrderRetryConfig{MaxAttempts: 2, RetryDelay: time.Millisecond, QueryDelay: time.Millisecond, RecvWindow: time.Millisecond}), convey.ShouldBeNil)
+		flaky.creates = []flakyCall{{err: timeout}, {err: timeout}}
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -1007)
+		convCtx.So(flaky.created, convey.ShouldEqual, 2)
+
+		// a resting order shows up late: the retry is refused as a duplicate and the order is recovered
+		cli, flaky, fake = newTestFlakyClient()
+		flaky.creates = []flakyCall{{err: timeout, placed: true}}
+		flaky.gets = []error{&common.APIError{Code: -2013, Message: "Order does not exist."}}
+		resp, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000", Price: "0.00019"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Recovered, convey.ShouldBeTrue)
+		convCtx.So(resp.Attempts, convey.ShouldEqual, 2)
+		convCtx.So(resp.Status, convey.ShouldEqual, binance.OrderStatusTypeNew)
+		open, _ := fake.ListOpenOrders(ctx, "LUNCBUSD")
+		convCtx.So(len(open), convey.ShouldEqual, 1)
+
+		convCtx.So(cli.SetOrderRetry(OrderRetryConfig{ClientOrderIdPrefix: "bad prefix"}), convey.ShouldNotBeNil)
+		convCtx.So(cli.SetOrderRetry(OrderRetryConfig{ClientOrderIdPrefix: "0123456789"}), convey.ShouldBeNil)
+		clientOrderId, err := cli.NewClientOrderId()
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(clientOrderId), convey.ShouldBeLessThanOrEqualTo, 36)
+	})
+}
+
+func TestPlaceOrderMockServer(t *testing.T) {
+	convey.Convey("TestPlaceOrderMockServer", t, func(convCtx convey.C) {
+		server, _ := newTestMockServer()
+		defer server.Close()
+		cli := NewSpotClient(server.Client())
+		convCtx.So(cli.SetOrderRetry(OrderRetryConfig{RetryDelay: time.Millisecond, QueryDelay: time.Millisecond, RecvWindow: time.Millisecond}), convey.ShouldBeNil)
+		ctx := context.Background()
+
+		server.ScriptError(http.MethodPost, "/api/v3/order", http.StatusServiceUnavailable, -1007, "Timeout waiting for response from backend server. Send status unknown; execution status unknown.")
+		server.Script(http.MethodPost, "/api/v3/order", MockResponse{Status: http.StatusBadGateway, Body: "<html>502 Bad Gateway</html>"})
+		resp, err := cli.StopLoss(ctx, &ConditionalOrderReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "100000", StopPrice: "0.00018", Price: "0.000179"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Attempts, convey.ShouldEqual, 3)
+		convCtx.So(resp.Recovered, convey.ShouldBeFalse)
+
+		var ids []string
+		lookups := 0
+		for _, req := range server.Requests() {
+			if req.Path != "/api/v3/order" {
+				continue
+			}
+			if req.Method == http.MethodPost {
+				ids = append(ids, req.Params.Get("newClientOrderId"))
+			} else if req.Method == http.MethodGet {
+				lookups++
+				convCtx.So(req.Params.Get("origClientOrderId"), convey.ShouldEqual, resp.ClientOrderID)
+			}
+		}
+		convCtx.So(ids, convey.ShouldResemble, []string{resp.ClientOrderID, resp.ClientOrderID, resp.ClientOrderID})
+		convCtx.So(lookups, convey.ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,493 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+本地订单簿, 按 binance 文档同步 REST 快照和 diff depth 推送:
+
+1. 先缓存推送, 再拉快照 (lastUpdateId), 快照比第一条缓存的 U 还旧则重新拉
+2. 丢弃 u <= lastUpdateId 的推送
+3. 第一条应用的推送必须满足 U <= lastUpdateId+1 <= u
+4. 之后每条推送的 U 必须等于上一条的 u+1, 否则视为丢包, 重新同步
+5. 数量为 0 删除该价位
+*/
+
+var (
+	ErrOrderBookNotSynced = errors.New("order book not synced")
+	ErrInsufficientDepth  = errors.New("insufficient order book depth")
+)
+
+// maxDepthBuffer events buffered while the snapshot loads, beyond it the sync starts over
+const maxDepthBuffer = 10000
+
+type PriceLevel struct {
+	Price    Decimal `json:"price"`
+	Quantity Decimal `json:"quantity"`
+}
+
+// BookFill result of walking one side of the book
+type BookFill struct {
+	Base       Decimal `json:"base"`       // base asset traded
+	Quote      Decimal `json:"quote"`      // quote asset traded
+	AvgPrice   Decimal `json:"avgPrice"`   // quote / base, zero when nothing was filled
+	BestPrice  Decimal `json:"bestPrice"`  // first level touched
+	WorstPrice Decimal `json:"worstPrice"` // last level touched
+	Levels     int     `json:"levels"`
+	Complete   bool    `json:"complete"` // false when the book ran out before the amount
+}
+
+// avgPricePlaces precision of BookFill.AvgPrice, enough for sub-satoshi prices
+const avgPricePlaces = 16
+
+// walkLevels take liquidity from levels (best first) until amount of base or quote (kind) is reached,
+// base amounts bought with a quote amount are truncated to places
+func walkLevels(levels []PriceLevel, amount Decimal, kind QuantityKind, places int32) *BookFill {
+	fill := &BookFill{}
+	remaining := amount
+	for _, level := range levels {
+		if remaining.Sign() <= 0 {
+			break
+		}
+		base, quote := level.Quantity, level.Quantity.Mul(level.Price)
+		if kind == QuantityKindQuote {
+			if quote.GreaterThan(remaining) {
+				quote = remaining
+				base = remaining.Div(level.Price, places)
+			}
+			remaining = remaining.Sub(quote)
+		} else {
+			if base.GreaterThan(remaining) {
+				base = remaining
+				quote = base.Mul(level.Price)
+			}
+			remaining = remaining.Sub(base)
+		}
+		if fill.Levels == 0 {
+			fill.BestPrice = level.Price
+		}
+		fill.WorstPrice = level.Price
+		fill.Levels++
+		fill.Base = fill.Base.Add(base)
+		fill.Quote = fill.Quote.Add(quote)
+	}
+	fill.Complete = remaining.Sign() <= 0
+	if !fill.Base.IsZero() {
+		fill.AvgPrice = fill.Quote.Div(fill.Base, avgPricePlaces)
+	}
+	return fill
+}
+
+// OrderBook local copy of one symbol's book, kept in sync by Run
+type OrderBook struct {
+	Symbol string
+	// Limit snapshot depth, default 1000
+	Limit int
+
+	exchange Exchange
+	// reconnect delays of the snapshot, tests shorten them
+	retryMin, retryMax time.Duration
+
+	mu           sync.RWMutex
+	bids         []PriceLevel // best (highest) first
+	asks         []PriceLevel // best (lowest) first
+	lastUpdateId int64
+	synced       bool
+	applied      bool // an event was applied since the snapshot
+	resyncs      int
+	updatedAt    time.Time
+	syncedC      chan struct{}
+
+	errs chan error
+}
+
+func NewOrderBook(exchange Exchange, symbol string, limit int) *OrderBook {
+	if limit <= 0 {
+		limit = 1000
+	}
+	return &OrderBook{
+		Symbol:   symbol,
+		Limit:    limit,
+		exchange: exchange,
+		retryMin: time.Second,
+		retryMax: 30 * time.Second,
+		syncedC:  make(chan struct{}),
+		errs:     make(chan error, 64),
+	}
+}
+
+// NewOrderBook local order book of symbol, limit is the snapshot depth
+func (c *SpotClient) NewOrderBook(symbol string, limit int) *OrderBook {
+	return NewOrderBook(c.exchange, symbol, limit)
+}
+
+// Errors snapshot failures and sequence gaps, each followed by a resync
+func (b *OrderBook) Errors() <-chan error {
+	return b.errs
+}
+
+func (b *OrderBook) reportError(err error) {
+	select {
+	case b.errs <- err:
+	default:
+	}
+}
+
+// Run keep the book in sync with events (MarketStream.Depth), blocks until ctx is done or events is closed
+func (b *OrderBook) Run(ctx context.Context, events <-chan *binance.WsDepthEvent) error {
+	for {
+		pending, err := b.sync(ctx, events)
+		if err != nil {
+			return err
+		}
+		for err == nil {
+			for len(pending) > 0 && err == nil {
+				err = b.apply(pending[0])
+				pending = pending[1:]
+			}
+			if err != nil {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case event, ok := <-events:
+				if !ok {
+					return ErrStreamClosed
+				}
+				pending = append(pending, event)
+			}
+		}
+		b.reportError(err)
+		b.unsync()
+	}
+}
+
+type depthSnapshot struct {
+	depth *binance.DepthResponse
+	err   error
+}
+
+// sync load a snapshot while buffering events, returns the buffered events newer than the snapshot
+func (b *OrderBook) sync(ctx context.Context, events <-chan *binance.WsDepthEvent) ([]*binance.WsDepthEvent, error) {
+	bo := &backoff{min: b.retryMin, max: b.retryMax}
+	var buffer []*binance.WsDepthEvent
+	for {
+		snapshotC := make(chan depthSnapshot, 1)
+		go func() {
+			depth, err := b.exchange.Depth(ctx, b.Symbol, b.Limit)
+			snapshotC <- depthSnapshot{depth: depth, err: err}
+		}()
+
+		var snapshot depthSnapshot
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case event, ok := <-events:
+				if !ok {
+					return nil, ErrStreamClosed
+				}
+				buffer = append(buffer, event)
+				if len(buffer) > maxDepthBuffer {
+					buffer = buffer[len(buffer)-maxDepthBuffer:]
+				}
+			case snapshot = <-snapshotC:
+				break wait
+			}
+		}
+
+		err := snapshot.err
+		if err != nil {
+			err = fmt.Errorf("%s depth snapshot %w", b.Symbol, err)
+		} else if len(buffer) > 0 && snapshot.depth.LastUpdateID < buffer[0].FirstUpdateID-1 {
+			// the snapshot must cover the first buffered event, otherwise load a newer one
+			err = errors.New(fmt.Sprintf("%s depth snapshot %d is older than update %d", b.Symbol, snapshot.depth.LastUpdateID, buffer[0].FirstUpdateID))
+		} else {
+			err = b.load(snapshot.depth)
+		}
+		if err != nil {
+			b.reportError(err)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(bo.Next()):
+			}
+			continue
+		}
+
+		var pending []*binance.WsDepthEvent
+		for _, event := range buffer {
+			if event.LastUpdateID > snapshot.depth.LastUpdateID {
+				pending = append(pending, event)
+			}
+		}
+		return pending, nil
+	}
+}
+
+func parseLevels(raw []binance.Bid, desc bool) ([]PriceLevel, error) {
+	levels := make([]PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err := ParseDecimal(level.Price)
+		if err != nil {
+			return nil, fmt.Errorf("price %w", err)
+		}
+		quantity, err := ParseDecimal(level.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("quantity %w", err)
+		}
+		if !quantity.IsZero() {
+			levels = append(levels, PriceLevel{Price: price, Quantity: quantity})
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if desc {
+			return levels[i].Price.GreaterThan(levels[j].Price)
+		}
+		return levels[i].Price.LessThan(levels[j].Price)
+	})
+	return levels, nil
+}
+
+func (b *OrderBook) load(depth *binance.DepthResponse) error {
+	bids, err := parseLevels(depth.Bids, true)
+	if err != nil {
+		return fmt.Errorf("%s snapshot bids %w", b.Symbol, err)
+	}
+	asks, err := parseLevels(depth.Asks, false)
+	if err != nil {
+		return fmt.Errorf("%s snapshot asks %w", b.Symbol, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bids, b.asks = bids, asks
+	b.lastUpdateId = depth.LastUpdateID
+	b.applied = false
+	b.updatedAt = time.Now()
+	if !b.synced {
+		b.synced = true
+		close(b.syncedC)
+	}
+	return nil
+}
+
+func (b *OrderBook) unsync() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.synced {
+		b.synced = false
+		b.syncedC = make(chan struct{})
+	}
+	b.resyncs++
+}
+
+// apply one diff event, an error means the book missed updates and must resync
+func (b *OrderBook) apply(event *binance.WsDepthEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if event.LastUpdateID <= b.lastUpdateId {
+		return nil
+	}
+	next := b.lastUpdateId + 1
+	if b.applied {
+		if event.FirstUpdateID != next {
+			return errors.New(fmt.Sprintf("%s depth gap: expected update %d, got %d-%d", b.Symbol, next, event.FirstUpdateID, event.LastUpdateID))
+		}
+	} else if event.FirstUpdateID > next {
+		return errors.New(fmt.Sprintf("%s depth gap: snapshot %d is older than update %d-%d", b.Symbol, b.lastUpdateId, event.FirstUpdateID, event.LastUpdateID))
+	}
+
+	bids, asks := b.bids, b.asks
+	var err error
+	for _, level := range event.Bids {
+		if bids, err = updateLevel(bids, level, true); err != nil {
+			return fmt.Errorf("%s bid %w", b.Symbol, err)
+		}
+	}
+	for _, level := range event.Asks {
+		if asks, err = updateLevel(asks, level, false); err != nil {
+			return fmt.Errorf("%s ask %w", b.Symbol, err)
+		}
+	}
+	b.bids, b.asks = bids, asks
+	b.lastUpdateId = event.LastUpdateID
+	b.applied = true
+	b.updatedAt = time.Now()
+	return nil
+}
+
+// updateLevel set or (quantity 0) remove a price level keeping the side sorted best first
+func updateLevel(levels []PriceLevel, raw binance.Bid, desc bool) ([]PriceLevel, error) {
+	price, err := ParseDecimal(raw.Price)
+	if err != nil {
+		return nil, fmt.Errorf("price %w", err)
+	}
+	quantity, err := ParseDecimal(raw.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("quantity %w", err)
+	}
+	i := sort.Search(len(levels), func(i int) bool {
+		if desc {
+			return levels[i].Price.Cmp(price) <= 0
+		}
+		return levels[i].Price.Cmp(price) >= 0
+	})
+	found := i < len(levels) && levels[i].Price.Equal(price)
+	switch {
+	case quantity.IsZero() && found:
+		levels = append(levels[:i], levels[i+1:]...)
+	case quantity.IsZero():
+	case found:
+		levels[i].Quantity = quantity
+	default:
+		levels = append(levels, PriceLevel{})
+		copy(levels[i+1:], levels[i:])
+		levels[i] = PriceLevel{Price: price, Quantity: quantity}
+	}
+	return levels, nil
+}
+
+// Synced whether the book currently mirrors the exchange
+func (b *OrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// WaitSynced block until the book is synced or ctx is done
+func (b *OrderBook) WaitSynced(ctx context.Context) error {
+	b.mu.RLock()
+	syncedC := b.syncedC
+	b.mu.RUnlock()
+	select {
+	case <-syncedC:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LastUpdateId update id the book reflects
+func (b *OrderBook) LastUpdateId() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastUpdateId
+}
+
+// Resyncs number of gaps that forced a new snapshot
+func (b *OrderBook) Resyncs() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.resyncs
+}
+
+func (b *OrderBook) UpdatedAt() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.updatedAt
+}
+
+func topLevels(levels []PriceLevel, n int) []PriceLevel {
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+	return append([]PriceLevel(nil), levels[:n]...)
+}
+
+// Bids best n bids, every level when n <= 0
+func (b *OrderBook) Bids(n int) []PriceLevel {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return topLevels(b.bids, n)
+}
+
+// Asks best n asks, every level when n <= 0
+func (b *OrderBook) Asks(n int) []PriceLevel {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return topLevels(b.asks, n)
+}
+
+func (b *OrderBook) BestBid() (PriceLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 {
+		return PriceLevel{}, false
+	}
+	return b.bids[0], true
+}
+
+func (b *OrderBook) BestAsk() (PriceLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.asks) == 0 {
+		return PriceLevel{}, false
+	}
+	return b.asks[0], true
+}
+
+// takerLevels the side a taker order of side consumes: asks for BUY, bids for SELL
+func (b *OrderBook) takerLevels(side binance.SideType) ([]PriceLevel, error) {
+	if !b.synced {
+		return nil, ErrOrderBookNotSynced
+	}
+	switch side {
+	case binance.SideTypeBuy:
+		return b.asks, nil
+	case binance.SideTypeSell:
+		return b.bids, nil
+	}
+	return nil, errors.New(fmt.Sprintf("invalid side %q", side))
+}
+
+// CumulativeDepth liquidity a taker order of side finds up to price (inclusive)
+func (b *OrderBook) CumulativeDepth(side binance.SideType, price Decimal) (base, quote Decimal, err error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	levels, err := b.takerLevels(side)
+	if err != nil {
+		return Zero, Zero, err
+	}
+	for _, level := range levels {
+		if (side == binance.SideTypeBuy && level.Price.GreaterThan(price)) || (side == binance.SideTypeSell && level.Price.LessThan(price)) {
+			break
+		}
+		base = base.Add(level.Quantity)
+		quote = quote.Add(level.Quantity.Mul(level.Price))
+	}
+	return base, quote, nil
+}
+
+// Fill walk the book for a taker order of side spending (QUOTE) or trading (BASE) amount,
+// places is the precision of base amounts derived from a quote amount
+func (b *OrderBook) Fill(side binance.SideType, amount Decimal, kind QuantityKind, places int32) (*BookFill, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	levels, err := b.takerLevels(side)
+	if err != nil {
+		return nil, err
+	}
+	return walkLevels(levels, amount, kind, places), nil
+}
+
+// VWAP average price of a taker order of side for quantity base asset
+func (b *OrderBook) VWAP(side binance.SideType, quantity Decimal) (Decimal, error) {
+	fill, err := b.Fill(side, quantity, QuantityKindBase, 16)
+	if err != nil {
+		return Zero, err
+	}
+	if !fill.Complete {
+		return Zero, ErrInsufficientDepth
+	}
+	return fill.AvgPrice, nil
+}
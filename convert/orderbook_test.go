@@ -0,0 +1,138 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func waitUpdateId(book *OrderBook, id int64) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if book.LastUpdateId() == id {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func TestOrderBook(t *testing.T) {
+	convey.Convey("TestOrderBook", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		fake.SetDepth("BTCUSDT", 100,
+			[]binance.Bid{{Price: "20000.00", Quantity: "1"}, {Price: "19999.00", Quantity: "2"}},
+			[]binance.Ask{{Price: "20002.00", Quantity: "3"}, {Price: "20001.00", Quantity: "1"}},
+		)
+		book := NewOrderBook(fake, "BTCUSDT", 100)
+		book.retryMin, book.retryMax = time.Millisecond, time.Millisecond
+		_, err := book.VWAP(binance.SideTypeBuy, MustDecimal("1"))
+		convCtx.So(err, convey.ShouldEqual, ErrOrderBookNotSynced)
+
+		events := make(chan *binance.WsDepthEvent, 16)
+		events <- &binance.WsDepthEvent{FirstUpdateID: 95, LastUpdateID: 99, Bids: []binance.Bid{{Price: "1", Quantity: "1"}}}
+		events <- &binance.WsDepthEvent{FirstUpdateID: 100, LastUpdateID: 102,
+			Bids: []binance.Bid{{Price: "20000.00", Quantity: "0"}},
+			Asks: []binance.Ask{{Price: "20001.50", Quantity: "1"}, {Price: "20003.00", Quantity: "0"}},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- book.Run(ctx, events) }()
+
+		convCtx.So(book.WaitSynced(ctx), convey.ShouldBeNil)
+		convCtx.So(waitUpdateId(book, 102), convey.ShouldBeTrue)
+		bid, ok := book.BestBid()
+		convCtx.So(ok, convey.ShouldBeTrue)
+		convCtx.So(bid.Price.String(), convey.ShouldEqual, "19999")
+		var asks []string
+		for _, level := range book.Asks(0) {
+			asks = append(asks, level.Price.String())
+		}
+		convCtx.So(asks, convey.ShouldResemble, []string{"20001", "20001.5", "20002"})
+		convCtx.So(len(book.Asks(2)), convey.ShouldEqual, 2)
+
+		base, quote, err := book.CumulativeDepth(binance.SideTypeBuy, MustDecimal("20001.5"))
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(base.String(), convey.ShouldEqual, "2")
+		convCtx.So(quote.String(), convey.ShouldEqual, "40002.5")
+		base, _, _ = book.CumulativeDepth(binance.SideTypeSell, MustDecimal("19999"))
+		convCtx.So(base.String(), convey.ShouldEqual, "2")
+
+		vwap, err := book.VWAP(binance.SideTypeBuy, MustDecimal("3"))
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(vwap.String(), convey.ShouldEqual, "20001.5")
+		_, err = book.VWAP(binance.SideTypeSell, MustDecimal("3"))
+		convCtx.So(err, convey.ShouldEqual, ErrInsufficientDepth)
+
+		fill, err := book.Fill(binance.SideTypeBuy, MustDecimal("30002"), QuantityKindQuote, 8)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(fill.Complete, convey.ShouldBeTrue)
+		convCtx.So(fill.Levels, convey.ShouldEqual, 2)
+		convCtx.So(fill.Base.String(), convey.ShouldEqual, "1.50001249")
+		convCtx.So(fill.WorstPrice.String(), convey.ShouldEqual, "20001.5")
+
+		// 103-104 never arrive: resync from a newer snapshot
+		fake.SetDepth("BTCUSDT", 110, []binance.Bid{{Price: "20010", Quantity: "1"}}, []binance.Ask{{Price: "20011", Quantity: "1"}})
+		events <- &binance.WsDepthEvent{FirstUpdateID: 105, LastUpdateID: 106}
+		events <- &binance.WsDepthEvent{FirstUpdateID: 108, LastUpdateID: 111, Asks: []binance.Ask{{Price: "20012", Quantity: "4"}}}
+		convCtx.So(waitUpdateId(book, 111), convey.ShouldBeTrue)
+		convCtx.So(book.Resyncs(), convey.ShouldEqual, 1)
+		convCtx.So(len(book.Asks(0)), convey.ShouldEqual, 2)
+		convCtx.So((<-book.Errors()).Error(), convey.ShouldContainSubstring, "depth gap")
+
+		cancel()
+		convCtx.So(errors.Is(<-done, context.Canceled), convey.ShouldBeTrue)
+	})
+}
+
+func TestOrderBookStream(t *testing.T) {
+	convey.Convey("TestOrderBookStream", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		fake.SetDepth("BTCUSDT", 7, []binance.Bid{{Price: "20000", Quantity: "1"}}, []binance.Ask{{Price: "20001", Quantity: "1"}})
+		ws, conns := newTestWsServer()
+		defer ws.Close()
+
+		stream := NewMarketStream(&StreamConfig{BaseURL: testWsURL(ws)})
+		defer stream.Close()
+		events, err := stream.Depth("BTCUSDT", "100ms")
+		convCtx.So(err, convey.ShouldBeNil)
+		conn := waitConn(conns)
+		convCtx.So(conn.URL, convey.ShouldEqual, "/stream?streams=btcusdt@depth@100ms")
+
+		book := NewSpotClient(server.Client()).NewOrderBook("BTCUSDT", 5)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go book.Run(ctx, events)
+		convCtx.So(book.WaitSynced(ctx), convey.ShouldBeNil)
+
+		for _, msg := range []string{
+			`{"stream":"btcusdt@depth@100ms","data":{"e":"depthUpdate","E":1,"s":"BTCUSDT","U":6,"u":8,"b":[["20000.5","2"]],"a":[]}}`,
+			`{"stream":"btcusdt@depth@100ms","data":{"e":"depthUpdate","E":2,"s":"BTCUSDT","U":12,"u":12,"b":[],"a":[]}}`,
+		} {
+			convCtx.So(conn.WriteMessage(websocket.TextMessage, []byte(msg)), convey.ShouldBeNil)
+		}
+		gap := <-stream.Gaps()
+		convCtx.So(*gap, convey.ShouldResemble, StreamGap{Stream: "btcusdt@depth@100ms", Reason: GapSequence, FromSeq: 9, ToSeq: 11})
+		// the gap forced a resync onto the snapshot
+		for i := 0; i < 5000 && book.Resyncs() == 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		convCtx.So(book.Resyncs(), convey.ShouldEqual, 1)
+		convCtx.So(book.WaitSynced(ctx), convey.ShouldBeNil)
+		bid, _ := book.BestBid()
+		convCtx.So(bid.Price.String(), convey.ShouldEqual, "20000")
+
+		var limit string
+		for _, req := range server.Requests() {
+			if req.Path == "/api/v3/depth" {
+				limit = req.Params.Get("limit")
+			}
+		}
+		convCtx.So(limit, convey.ShouldEqual, "5")
+	})
+}
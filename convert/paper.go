@@ -0,0 +1,452 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+/*
+模拟盘:
+
+行情类请求 (exchangeInfo, depth, klines, 价格, TradeFee) 发给真实交易所, 下单, 撤单, 查单, 余额, OCO, 提币, 闪兑都只在本地的 FakeExchange 上进行,
+不会有任何请求改动真实账户. 每次下单前拉一次真实的 depth, 市价单和立即成交的限价单逐档吃掉真实盘口 (taker 费率);
+查询挂单, 撤单和查余额前也会同步一次有挂单的交易对, 挂单在盘口越过挂单价时按挂单价成交 (maker 费率).
+两次同步之间价格穿过挂单价又回来的成交会被漏掉, 需要及时成交的机器人可以定期调用 Sync.
+手续费率取真实账户的 TradeFee, 从收到的资产里扣. 虚拟账户 (余额, 订单, 成交) 在每次变动后写入 StatePath, 重启后接着用.
+*/
+
+// PaperConfig of a paper trading account
+type PaperConfig struct {
+	Balances   map[string]string // starting balances of a new account, other assets start at 0
+	StatePath  string            // JSON file the account is loaded from and saved to after every change, empty keeps it in memory
+	DepthLimit int               // live order book levels orders fill against, default 100
+}
+
+// PaperExchange Exchange that reads market data from a live exchange and keeps the account simulated,
+// see NewPaperSpotClient
+type PaperExchange struct {
+	live       Exchange
+	sim        *FakeExchange
+	statePath  string
+	depthLimit int
+
+	mu sync.Mutex // one account call at a time, so saves see a consistent account
+}
+
+// paperState the simulated account as saved to StatePath
+type paperState struct {
+	Balances    map[string]Decimal                 `json:"balances"`
+	Orders      []*binance.Order                   `json:"orders"`
+	Trades      []*binance.TradeV3                 `json:"trades"`
+	OrderLists  []*binance.Oco                     `json:"orderLists"`
+	Triggered   map[int64]bool                     `json:"triggered"`
+	Withdraws   []*binance.Withdraw                `json:"withdraws"`
+	Conversions []*binance.ConvertTradeHistoryItem `json:"conversions"`
+	NextOrderId int64                              `json:"nextOrderId"`
+	NextListId  int64                              `json:"nextListId"`
+}
+
+// NewPaperExchange paper account on top of live, loaded from cfg.StatePath when that file exists.
+// A nil cfg is an empty in-memory account
+func NewPaperExchange(live Exchange, cfg *PaperConfig) (*PaperExchange, error) {
+	if cfg == nil {
+		cfg = &PaperConfig{}
+	}
+	p := &PaperExchange{live: live, sim: NewFakeExchange(), statePath: cfg.StatePath, depthLimit: cfg.DepthLimit}
+	if p.depthLimit <= 0 {
+		p.depthLimit = 100
+	}
+	p.sim.SetChargeFees(true)
+	if p.statePath != "" {
+		loaded, err := p.load()
+		if err != nil {
+			return nil, err
+		}
+		if loaded {
+			return p, nil
+		}
+	}
+	for asset, free := range cfg.Balances {
+		d, err := ParseDecimal(free)
+		if err != nil {
+			return nil, err
+		}
+		p.sim.balances[asset] = d
+	}
+	return p, p.save()
+}
+
+// NewPaperSpotClient SpotClient trading a paper account against live binance market data,
+// the live requests go through a RateLimiter like NewSpotClient's
+func NewPaperSpotClient(spotClient *binance.Client, cfg *PaperConfig) (*SpotClient, error) {
+	live := NewSpotClient(spotClient)
+	p, err := NewPaperExchange(live.exchange, cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := NewSpotClientWithExchange(p)
+	c.limiter = live.limiter
+	return c, nil
+}
+
+// load the account from statePath, false when the file does not exist yet
+func (p *PaperExchange) load() (bool, error) {
+	data, err := os.ReadFile(p.statePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var state paperState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return false, err
+	}
+	e := p.sim
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for asset, balance := range state.Balances {
+		e.balances[asset] = balance
+	}
+	e.orders, e.trades, e.orderLists = state.Orders, state.Trades, state.OrderLists
+	e.withdraws, e.conversions = state.Withdraws, state.Conversions
+	for orderId := range state.Triggered {
+		e.triggered[orderId] = true
+	}
+	if state.NextOrderId > 0 {
+		e.nextOrderId = state.NextOrderId
+	}
+	if state.NextListId > 0 {
+		e.nextListId = state.NextListId
+	}
+	return true, nil
+}
+
+// save write the account to statePath, if any
+func (p *PaperExchange) save() error {
+	if p.statePath == "" {
+		return nil
+	}
+	e := p.sim
+	e.mu.Lock()
+	data, err := json.MarshalIndent(&paperState{
+		Balances:    e.balances,
+		Orders:      e.orders,
+		Trades:      e.trades,
+		OrderLists:  e.orderLists,
+		Triggered:   e.triggered,
+		Withdraws:   e.withdraws,
+		Conversions: e.conversions,
+		NextOrderId: e.nextOrderId,
+		NextListId:  e.nextListId,
+	}, "", "  ")
+	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p.statePath, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// loadSymbol add symbol and the account's fee rates to the simulated exchange, once
+func (p *PaperExchange) loadSymbol(ctx context.Context, symbol string) error {
+	p.sim.mu.Lock()
+	_, known := p.sim.symbols[symbol]
+	p.sim.mu.Unlock()
+	if known {
+		return nil
+	}
+	info, err := p.live.ExchangeInfo(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	var s *binance.Symbol
+	for i := range info.Symbols {
+		if info.Symbols[i].Symbol == symbol {
+			s = &info.Symbols[i]
+		}
+	}
+	if s == nil {
+		return &common.APIError{Code: -1121, Message: "Invalid symbol."}
+	}
+	fees, err := p.live.TradeFee(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	p.sim.AddSymbol(*s)
+	if len(fees) > 0 {
+		p.sim.SetTradeFee(symbol, fees[0].MakerCommission, fees[0].TakerCommission)
+	}
+	p.trackAssets(s.BaseAsset, s.QuoteAsset)
+	return nil
+}
+
+// trackAssets start assets at 0, the simulated exchange does not check untracked assets
+func (p *PaperExchange) trackAssets(assets ...string) {
+	p.sim.mu.Lock()
+	defer p.sim.mu.Unlock()
+	for _, asset := range assets {
+		if _, ok := p.sim.balances[asset]; !ok {
+			p.sim.balances[asset] = Zero
+		}
+	}
+}
+
+// syncSymbol copy the live book of symbol into the simulated exchange, which fills the resting orders it reaches
+func (p *PaperExchange) syncSymbol(ctx context.Context, symbol string) error {
+	if err := p.loadSymbol(ctx, symbol); err != nil {
+		return err
+	}
+	depth, err := p.live.Depth(ctx, symbol, p.depthLimit)
+	if err != nil {
+		return err
+	}
+	avg, err := p.live.AveragePrice(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	p.sim.SetDepth(symbol, depth.LastUpdateID, depth.Bids, depth.Asks)
+	p.sim.SetAveragePrice(symbol, avg.Price)
+	if len(depth.Bids) > 0 && len(depth.Asks) > 0 {
+		p.sim.SetBookTicker(symbol, depth.Bids[0].Price, depth.Bids[0].Quantity, depth.Asks[0].Price, depth.Asks[0].Quantity)
+	}
+	return nil
+}
+
+// syncOpen sync every symbol (or only symbol when set) with open orders
+func (p *PaperExchange) syncOpen(ctx context.Context, symbol string) error {
+	if symbol != "" {
+		if err := p.loadSymbol(ctx, symbol); err != nil {
+			return err
+		}
+	}
+	symbols := map[string]bool{}
+	p.sim.mu.Lock()
+	for _, order := range p.sim.orders {
+		if (symbol == "" || order.Symbol == symbol) && (order.Status == binance.OrderStatusTypeNew || order.Status == binance.OrderStatusTypePartiallyFilled) {
+			symbols[order.Symbol] = true
+		}
+	}
+	p.sim.mu.Unlock()
+	var names []string
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := p.syncSymbol(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync fill the resting orders the live book has reached and save the account
+func (p *PaperExchange) Sync(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.syncOpen(ctx, ""); err != nil {
+		return err
+	}
+	return p.save()
+}
+
+// account sync then run fn against the simulated account and save it
+func (p *PaperExchange) account(sync func() error, fn func() error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sync != nil {
+		if err := sync(); err != nil {
+			return err
+		}
+	}
+	err := fn()
+	if saveErr := p.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+func (p *PaperExchange) ListBookTickers(ctx context.Context, symbol string) ([]*binance.BookTicker, error) {
+	return p.live.ListBookTickers(ctx, symbol)
+}
+
+func (p *PaperExchange) ExchangeInfo(ctx context.Context, symbols ...string) (*binance.ExchangeInfo, error) {
+	return p.live.ExchangeInfo(ctx, symbols...)
+}
+
+func (p *PaperExchange) AveragePrice(ctx context.Context, symbol string) (*binance.AvgPrice, error) {
+	return p.live.AveragePrice(ctx, symbol)
+}
+
+func (p *PaperExchange) TradeFee(ctx context.Context, symbol string) ([]*binance.TradeFeeDetails, error) {
+	return p.live.TradeFee(ctx, symbol)
+}
+
+func (p *PaperExchange) Klines(ctx context.Context, params *KlinesParams) ([]*binance.Kline, error) {
+	return p.live.Klines(ctx, params)
+}
+
+func (p *PaperExchange) ListPrices(ctx context.Context, symbols ...string) ([]*binance.SymbolPrice, error) {
+	return p.live.ListPrices(ctx, symbols...)
+}
+
+func (p *PaperExchange) Depth(ctx context.Context, symbol string, limit int) (*binance.DepthResponse, error) {
+	return p.live.Depth(ctx, symbol, limit)
+}
+
+func (p *PaperExchange) CreateOrder(ctx context.Context, params *CreateOrderParams) (resp *binance.CreateOrderResponse, err error) {
+	err = p.account(func() error { return p.syncSymbol(ctx, params.Symbol) }, func() error {
+		resp, err = p.sim.CreateOrder(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) GetOrder(ctx context.Context, params *QueryOrderParams) (resp *binance.Order, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, params.Symbol) }, func() error {
+		resp, err = p.sim.GetOrder(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) CancelOrder(ctx context.Context, params *QueryOrderParams) (resp *binance.CancelOrderResponse, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, params.Symbol) }, func() error {
+		resp, err = p.sim.CancelOrder(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) ListOrders(ctx context.Context, params *ListOrdersParams) (resp []*binance.Order, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, params.Symbol) }, func() error {
+		resp, err = p.sim.ListOrders(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) ListOpenOrders(ctx context.Context, symbol string) (resp []*binance.Order, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, symbol) }, func() error {
+		resp, err = p.sim.ListOpenOrders(ctx, symbol)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) ListTrades(ctx context.Context, params *ListTradesParams) (resp []*binance.TradeV3, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, params.Symbol) }, func() error {
+		resp, err = p.sim.ListTrades(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) UserAsset(ctx context.Context, asset string) (resp []*binance.UserAssetV3, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, "") }, func() error {
+		resp, err = p.sim.UserAsset(ctx, asset)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) CreateWithdraw(ctx context.Context, params *WithdrawParams) (resp *binance.CreateWithdrawResponse, err error) {
+	err = p.account(func() error {
+		p.trackAssets(params.Coin)
+		return nil
+	}, func() error {
+		resp, err = p.sim.CreateWithdraw(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) ListWithdraws(ctx context.Context, coin string, withdrawOrderId string) ([]*binance.Withdraw, error) {
+	return p.sim.ListWithdraws(ctx, coin, withdrawOrderId)
+}
+
+func (p *PaperExchange) CreateOCO(ctx context.Context, params *CreateOCOParams) (resp *binance.CreateOCOResponse, err error) {
+	err = p.account(func() error { return p.syncSymbol(ctx, params.Symbol) }, func() error {
+		resp, err = p.sim.CreateOCO(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) GetOrderList(ctx context.Context, params *QueryOrderListParams) (resp *binance.Oco, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, "") }, func() error {
+		resp, err = p.sim.GetOrderList(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) ListOpenOrderLists(ctx context.Context) (resp []*binance.Oco, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, "") }, func() error {
+		resp, err = p.sim.ListOpenOrderLists(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) CancelOrderList(ctx context.Context, params *QueryOrderListParams) (resp *binance.CancelOCOResponse, err error) {
+	err = p.account(func() error { return p.syncOpen(ctx, params.Symbol) }, func() error {
+		resp, err = p.sim.CancelOrderList(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+// StartUserStream listen keys of the paper account, no events are pushed for it
+func (p *PaperExchange) StartUserStream(ctx context.Context) (string, error) {
+	return p.sim.StartUserStream(ctx)
+}
+
+func (p *PaperExchange) KeepaliveUserStream(ctx context.Context, listenKey string) error {
+	return p.sim.KeepaliveUserStream(ctx, listenKey)
+}
+
+func (p *PaperExchange) CloseUserStream(ctx context.Context, listenKey string) error {
+	return p.sim.CloseUserStream(ctx, listenKey)
+}
+
+// GetConvertQuote priced from the live book of the symbol trading both assets
+func (p *PaperExchange) GetConvertQuote(ctx context.Context, params *ConvertQuoteParams) (resp *ConvertQuoteResponse, err error) {
+	sync := func() error {
+		err := p.syncSymbol(ctx, params.FromAsset+params.ToAsset)
+		if err != nil {
+			err = p.syncSymbol(ctx, params.ToAsset+params.FromAsset)
+		}
+		return err
+	}
+	err = p.account(sync, func() error {
+		resp, err = p.sim.GetConvertQuote(ctx, params)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) AcceptConvertQuote(ctx context.Context, quoteId string) (resp *AcceptQuoteResponse, err error) {
+	err = p.account(nil, func() error {
+		resp, err = p.sim.AcceptConvertQuote(ctx, quoteId)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PaperExchange) GetConvertOrder(ctx context.Context, params *QueryConvertOrderParams) (*binance.ConvertTradeHistoryItem, error) {
+	return p.sim.GetConvertOrder(ctx, params)
+}
+
+func (p *PaperExchange) ListConvertTrades(ctx context.Context, params *ListConvertTradesParams) (*binance.ConvertTradeHistory, error) {
+	return p.sim.ListConvertTrades(ctx, params)
+}
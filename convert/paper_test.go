@@ -0,0 +1,113 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/smartystreets/goconvey/convey"
+	"path/filepath"
+	"testing"
+)
+
+func TestPaperExchange(t *testing.T) {
+	convey.Convey("TestPaperExchange", t, func(convCtx convey.C) {
+		live := newTestFakeExchange()
+		live.SetBalance("BUSD", "5")
+		live.SetTradeFee("LUNCBUSD", "0.001", "0.002")
+		live.SetAveragePrice("LUNCBUSD", "0.0002")
+		live.SetDepth("LUNCBUSD", 1, []binance.Bid{{Price: "0.00019", Quantity: "500000"}},
+			[]binance.Ask{{Price: "0.00020", Quantity: "100000"}, {Price: "0.00021", Quantity: "100000"}})
+		statePath := filepath.Join(t.TempDir(), "paper.json")
+		paper, err := NewPaperExchange(live, &PaperConfig{Balances: map[string]string{"BUSD": "100"}, StatePath: statePath})
+		convCtx.So(err, convey.ShouldBeNil)
+		cli := NewSpotClientWithExchange(paper)
+		ctx := context.Background()
+
+		// walks the live book, the taker fee is paid in LUNC
+		resp, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "30"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(len(resp.Fills), convey.ShouldEqual, 2)
+		convCtx.So(resp.Fills[0].Price, convey.ShouldEqual, "0.00020000")
+		convCtx.So(resp.Fills[0].Quantity, convey.ShouldEqual, "100000.00000000")
+		convCtx.So(resp.Fills[0].Commission, convey.ShouldEqual, "200.00000000")
+		convCtx.So(resp.Fills[0].CommissionAsset, convey.ShouldEqual, "LUNC")
+		convCtx.So(resp.Fills[1].Price, convey.ShouldEqual, "0.00021000")
+		convCtx.So(resp.Fills[1].Quantity, convey.ShouldEqual, "47619.04761904")
+		assets, err := cli.GetUserAsset(ctx, &UserAssetReq{})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(assets.Data), convey.ShouldEqual, 2)
+		convCtx.So(assets.Data[0].Asset, convey.ShouldEqual, "BUSD")
+		convCtx.So(assets.Data[0].Free, convey.ShouldEqual, "70.00000000")
+		convCtx.So(assets.Data[1].Free, convey.ShouldEqual, "147323.80952380")
+
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "400000"})
+		convCtx.So(errors.Is(err, ErrInsufficientBalance), convey.ShouldBeTrue)
+
+		// rests until the live bid reaches it, then fills at its price as maker
+		sell, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Type: "LIMIT", Quantity: "100000", Price: "0.00025"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(sell.Status, convey.ShouldEqual, "NEW")
+		open, err := cli.HangOrderList(ctx)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(open.Data), convey.ShouldEqual, 1)
+		live.SetDepth("LUNCBUSD", 2, []binance.Bid{{Price: "0.00026", Quantity: "500000"}},
+			[]binance.Ask{{Price: "0.00027", Quantity: "100000"}, {Price: "0.00028", Quantity: "100000"}})
+		order, err := cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: sell.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(order.CummulativeQuoteQuantity, convey.ShouldEqual, "25.00000000")
+		assets, err = cli.GetUserAsset(ctx, &UserAssetReq{Asset: "BUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(assets.Data[0].Free, convey.ShouldEqual, "94.97500000")
+
+		// IOC takes what the book has up to its price
+		ioc, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", TimeInForce: "IOC", Quantity: "150000", Price: "0.00027"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(ioc.Status, convey.ShouldEqual, "EXPIRED")
+		convCtx.So(ioc.ExecutedQuantity, convey.ShouldEqual, "100000.00000000")
+
+		resting, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "60000", Price: "0.00018"})
+		convCtx.So(err, convey.ShouldBeNil)
+		canceled, err := cli.CancelOrder(ctx, &CancelReq{Symbol: "LUNCBUSD", OrderId: resting.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(canceled.Status, convey.ShouldEqual, binance.OrderStatusTypeCanceled)
+		open, err = cli.HangOrderList(ctx)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(open.Data, convey.ShouldBeEmpty)
+		orders, err := cli.OrderList(ctx, &OrderListReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(orders.Data), convey.ShouldEqual, 4)
+
+		// the real account is never touched
+		convCtx.So(live.Balance("BUSD").String(), convey.ShouldEqual, "5")
+		liveOrders, err := live.ListOrders(ctx, &ListOrdersParams{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(liveOrders, convey.ShouldBeEmpty)
+
+		// the account survives a restart, starting balances only seed a new one
+		restarted, err := NewPaperExchange(live, &PaperConfig{Balances: map[string]string{"BUSD": "1"}, StatePath: statePath})
+		convCtx.So(err, convey.ShouldBeNil)
+		cli = NewSpotClientWithExchange(restarted)
+		assets, err = cli.GetUserAsset(ctx, &UserAssetReq{Asset: "BUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(assets.Data[0].Free, convey.ShouldEqual, "67.97500000")
+		order, err = cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: sell.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.Status, convey.ShouldEqual, "FILLED")
+		next, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "50000"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(next.OrderID, convey.ShouldEqual, resting.OrderID+1)
+		convCtx.So(next.CummulativeQuoteQuantity, convey.ShouldEqual, "13.00000000")
+
+		_, err = NewPaperExchange(live, &PaperConfig{Balances: map[string]string{"BUSD": "x"}})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		// a nil config is an empty account kept in memory
+		empty, err := NewPaperExchange(live, nil)
+		convCtx.So(err, convey.ShouldBeNil)
+		assets, err = NewSpotClientWithExchange(empty).GetUserAsset(ctx, &UserAssetReq{})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(assets.Data, convey.ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,461 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+请求权重限流:
+
+binance 按 IP 统计 REQUEST_WEIGHT (每分钟) 和 RAW_REQUESTS, 按账户统计 ORDERS (每 10 秒 / 每天), 窗口按自然时间对齐.
+RateLimiter 以 http.RoundTripper 的形式包住 binance.Client 的 HTTPClient, go-binance 的服务和 callSigned 都经过它.
+NewSpotClient 默认给每个客户端一个 DefaultRateLimits 的 RateLimiter, 同一 IP / 账户上的多个客户端用 NewSpotClientWithLimiter 共享一个:
+请求发出前按端点权重占用额度, 额度不足时阻塞到窗口滚动 (BLOCK) 或直接返回 *RateLimitError (FAIL_FAST).
+响应头 X-MBX-USED-WEIGHT-1M / X-MBX-ORDER-COUNT-10S / X-MBX-ORDER-COUNT-1D 是服务端的计数, 比本地多时以服务端为准
+(同一 IP 上的其他进程也在消耗额度).
+429 (超限) 和 418 (IP 被封) 按 Retry-After 暂停所有请求, 继续请求只会延长封禁.
+/sapi 接口有独立的限额, 这里只遵守全局暂停.
+*/
+
+var ErrRateLimited = errors.New("rate limited")
+
+type RateLimitMode string
+
+const (
+	RateLimitBlock    RateLimitMode = "BLOCK"     // wait until the request fits
+	RateLimitFailFast RateLimitMode = "FAIL_FAST" // refuse with a *RateLimitError
+)
+
+// DefaultRateLimits spot api limits, SpotClient.SyncRateLimits replaces them with the exchangeInfo ones
+var DefaultRateLimits = []binance.RateLimit{
+	{RateLimitType: "REQUEST_WEIGHT", Interval: "MINUTE", IntervalNum: 1, Limit: 6000},
+	{RateLimitType: "ORDERS", Interval: "SECOND", IntervalNum: 10, Limit: 100},
+	{RateLimitType: "ORDERS", Interval: "DAY", IntervalNum: 1, Limit: 200000},
+	{RateLimitType: "RAW_REQUESTS", Interval: "MINUTE", IntervalNum: 5, Limit: 61000},
+}
+
+const (
+	rateLimitBanned = "BANNED"
+	defaultBanWait  = time.Minute
+)
+
+// endpointWeights REQUEST_WEIGHT of spot endpoints, endpoints not listed weigh 1.
+// depth, ticker and openOrders depend on their params, see endpointWeight
+var endpointWeights = map[string]int64{
+	"GET /api/v3/exchangeInfo":      20,
+	"GET /api/v3/avgPrice":          2,
+	"GET /api/v3/klines":            2,
+	"GET /api/v3/uiKlines":          2,
+	"GET /api/v3/trades":            25,
+	"GET /api/v3/historicalTrades":  25,
+	"GET /api/v3/aggTrades":         2,
+	"GET /api/v3/order":             4,
+	"GET /api/v3/allOrders":         20,
+	"GET /api/v3/orderList":         4,
+	"GET /api/v3/allOrderList":      20,
+	"GET /api/v3/openOrderList":     6,
+	"GET /api/v3/account":           20,
+	"GET /api/v3/myTrades":          20,
+	"POST /api/v3/userDataStream":   2,
+	"PUT /api/v3/userDataStream":    2,
+	"DELETE /api/v3/userDataStream": 2,
+}
+
+// orderEndpoints ORDERS counted per request
+var orderEndpoints = map[string]int64{
+	"POST /api/v3/order":               1,
+	"POST /api/v3/order/cancelReplace": 1,
+	"POST /api/v3/order/oco":           2,
+}
+
+// endpointWeight REQUEST_WEIGHT of a spot api request
+func endpointWeight(method, path string, query url.Values) int64 {
+	key := method + " " + path
+	single := query.Get("symbol") != ""
+	switch key {
+	case "GET /api/v3/depth":
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		switch {
+		case limit > 1000:
+			return 250
+		case limit > 500:
+			return 50
+		case limit > 100:
+			return 25
+		}
+		return 5
+	case "GET /api/v3/ticker/price", "GET /api/v3/ticker/bookTicker":
+		if single {
+			return 2
+		}
+		return 4
+	case "GET /api/v3/ticker/24hr":
+		if single {
+			return 2
+		}
+		return 80
+	case "GET /api/v3/openOrders":
+		if single {
+			return 6
+		}
+		return 80
+	}
+	if weight, ok := endpointWeights[key]; ok {
+		return weight
+	}
+	return 1
+}
+
+type RateLimitConfig struct {
+	Limits  []binance.RateLimit // default DefaultRateLimits
+	Mode    RateLimitMode       // default BLOCK
+	Weights map[string]int64    // weight overrides by "METHOD /path", e.g. "GET /api/v3/klines"
+	BanWait time.Duration       // pause after a 429/418 without Retry-After, default 1m
+}
+
+// RateLimitError a request refused before it was sent
+type RateLimitError struct {
+	Type       string        `json:"type"` // REQUEST_WEIGHT ORDERS RAW_REQUESTS, BANNED while paused by a 429/418
+	Interval   time.Duration `json:"interval"`
+	Used       int64         `json:"used"`
+	Limit      int64         `json:"limit"`
+	RetryAfter time.Duration `json:"retryAfter"`
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Type == rateLimitBanned {
+		return fmt.Sprintf("rate limited: requests paused for %s after 429/418", e.RetryAfter)
+	}
+	return fmt.Sprintf("rate limited: %s %d/%d per %s, retry after %s", e.Type, e.Used, e.Limit, e.Interval, e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimitUsage one window as the limiter counts it
+type RateLimitUsage struct {
+	Type     string        `json:"type"`
+	Interval time.Duration `json:"interval"`
+	Used     int64         `json:"used"`
+	Limit    int64         `json:"limit"`
+	ResetAt  time.Time     `json:"resetAt"`
+}
+
+type requestCost struct {
+	weight int64
+	orders int64
+	raw    int64
+}
+
+// rateWindow fixed window aligned to its size like binance's
+type rateWindow struct {
+	limitType string
+	size      time.Duration
+	limit     int64
+	header    string // response header with the server's count, empty when there is none
+	start     time.Time
+	used      int64
+}
+
+// newRateWindow nil for rateLimitTypes the limiter does not know
+func newRateWindow(l binance.RateLimit) (*rateWindow, error) {
+	if l.RateLimitType != "REQUEST_WEIGHT" && l.RateLimitType != "ORDERS" && l.RateLimitType != "RAW_REQUESTS" {
+		return nil, nil
+	}
+	var unit time.Duration
+	var suffix string
+	switch l.Interval {
+	case "SECOND":
+		unit, suffix = time.Second, "S"
+	case "MINUTE":
+		unit, suffix = time.Minute, "M"
+	case "HOUR":
+		unit, suffix = time.Hour, "H"
+	case "DAY":
+		unit, suffix = 24*time.Hour, "D"
+	default:
+		return nil, errors.New(fmt.Sprintf("%s invalid interval %q", l.RateLimitType, l.Interval))
+	}
+	if l.IntervalNum <= 0 || l.Limit <= 0 {
+		return nil, errors.New(fmt.Sprintf("%s invalid limit %d per %d %s", l.RateLimitType, l.Limit, l.IntervalNum, l.Interval))
+	}
+	w := &rateWindow{limitType: l.RateLimitType, size: time.Duration(l.IntervalNum) * unit, limit: l.Limit}
+	switch l.RateLimitType {
+	case "REQUEST_WEIGHT":
+		w.header = fmt.Sprintf("X-MBX-USED-WEIGHT-%d%s", l.IntervalNum, suffix)
+	case "ORDERS":
+		w.header = fmt.Sprintf("X-MBX-ORDER-COUNT-%d%s", l.IntervalNum, suffix)
+	}
+	return w, nil
+}
+
+// roll start a new window once now left the current one, also when the clock stepped back
+func (w *rateWindow) roll(now time.Time) {
+	if !now.Before(w.start) && now.Before(w.start.Add(w.size)) {
+		return
+	}
+	w.start, w.used = now.Truncate(w.size), 0
+}
+
+func (w *rateWindow) cost(c requestCost) int64 {
+	switch w.limitType {
+	case "REQUEST_WEIGHT":
+		return c.weight
+	case "ORDERS":
+		return c.orders
+	}
+	return c.raw
+}
+
+// RateLimiter share one between every client calling from the same IP / account
+type RateLimiter struct {
+	mode    RateLimitMode
+	weights map[string]int64
+	banWait time.Duration
+	now     func() time.Time
+
+	mu          sync.Mutex
+	windows     []*rateWindow
+	bannedUntil time.Time
+}
+
+func NewRateLimiter(cfg *RateLimitConfig) (*RateLimiter, error) {
+	if cfg == nil {
+		cfg = &RateLimitConfig{}
+	}
+	l := &RateLimiter{mode: cfg.Mode, weights: cfg.Weights, banWait: cfg.BanWait, now: time.Now}
+	if l.mode == "" {
+		l.mode = RateLimitBlock
+	}
+	if l.banWait <= 0 {
+		l.banWait = defaultBanWait
+	}
+	limits := cfg.Limits
+	if len(limits) == 0 {
+		limits = DefaultRateLimits
+	}
+	if err := l.SetRateLimits(limits); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetRateLimits replace the limits, counts of windows with the same type and interval are kept
+func (l *RateLimiter) SetRateLimits(limits []binance.RateLimit) error {
+	var windows []*rateWindow
+	for _, limit := range limits {
+		w, err := newRateWindow(limit)
+		if err != nil {
+			return err
+		}
+		if w == nil {
+			continue
+		}
+		windows = append(windows, w)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, w := range windows {
+		for _, old := range l.windows {
+			if old.limitType == w.limitType && old.size == w.size {
+				w.start, w.used = old.start, old.used
+			}
+		}
+	}
+	l.windows = windows
+	return nil
+}
+
+// Usage current count of every window
+func (l *RateLimiter) Usage() []RateLimitUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var resp []RateLimitUsage
+	for _, w := range l.windows {
+		w.roll(now)
+		resp = append(resp, RateLimitUsage{Type: w.limitType, Interval: w.size, Used: w.used, Limit: w.limit, ResetAt: w.start.Add(w.size)})
+	}
+	return resp
+}
+
+// BannedUntil end of the pause set by the last 429/418, zero when never paused
+func (l *RateLimiter) BannedUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bannedUntil
+}
+
+// Acquire reserve weight and orders for a request sent outside the limiter's HTTPClient
+func (l *RateLimiter) Acquire(ctx context.Context, weight, orders int64) error {
+	return l.acquire(ctx, requestCost{weight: weight, orders: orders, raw: 1})
+}
+
+// acquire BLOCK waits while the wait fits in ctx's deadline, otherwise the *RateLimitError is returned at once
+func (l *RateLimiter) acquire(ctx context.Context, cost requestCost) error {
+	for {
+		l.mu.Lock()
+		refused := l.reserve(cost)
+		l.mu.Unlock()
+		if refused == nil {
+			return nil
+		}
+		if l.mode == RateLimitFailFast {
+			return refused
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < refused.RetryAfter {
+			return refused
+		}
+		timer := time.NewTimer(refused.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve add cost to every window, or the refusal with the longest wait when one is full.
+// A request heavier than a whole limit still goes through on an empty window
+func (l *RateLimiter) reserve(cost requestCost) *RateLimitError {
+	now := l.now()
+	if now.Before(l.bannedUntil) {
+		return &RateLimitError{Type: rateLimitBanned, RetryAfter: l.bannedUntil.Sub(now)}
+	}
+	var refused *RateLimitError
+	for _, w := range l.windows {
+		w.roll(now)
+		n := w.cost(cost)
+		if n == 0 || w.used == 0 || w.used+n <= w.limit {
+			continue
+		}
+		retryAfter := w.start.Add(w.size).Sub(now)
+		if refused == nil || retryAfter > refused.RetryAfter {
+			refused = &RateLimitError{Type: w.limitType, Interval: w.size, Used: w.used, Limit: w.limit, RetryAfter: retryAfter}
+		}
+	}
+	if refused != nil {
+		return refused
+	}
+	for _, w := range l.windows {
+		w.used += w.cost(cost)
+	}
+	return nil
+}
+
+// observe sync the counts from the response headers and pause on 429/418
+func (l *RateLimiter) observe(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for _, w := range l.windows {
+		if w.header == "" {
+			continue
+		}
+		used, err := strconv.ParseInt(resp.Header.Get(w.header), 10, 64)
+		if err != nil {
+			continue
+		}
+		w.roll(now)
+		if used > w.used {
+			w.used = used
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+		wait := l.banWait
+		if seconds, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		if until := now.Add(wait); until.After(l.bannedUntil) {
+			l.bannedUntil = until
+		}
+	}
+}
+
+func (l *RateLimiter) requestCost(req *http.Request) requestCost {
+	if !strings.HasPrefix(req.URL.Path, "/api/") {
+		return requestCost{}
+	}
+	key := req.Method + " " + req.URL.Path
+	cost := requestCost{orders: orderEndpoints[key], raw: 1}
+	if weight, ok := l.weights[key]; ok {
+		cost.weight = weight
+	} else {
+		cost.weight = endpointWeight(req.Method, req.URL.Path, req.URL.Query())
+	}
+	return cost
+}
+
+type rateLimitTransport struct {
+	limiter *RateLimiter
+	base    http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.acquire(req.Context(), t.limiter.requestCost(req)); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.limiter.observe(resp)
+	return resp, nil
+}
+
+// HTTPClient copy of client (http.DefaultClient when nil) whose requests go through the limiter
+func (l *RateLimiter) HTTPClient(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	copied := *client
+	if copied.Transport == nil {
+		copied.Transport = http.DefaultTransport
+	}
+	copied.Transport = &rateLimitTransport{limiter: l, base: copied.Transport}
+	return &copied
+}
+
+// NewSpotClientWithLimiter route every request through limiter, share it between the clients of one IP / account.
+// The client works on a copy of spotClient, which is left unchanged; set TimeOffset on spotClient before
+func NewSpotClientWithLimiter(spotClient *binance.Client, limiter *RateLimiter) *SpotClient {
+	copied := *spotClient
+	copied.HTTPClient = limiter.HTTPClient(spotClient.HTTPClient)
+	c := NewSpotClientWithExchange(NewBinanceExchange(&copied))
+	c.limiter = limiter
+	return c
+}
+
+// Limiter nil unless the client was built by NewSpotClient or NewSpotClientWithLimiter
+func (c *SpotClient) Limiter() *RateLimiter {
+	return c.limiter
+}
+
+// SyncRateLimits adopt the rateLimits of the cached exchangeInfo
+func (c *SpotClient) SyncRateLimits(ctx context.Context) error {
+	if c.limiter == nil {
+		return errors.New("client has no rate limiter")
+	}
+	limits, err := c.registry.RateLimits(ctx)
+	if err != nil {
+		return err
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+	return c.limiter.SetRateLimits(limits)
+}
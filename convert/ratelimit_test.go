@@ -0,0 +1,170 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestEndpointWeight(t *testing.T) {
+	convey.Convey("TestEndpointWeight", t, func(convCtx convey.C) {
+		convCtx.So(endpointWeight("GET", "/api/v3/depth", url.Values{"symbol": {"BTCUSDT"}}), convey.ShouldEqual, 5)
+		convCtx.So(endpointWeight("GET", "/api/v3/depth", url.Values{"limit": {"500"}}), convey.ShouldEqual, 25)
+		convCtx.So(endpointWeight("GET", "/api/v3/depth", url.Values{"limit": {"5000"}}), convey.ShouldEqual, 250)
+		convCtx.So(endpointWeight("GET", "/api/v3/ticker/bookTicker", url.Values{"symbol": {"BTCUSDT"}}), convey.ShouldEqual, 2)
+		convCtx.So(endpointWeight("GET", "/api/v3/ticker/bookTicker", url.Values{}), convey.ShouldEqual, 4)
+		convCtx.So(endpointWeight("GET", "/api/v3/openOrders", url.Values{}), convey.ShouldEqual, 80)
+		convCtx.So(endpointWeight("GET", "/api/v3/exchangeInfo", url.Values{}), convey.ShouldEqual, 20)
+		convCtx.So(endpointWeight("POST", "/api/v3/order", url.Values{}), convey.ShouldEqual, 1)
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	convey.Convey("TestRateLimiter", t, func(convCtx convey.C) {
+		limiter, err := NewRateLimiter(&RateLimitConfig{
+			Mode: RateLimitFailFast,
+			Limits: []binance.RateLimit{
+				{RateLimitType: "REQUEST_WEIGHT", Interval: "MINUTE", IntervalNum: 1, Limit: 30},
+				{RateLimitType: "ORDERS", Interval: "SECOND", IntervalNum: 10, Limit: 2},
+				{RateLimitType: "CONNECTIONS", Interval: "MINUTE", IntervalNum: 1, Limit: 1},
+			},
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(limiter.Usage()), convey.ShouldEqual, 2)
+		clock := time.Date(2022, 10, 1, 12, 0, 5, 0, time.UTC)
+		limiter.now = func() time.Time { return clock }
+		ctx := context.Background()
+
+		convCtx.So(limiter.Acquire(ctx, 20, 0), convey.ShouldBeNil)
+		err = limiter.Acquire(ctx, 20, 0)
+		convCtx.So(errors.Is(err, ErrRateLimited), convey.ShouldBeTrue)
+		var limitErr *RateLimitError
+		convCtx.So(errors.As(err, &limitErr), convey.ShouldBeTrue)
+		convCtx.So(limitErr.Type, convey.ShouldEqual, "REQUEST_WEIGHT")
+		convCtx.So(limitErr.Used, convey.ShouldEqual, 20)
+		convCtx.So(limitErr.RetryAfter, convey.ShouldEqual, 55*time.Second)
+
+		// the minute window rolls over at 12:01:00
+		clock = clock.Add(55 * time.Second)
+		convCtx.So(limiter.Acquire(ctx, 20, 1), convey.ShouldBeNil)
+		convCtx.So(limiter.Acquire(ctx, 1, 1), convey.ShouldBeNil)
+		err = limiter.Acquire(ctx, 1, 1)
+		convCtx.So(errors.As(err, &limitErr), convey.ShouldBeTrue)
+		convCtx.So(limitErr.Type, convey.ShouldEqual, "ORDERS")
+		convCtx.So(limitErr.RetryAfter, convey.ShouldEqual, 10*time.Second)
+		usage := limiter.Usage()
+		convCtx.So(usage[0].Used, convey.ShouldEqual, 21)
+		convCtx.So(usage[1].Used, convey.ShouldEqual, 2)
+
+		// a blocking limiter refuses at once when the wait does not fit in the deadline
+		limiter.mode = RateLimitBlock
+		deadline, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		convCtx.So(errors.Is(limiter.Acquire(deadline, 1, 1), ErrRateLimited), convey.ShouldBeTrue)
+
+		// counts survive new limits of the same window
+		convCtx.So(limiter.SetRateLimits([]binance.RateLimit{{RateLimitType: "REQUEST_WEIGHT", Interval: "MINUTE", IntervalNum: 1, Limit: 100}}), convey.ShouldBeNil)
+		convCtx.So(limiter.Usage()[0].Used, convey.ShouldEqual, 21)
+		convCtx.So(limiter.SetRateLimits([]binance.RateLimit{{RateLimitType: "ORDERS", Interval: "WEEK", IntervalNum: 1, Limit: 1}}), convey.ShouldNotBeNil)
+	})
+}
+
+func TestRateLimiterBlock(t *testing.T) {
+	convey.Convey("TestRateLimiterBlock", t, func(convCtx convey.C) {
+		limiter, err := NewRateLimiter(&RateLimitConfig{
+			Limits: []binance.RateLimit{{RateLimitType: "ORDERS", Interval: "SECOND", IntervalNum: 1, Limit: 1}},
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		ctx := context.Background()
+		convCtx.So(limiter.Acquire(ctx, 1, 1), convey.ShouldBeNil)
+		start := time.Now()
+		convCtx.So(limiter.Acquire(ctx, 1, 1), convey.ShouldBeNil)
+		convCtx.So(time.Now().Truncate(time.Second).After(start.Truncate(time.Second)), convey.ShouldBeTrue)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		convCtx.So(limiter.Acquire(canceled, 1, 1), convey.ShouldEqual, context.Canceled)
+	})
+}
+
+func TestRateLimiterMockServer(t *testing.T) {
+	convey.Convey("TestRateLimiterMockServer", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		fake.SetRateLimits(
+			binance.RateLimit{RateLimitType: "REQUEST_WEIGHT", Interval: "MINUTE", IntervalNum: 1, Limit: 100},
+			binance.RateLimit{RateLimitType: "RAW_REQUESTS", Interval: "MINUTE", IntervalNum: 5, Limit: 1000},
+		)
+		limiter, err := NewRateLimiter(&RateLimitConfig{Mode: RateLimitFailFast})
+		convCtx.So(err, convey.ShouldBeNil)
+		clock := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+		limiter.now = func() time.Time { return clock }
+		client := server.Client()
+		httpClient := client.HTTPClient
+		cli := NewSpotClientWithLimiter(client, limiter)
+		ctx := context.Background()
+		convCtx.So(client.HTTPClient, convey.ShouldEqual, httpClient)
+		convCtx.So(cli.Limiter(), convey.ShouldEqual, limiter)
+
+		// NewSpotClient counts weight with the default limits
+		defaultCli := NewSpotClient(client)
+		convCtx.So(client.HTTPClient, convey.ShouldEqual, httpClient)
+		_, err = defaultCli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(defaultCli.Limiter().Usage()[0].Limit, convey.ShouldEqual, 6000)
+		convCtx.So(defaultCli.Limiter().Usage()[0].Used, convey.ShouldBeGreaterThan, 0)
+		convCtx.So(limiter.Usage()[0].Used, convey.ShouldEqual, 0)
+
+		convCtx.So(cli.SyncRateLimits(ctx), convey.ShouldBeNil)
+		usage := limiter.Usage()
+		convCtx.So(len(usage), convey.ShouldEqual, 2)
+		convCtx.So(usage[0].Limit, convey.ShouldEqual, 100)
+		convCtx.So(usage[0].Used, convey.ShouldEqual, 20) // exchangeInfo
+
+		// another process on the same IP used more weight than we did
+		server.Script("GET", "/api/v3/ticker/bookTicker", MockResponse{
+			Body:   `{"symbol":"LUNCBUSD","bidPrice":"0.00020000","bidQty":"1","askPrice":"0.00020010","askQty":"1"}`,
+			Header: http.Header{"X-Mbx-Used-Weight-1m": {"95"}},
+		})
+		_, err = cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(limiter.Usage()[0].Used, convey.ShouldEqual, 95)
+		_, err = cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		_, err = cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		_, err = cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD"})
+		convCtx.So(errors.Is(err, ErrRateLimited), convey.ShouldBeTrue)
+		requests := len(server.Requests())
+
+		// 429 pauses every request for Retry-After, /sapi included
+		clock = clock.Add(time.Minute)
+		server.Script("GET", "/api/v3/ticker/bookTicker", MockResponse{
+			Status: http.StatusTooManyRequests,
+			Body:   `{"code":-1003,"msg":"Too many requests."}`,
+			Header: http.Header{"Retry-After": {"30"}},
+		})
+		_, err = cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD"})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -1003)
+		convCtx.So(limiter.BannedUntil(), convey.ShouldResemble, clock.Add(30*time.Second))
+		_, err = cli.TradeFee(ctx, &TradeFeeReq{Symbol: "LUNCBUSD"})
+		var limitErr *RateLimitError
+		convCtx.So(errors.As(err, &limitErr), convey.ShouldBeTrue)
+		convCtx.So(limitErr.Type, convey.ShouldEqual, "BANNED")
+		convCtx.So(len(server.Requests()), convey.ShouldEqual, requests+1)
+
+		clock = clock.Add(30 * time.Second)
+		_, err = cli.TradeFee(ctx, &TradeFeeReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+
+		convCtx.So(NewSpotClientWithExchange(fake).SyncRateLimits(ctx), convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,343 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// DefaultSymbolTTL exchangeInfo older than this is reloaded on the next lookup
+const DefaultSymbolTTL = 30 * time.Minute
+
+// unknown symbols reload exchangeInfo at most once per interval, for new listings
+const unknownSymbolRefreshInterval = time.Minute
+
+// after a failed reload the loaded symbols are served for this long before the next attempt
+const failedReloadRetryInterval = 10 * time.Second
+
+// SymbolInfo typed exchangeInfo of one symbol
+type SymbolInfo struct {
+	Symbol                     string
+	Status                     string
+	BaseAsset                  string
+	QuoteAsset                 string
+	BaseAssetPrecision         int32
+	QuotePrecision             int32
+	QuoteAssetPrecision        int32
+	BaseCommissionPrecision    int32
+	QuoteCommissionPrecision   int32
+	OrderTypes                 []string
+	Permissions                []string
+	IcebergAllowed             bool
+	OcoAllowed                 bool
+	QuoteOrderQtyMarketAllowed bool
+	IsSpotTradingAllowed       bool
+	IsMarginTradingAllowed     bool
+	Filters                    *SymbolFilters
+	Raw                        binance.Symbol
+}
+
+func newSymbolInfo(s binance.Symbol) (*SymbolInfo, error) {
+	filters, err := ParseSymbolFilters(s.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("%s filters %w", s.Symbol, err)
+	}
+	return &SymbolInfo{
+		Symbol:                     s.Symbol,
+		Status:                     s.Status,
+		BaseAsset:                  s.BaseAsset,
+		QuoteAsset:                 s.QuoteAsset,
+		BaseAssetPrecision:         int32(s.BaseAssetPrecision),
+		QuotePrecision:             int32(s.QuotePrecision),
+		QuoteAssetPrecision:        int32(s.QuoteAssetPrecision),
+		BaseCommissionPrecision:    s.BaseCommissionPrecision,
+		QuoteCommissionPrecision:   s.QuoteCommissionPrecision,
+		OrderTypes:                 s.OrderTypes,
+		Permissions:                s.Permissions,
+		IcebergAllowed:             s.IcebergAllowed,
+		OcoAllowed:                 s.OcoAllowed,
+		QuoteOrderQtyMarketAllowed: s.QuoteOrderQtyMarketAllowed,
+		IsSpotTradingAllowed:       s.IsSpotTradingAllowed,
+		IsMarginTradingAllowed:     s.IsMarginTradingAllowed,
+		Filters:                    filters,
+		Raw:                        s,
+	}, nil
+}
+
+// IsTrading symbol status is TRADING
+func (s *SymbolInfo) IsTrading() bool {
+	return s.Status == "TRADING"
+}
+
+func (s *SymbolInfo) HasPermission(permission string) bool {
+	for _, p := range s.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SymbolInfo) SupportsOrderType(orderType binance.OrderType) bool {
+	for _, t := range s.OrderTypes {
+		if t == string(orderType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalizer for quantities and prices of this symbol
+func (s *SymbolInfo) Normalizer() (*Normalizer, error) {
+	return NewNormalizer(&s.Raw)
+}
+
+// SymbolRegistry exchangeInfo of every symbol loaded once and shared by all goroutines of a SpotClient.
+// It reloads when older than ttl, when Invalidate is called, or after a filter / invalid symbol error
+type SymbolRegistry struct {
+	exchange Exchange
+	ttl      time.Duration
+	now      func() time.Time
+
+	loadMu sync.Mutex // one reload at a time
+
+	mu         sync.RWMutex
+	symbols    map[string]*SymbolInfo
+	rateLimits []binance.RateLimit
+	loadedAt   time.Time
+	failedAt   time.Time
+	stale      bool
+	lastErr    error
+}
+
+// NewSymbolRegistry ttl <= 0 never expires, use Invalidate or StartAutoRefresh to reload
+func NewSymbolRegistry(exchange Exchange, ttl time.Duration) *SymbolRegistry {
+	return &SymbolRegistry{exchange: exchange, ttl: ttl, now: time.Now}
+}
+
+func (r *SymbolRegistry) expired(now time.Time) bool {
+	if r.symbols == nil {
+		return true
+	}
+	if r.retrying(now) {
+		return false
+	}
+	if r.stale {
+		return true
+	}
+	return r.ttl > 0 && now.Sub(r.loadedAt) >= r.ttl
+}
+
+// retrying a reload failed within failedReloadRetryInterval
+func (r *SymbolRegistry) retrying(now time.Time) bool {
+	return !r.failedAt.IsZero() && now.Sub(r.failedAt) < failedReloadRetryInterval
+}
+
+// Refresh reload exchangeInfo now, the previous symbols stay in use when it fails
+func (r *SymbolRegistry) Refresh(ctx context.Context) error {
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+	return r.load(ctx)
+}
+
+func (r *SymbolRegistry) load(ctx context.Context) error {
+	info, err := r.exchange.ExchangeInfo(ctx)
+	if err == nil {
+		symbols := make(map[string]*SymbolInfo, len(info.Symbols))
+		for _, s := range info.Symbols {
+			var si *SymbolInfo
+			if si, err = newSymbolInfo(s); err != nil {
+				break
+			}
+			symbols[s.Symbol] = si
+		}
+		if err == nil {
+			r.mu.Lock()
+			r.symbols, r.rateLimits, r.loadedAt, r.failedAt, r.stale, r.lastErr = symbols, info.RateLimits, r.now(), time.Time{}, false, nil
+			r.mu.Unlock()
+			return nil
+		}
+	}
+	r.mu.Lock()
+	r.failedAt, r.lastErr = r.now(), err
+	r.mu.Unlock()
+	return err
+}
+
+// ensure load when expired, concurrent callers wait for a single reload
+func (r *SymbolRegistry) ensure(ctx context.Context) error {
+	r.mu.RLock()
+	expired := r.expired(r.now())
+	r.mu.RUnlock()
+	if !expired {
+		return nil
+	}
+
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+	r.mu.RLock()
+	expired, loaded := r.expired(r.now()), r.symbols != nil
+	r.mu.RUnlock()
+	if !expired {
+		return nil
+	}
+	if err := r.load(ctx); err != nil && !loaded {
+		return err
+	}
+	return nil
+}
+
+// Symbol lookup one symbol, errors.Is(err, ErrUnknownSymbol) when binance does not list it
+func (r *SymbolRegistry) Symbol(ctx context.Context, symbol string) (*SymbolInfo, error) {
+	if err := r.ensure(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	s, ok := r.symbols[symbol]
+	now := r.now()
+	recent := now.Sub(r.loadedAt) < unknownSymbolRefreshInterval || r.retrying(now)
+	r.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+	if !recent {
+		if err := r.Refresh(ctx); err != nil {
+			return nil, err
+		}
+		r.mu.RLock()
+		s, ok = r.symbols[symbol]
+		r.mu.RUnlock()
+		if ok {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w : %s", ErrUnknownSymbol, symbol)
+}
+
+// Symbols every listed symbol sorted by name
+func (r *SymbolRegistry) Symbols(ctx context.Context) ([]*SymbolInfo, error) {
+	if err := r.ensure(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	resp := make([]*SymbolInfo, 0, len(r.symbols))
+	for _, s := range r.symbols {
+		resp = append(resp, s)
+	}
+	sort.Slice(resp, func(i, j int) bool { return resp[i].Symbol < resp[j].Symbol })
+	return resp, nil
+}
+
+// RateLimits exchangeInfo rateLimits (REQUEST_WEIGHT, ORDERS, RAW_REQUESTS)
+func (r *SymbolRegistry) RateLimits(ctx context.Context) ([]binance.RateLimit, error) {
+	if err := r.ensure(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]binance.RateLimit(nil), r.rateLimits...), nil
+}
+
+func (r *SymbolRegistry) BaseAsset(ctx context.Context, symbol string) (string, error) {
+	s, err := r.Symbol(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+	return s.BaseAsset, nil
+}
+
+func (r *SymbolRegistry) QuoteAsset(ctx context.Context, symbol string) (string, error) {
+	s, err := r.Symbol(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+	return s.QuoteAsset, nil
+}
+
+func (r *SymbolRegistry) Status(ctx context.Context, symbol string) (string, error) {
+	s, err := r.Symbol(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+	return s.Status, nil
+}
+
+func (r *SymbolRegistry) Filters(ctx context.Context, symbol string) (*SymbolFilters, error) {
+	s, err := r.Symbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return s.Filters, nil
+}
+
+func (r *SymbolRegistry) Permissions(ctx context.Context, symbol string) ([]string, error) {
+	s, err := r.Symbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return s.Permissions, nil
+}
+
+// Precisions base and quote asset precision of symbol
+func (r *SymbolRegistry) Precisions(ctx context.Context, symbol string) (base, quote int32, err error) {
+	s, err := r.Symbol(ctx, symbol)
+	if err != nil {
+		return 0, 0, err
+	}
+	return s.BaseAssetPrecision, s.QuoteAssetPrecision, nil
+}
+
+// Invalidate reload on the next lookup, the current symbols are served until then
+func (r *SymbolRegistry) Invalidate() {
+	r.mu.Lock()
+	r.stale = true
+	r.mu.Unlock()
+}
+
+// InvalidateOnError Invalidate when err means exchangeInfo is out of date:
+// -1013 filter failure, -1121 invalid symbol
+func (r *SymbolRegistry) InvalidateOnError(err error) {
+	var apiErr *common.APIError
+	if errors.As(err, &apiErr) && (apiErr.Code == -1013 || apiErr.Code == -1121) {
+		r.Invalidate()
+	}
+}
+
+// LastError error of the last reload, nil when it succeeded
+func (r *SymbolRegistry) LastError() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastErr
+}
+
+// LoadedAt time of the last successful reload
+func (r *SymbolRegistry) LoadedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.loadedAt
+}
+
+// StartAutoRefresh reload every interval in the background until ctx is done,
+// failures are kept in LastError
+func (r *SymbolRegistry) StartAutoRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = r.Refresh(ctx)
+			}
+		}
+	}()
+}
@@ -0,0 +1,129 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingExchange count exchangeInfo requests, failing when fail is set
+type countingExchange struct {
+	Exchange
+	exchangeInfoCalls int32
+	fail              int32
+}
+
+func (e *countingExchange) ExchangeInfo(ctx context.Context, symbols ...string) (*binance.ExchangeInfo, error) {
+	atomic.AddInt32(&e.exchangeInfoCalls, 1)
+	if atomic.LoadInt32(&e.fail) != 0 {
+		return nil, &common.APIError{Code: -1003, Message: "Too many requests."}
+	}
+	return e.Exchange.ExchangeInfo(ctx, symbols...)
+}
+
+func TestSymbolRegistry(t *testing.T) {
+	convey.Convey("TestSymbolRegistry", t, func(convCtx convey.C) {
+		exchange := &countingExchange{Exchange: newTestFakeExchange()}
+		registry := NewSymbolRegistry(exchange, time.Minute)
+		now := time.Now()
+		registry.now = func() time.Time { return now }
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = registry.Symbol(ctx, "LUNCBUSD")
+			}()
+		}
+		wg.Wait()
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 1)
+
+		s, err := registry.Symbol(ctx, "LUNCBUSD")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(s.BaseAsset, convey.ShouldEqual, "LUNC")
+		convCtx.So(s.QuoteAsset, convey.ShouldEqual, "BUSD")
+		convCtx.So(s.Filters.LotSize.StepSize.String(), convey.ShouldEqual, "1")
+
+		base, quote, err := registry.Precisions(ctx, "LUNCBUSD")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(base, convey.ShouldEqual, 8)
+		convCtx.So(quote, convey.ShouldEqual, 8)
+
+		symbols, err := registry.Symbols(ctx)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(symbols), convey.ShouldEqual, 2)
+		convCtx.So(symbols[0].Symbol, convey.ShouldEqual, "EOSBTC")
+
+		// unknown symbols reload at most once a minute
+		_, err = registry.Symbol(ctx, "XXXYYY")
+		convCtx.So(errors.Is(err, ErrUnknownSymbol), convey.ShouldBeTrue)
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 1)
+
+		now = now.Add(time.Minute)
+		_, err = registry.Symbol(ctx, "EOSBTC")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 2)
+
+		registry.InvalidateOnError(&common.APIError{Code: -2010, Message: "Account has insufficient balance for requested action."})
+		_, _ = registry.Symbol(ctx, "EOSBTC")
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 2)
+
+		registry.InvalidateOnError(&common.APIError{Code: -1013, Message: "Filter failure: LOT_SIZE"})
+		_, _ = registry.Symbol(ctx, "EOSBTC")
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 3)
+
+		// a failed reload serves the loaded symbols and waits before trying again
+		atomic.StoreInt32(&exchange.fail, 1)
+		registry.Invalidate()
+		for i := 0; i < 3; i++ {
+			s, err = registry.Symbol(ctx, "EOSBTC")
+			convCtx.So(err, convey.ShouldBeNil)
+			convCtx.So(s.Symbol, convey.ShouldEqual, "EOSBTC")
+			_, err = registry.Symbol(ctx, "XXXYYY")
+			convCtx.So(errors.Is(err, ErrUnknownSymbol), convey.ShouldBeTrue)
+		}
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 4)
+		convCtx.So(registry.LastError(), convey.ShouldNotBeNil)
+
+		now = now.Add(failedReloadRetryInterval)
+		_, err = registry.Symbol(ctx, "EOSBTC")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 5)
+
+		atomic.StoreInt32(&exchange.fail, 0)
+		now = now.Add(failedReloadRetryInterval)
+		_, err = registry.Symbol(ctx, "EOSBTC")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 6)
+		convCtx.So(registry.LastError(), convey.ShouldBeNil)
+		_, _ = registry.Symbol(ctx, "EOSBTC")
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 6)
+	})
+}
+
+func TestSpotClientUsesRegistry(t *testing.T) {
+	convey.Convey("TestSpotClientUsesRegistry", t, func(convCtx convey.C) {
+		exchange := &countingExchange{Exchange: newTestFakeExchange()}
+		cli := NewSpotClientWithExchange(exchange)
+		ctx := context.Background()
+
+		_, err := cli.EstQuote(ctx, &EstQuoteReq{Symbol: "LUNCBUSD"})
+		convCtx.So(err, convey.ShouldBeNil)
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(err, convey.ShouldBeNil)
+		_, err = cli.ValidateOrder(ctx, &ValidateOrderReq{Symbol: "LUNCBUSD", Side: "BUY", Type: "LIMIT", Quantity: "100000", Price: "0.0002"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(atomic.LoadInt32(&exchange.exchangeInfoCalls), convey.ShouldEqual, 1)
+
+		_, err = cli.Trade(ctx, &TradeReq{Symbol: "NOPE", Side: "BUY", Quantity: "20"})
+		convCtx.So(errors.Is(err, ErrUnknownSymbol), convey.ShouldBeTrue)
+	})
+}
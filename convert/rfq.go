@@ -0,0 +1,343 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+闪兑 (Convert RFQ):
+
+向 binance 询价 (getQuote) 得到一个锁定的兑换比例, 在 validTimestamp 之前 acceptQuote 成交, 不收手续费.
+accept 之后订单是 PROCESS, 用 orderStatus 轮询到 SUCCESS 或 FAIL.
+CompareConvertQuote 同时按深度估算现货市价兑换 (QuoteRoutes, 含 taker 手续费), 比较两者实际到手数量.
+*/
+
+const (
+	ConvertStatusProcess       = "PROCESS"
+	ConvertStatusAcceptSuccess = "ACCEPT_SUCCESS"
+	ConvertStatusSuccess       = "SUCCESS"
+	ConvertStatusFail          = "FAIL"
+
+	maxConvertHistoryRange = 30 * 24 * time.Hour
+	convertPollInterval    = time.Second
+)
+
+var ErrConvertQuoteExpired = errors.New("convert quote expired")
+
+// ConvertQuoteReq exactly one of FromAmount and ToAmount
+type ConvertQuoteReq struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	FromAmount string `json:"fromAmount"` // amount of From to spend
+	ToAmount   string `json:"toAmount"`   // amount of To to receive
+	WalletType string `json:"walletType"` // SPOT FUNDING, default SPOT
+	ValidTime  string `json:"validTime"`  // 10s 30s 1m 2m, default 10s
+}
+
+type ConvertQuoteResp struct {
+	QuoteId        string  `json:"quoteId"`
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	FromAmount     Decimal `json:"fromAmount"`
+	ToAmount       Decimal `json:"toAmount"`
+	Ratio          Decimal `json:"ratio"`        // To per From
+	InverseRatio   Decimal `json:"inverseRatio"` // From per To
+	ValidTimestamp int64   `json:"validTimestamp"`
+}
+
+// Expired the quote can no longer be accepted at now
+func (q *ConvertQuoteResp) Expired(now time.Time) bool {
+	return binance.FormatTimestamp(now) >= q.ValidTimestamp
+}
+
+// ConvertQuote request a zero-fee quote, accept it with AcceptConvertQuote before ValidTimestamp
+func (c *SpotClient) ConvertQuote(ctx context.Context, req *ConvertQuoteReq) (*ConvertQuoteResp, error) {
+	from, to := strings.ToUpper(req.From), strings.ToUpper(req.To)
+	if from == "" || to == "" || from == to {
+		return nil, errors.New(fmt.Sprintf("invalid conversion %q -> %q", req.From, req.To))
+	}
+	if (req.FromAmount == "") == (req.ToAmount == "") {
+		return nil, errors.New("exactly one of fromAmount and toAmount is required")
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+	}{{"fromAmount", req.FromAmount}, {"toAmount", req.ToAmount}} {
+		if field.value == "" {
+			continue
+		}
+		amount, err := ParseDecimal(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", field.name, err)
+		}
+		if amount.Sign() <= 0 {
+			return nil, errors.New(fmt.Sprintf("%s must be positive", field.name))
+		}
+	}
+
+	res, err := c.exchange.GetConvertQuote(ctx, &ConvertQuoteParams{
+		FromAsset:  from,
+		ToAsset:    to,
+		FromAmount: req.FromAmount,
+		ToAmount:   req.ToAmount,
+		WalletType: req.WalletType,
+		ValidTime:  req.ValidTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ConvertQuoteResp{QuoteId: res.QuoteId, From: from, To: to, ValidTimestamp: res.ValidTimestamp}
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   *Decimal
+	}{
+		{"fromAmount", res.FromAmount, &resp.FromAmount},
+		{"toAmount", res.ToAmount, &resp.ToAmount},
+		{"ratio", res.Ratio, &resp.Ratio},
+		{"inverseRatio", res.InverseRatio, &resp.InverseRatio},
+	} {
+		if *field.dst, err = ParseDecimal(field.value); err != nil {
+			return nil, fmt.Errorf("quote %s %w", field.name, err)
+		}
+	}
+	return resp, nil
+}
+
+type AcceptConvertQuoteReq struct {
+	QuoteId        string `json:"quoteId"`
+	ValidTimestamp int64  `json:"validTimestamp"` // when set an expired quote is refused without calling the api
+}
+
+// ConvertOrderResp From / To fields are empty in the AcceptConvertQuote response, QuoteId is only known there and in history
+type ConvertOrderResp struct {
+	OrderId      int64   `json:"orderId"`
+	QuoteId      string  `json:"quoteId,omitempty"`
+	Status       string  `json:"status"` // PROCESS ACCEPT_SUCCESS SUCCESS FAIL
+	From         string  `json:"from,omitempty"`
+	FromAmount   Decimal `json:"fromAmount"`
+	To           string  `json:"to,omitempty"`
+	ToAmount     Decimal `json:"toAmount"`
+	Ratio        Decimal `json:"ratio"`
+	InverseRatio Decimal `json:"inverseRatio"`
+	CreateTime   int64   `json:"createTime"`
+}
+
+// Done SUCCESS or FAIL
+func (o *ConvertOrderResp) Done() bool {
+	return o.Status == ConvertStatusSuccess || o.Status == ConvertStatusFail
+}
+
+func newConvertOrderResp(item *binance.ConvertTradeHistoryItem) (*ConvertOrderResp, error) {
+	resp := &ConvertOrderResp{
+		OrderId:    item.OrderId,
+		QuoteId:    item.QuoteId,
+		Status:     item.OrderStatus,
+		From:       item.FromAsset,
+		To:         item.ToAsset,
+		CreateTime: item.CreateTime,
+	}
+	var err error
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   *Decimal
+	}{
+		{"fromAmount", item.FromAmount, &resp.FromAmount},
+		{"toAmount", item.ToAmount, &resp.ToAmount},
+		{"ratio", item.Ratio, &resp.Ratio},
+		{"inverseRatio", item.InverseRatio, &resp.InverseRatio},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if *field.dst, err = ParseDecimal(field.value); err != nil {
+			return nil, fmt.Errorf("convert order %d %s %w", item.OrderId, field.name, err)
+		}
+	}
+	return resp, nil
+}
+
+// AcceptConvertQuote accept a quote of ConvertQuote, the order usually starts as PROCESS (see WaitConvertOrder)
+func (c *SpotClient) AcceptConvertQuote(ctx context.Context, req *AcceptConvertQuoteReq) (*ConvertOrderResp, error) {
+	if req.QuoteId == "" {
+		return nil, errors.New("quoteId is required")
+	}
+	if req.ValidTimestamp > 0 && binance.FormatTimestamp(time.Now()) >= req.ValidTimestamp {
+		return nil, ErrConvertQuoteExpired
+	}
+	res, err := c.exchange.AcceptConvertQuote(ctx, req.QuoteId)
+	if err != nil {
+		return nil, err
+	}
+	orderId, err := strconv.ParseInt(res.OrderId, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("orderId %w", err)
+	}
+	return &ConvertOrderResp{OrderId: orderId, QuoteId: req.QuoteId, Status: res.OrderStatus, CreateTime: res.CreateTime}, nil
+}
+
+type ConvertOrderReq struct {
+	OrderId int64  `json:"orderId"`
+	QuoteId string `json:"quoteId"`
+}
+
+// ConvertOrderStatus query a convert order by orderId or quoteId
+func (c *SpotClient) ConvertOrderStatus(ctx context.Context, req *ConvertOrderReq) (*ConvertOrderResp, error) {
+	if req.OrderId <= 0 && req.QuoteId == "" {
+		return nil, errors.New("orderId or quoteId is required")
+	}
+	item, err := c.exchange.GetConvertOrder(ctx, &QueryConvertOrderParams{OrderId: req.OrderId, QuoteId: req.QuoteId})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newConvertOrderResp(item)
+	if err != nil {
+		return nil, err
+	}
+	if resp.QuoteId == "" {
+		resp.QuoteId = req.QuoteId
+	}
+	return resp, nil
+}
+
+// WaitConvertOrder poll ConvertOrderStatus every interval (default 1s) until SUCCESS or FAIL,
+// a FAIL order is returned together with an error
+func (c *SpotClient) WaitConvertOrder(ctx context.Context, req *ConvertOrderReq, interval time.Duration) (*ConvertOrderResp, error) {
+	if interval <= 0 {
+		interval = convertPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		order, err := c.ConvertOrderStatus(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if order.Status == ConvertStatusFail {
+			return order, errors.New(fmt.Sprintf("convert order %d failed", order.OrderId))
+		}
+		if order.Done() {
+			return order, nil
+		}
+		select {
+		case <-ctx.Done():
+			return order, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ConvertHistoryReq default range is the last 30 days, which is also the longest binance accepts
+type ConvertHistoryReq struct {
+	StartTime int64 `json:"startTime"` // ms
+	EndTime   int64 `json:"endTime"`   // ms
+	Limit     int   `json:"limit"`     // default 100, at most 1000
+}
+
+type ConvertHistoryResp struct {
+	Data     []*ConvertOrderResp `json:"data"`
+	MoreData bool                `json:"moreData"` // more trades in range than Limit
+}
+
+// ConvertHistory convert trades created within [StartTime, EndTime]
+func (c *SpotClient) ConvertHistory(ctx context.Context, req *ConvertHistoryReq) (*ConvertHistoryResp, error) {
+	endTime := req.EndTime
+	if endTime <= 0 {
+		endTime = binance.FormatTimestamp(time.Now())
+	}
+	startTime := req.StartTime
+	if startTime <= 0 {
+		startTime = endTime - maxConvertHistoryRange.Milliseconds()
+	}
+	if startTime > endTime {
+		return nil, errors.New("startTime is after endTime")
+	}
+	if endTime-startTime > maxConvertHistoryRange.Milliseconds() {
+		return nil, errors.New("startTime and endTime are more than 30 days apart")
+	}
+	if req.Limit > 1000 {
+		return nil, errors.New("limit is at most 1000")
+	}
+
+	res, err := c.exchange.ListConvertTrades(ctx, &ListConvertTradesParams{StartTime: startTime, EndTime: endTime, Limit: req.Limit})
+	if err != nil {
+		return nil, err
+	}
+	resp := &ConvertHistoryResp{MoreData: res.MoreData}
+	for i := range res.List {
+		order, err := newConvertOrderResp(&res.List[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Data = append(resp.Data, order)
+	}
+	return resp, nil
+}
+
+// CompareConvertReq the RFQ quote request plus how spot routes are searched (see ConvertReq)
+type CompareConvertReq struct {
+	ConvertQuoteReq
+	MaxLegs    int      `json:"maxLegs"`
+	Via        []string `json:"via"`
+	DepthLimit int      `json:"depthLimit"`
+}
+
+const (
+	ConvertViaRFQ  = "RFQ"
+	ConvertViaSpot = "SPOT"
+)
+
+// ConvertComparison RFQ quote against the best spot route for the same FromAmount
+type ConvertComparison struct {
+	Quote         *ConvertQuoteResp `json:"quote"`
+	Spot          *RouteQuote       `json:"spot"`                 // nil when no spot route is executable
+	SpotReason    string            `json:"spotReason,omitempty"` // why Spot is nil
+	Difference    Decimal           `json:"difference"`           // quote toAmount - spot received, positive favours the quote
+	DifferenceBps Decimal           `json:"differenceBps"`        // Difference relative to the spot received amount
+	Best          string            `json:"best"`                 // RFQ SPOT
+}
+
+// CompareConvertQuote price req.FromAmount through the spot books first, then request the RFQ quote so it is
+// as fresh as possible when the caller accepts it
+func (c *SpotClient) CompareConvertQuote(ctx context.Context, req *CompareConvertReq) (*ConvertComparison, error) {
+	if req.FromAmount == "" || req.ToAmount != "" {
+		return nil, errors.New("fromAmount is required to compare with spot routes")
+	}
+	routes, err := c.QuoteRoutes(ctx, &ConvertReq{
+		From:       req.From,
+		To:         req.To,
+		Amount:     req.FromAmount,
+		MaxLegs:    req.MaxLegs,
+		Via:        req.Via,
+		DepthLimit: req.DepthLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	quote, err := c.ConvertQuote(ctx, &req.ConvertQuoteReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ConvertComparison{Quote: quote, Spot: routes.Best, Best: ConvertViaRFQ}
+	if routes.Best == nil {
+		resp.SpotReason = routes.Routes[0].Reason
+		return resp, nil
+	}
+	resp.Difference = quote.ToAmount.Sub(routes.Best.Received)
+	if routes.Best.Received.Sign() > 0 {
+		resp.DifferenceBps = resp.Difference.Mul(NewDecimalFromInt(10000)).Div(routes.Best.Received, 4).Round(2, RoundHalfUp)
+	}
+	if resp.Difference.Sign() < 0 {
+		resp.Best = ConvertViaSpot
+	}
+	return resp, nil
+}
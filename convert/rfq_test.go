@@ -0,0 +1,119 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestConvertQuote(t *testing.T) {
+	convey.Convey("TestConvertQuote", t, func(convCtx convey.C) {
+		fake := newTestRouteExchange()
+		server := NewMockServer(fake, "mockApiKey", "mockSecretKey")
+		defer server.Close()
+		cli := NewSpotClient(server.Client())
+		ctx := context.Background()
+
+		quote, err := cli.ConvertQuote(ctx, &ConvertQuoteReq{From: "busd", To: "eos", FromAmount: "70", ValidTime: "30s"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(quote.QuoteId, convey.ShouldNotBeEmpty)
+		convCtx.So(quote.ToAmount.String(), convey.ShouldEqual, "50")
+		convCtx.So(quote.Ratio.String(), convey.ShouldEqual, "0.71428571")
+		convCtx.So(quote.InverseRatio.String(), convey.ShouldEqual, "1.4")
+		convCtx.So(quote.Expired(time.Now()), convey.ShouldBeFalse)
+		convCtx.So(quote.Expired(time.Now().Add(31*time.Second)), convey.ShouldBeTrue)
+
+		byTo, err := cli.ConvertQuote(ctx, &ConvertQuoteReq{From: "EOS", To: "BUSD", ToAmount: "13.9"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(byTo.FromAmount.String(), convey.ShouldEqual, "10")
+
+		_, err = cli.ConvertQuote(ctx, &ConvertQuoteReq{From: "BUSD", To: "EOS", FromAmount: "70", ToAmount: "50"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+		_, err = cli.ConvertQuote(ctx, &ConvertQuoteReq{From: "BUSD", To: "EOS", FromAmount: "-1"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		_, err = cli.AcceptConvertQuote(ctx, &AcceptConvertQuoteReq{QuoteId: quote.QuoteId, ValidTimestamp: quote.ValidTimestamp - 60000})
+		convCtx.So(err, convey.ShouldEqual, ErrConvertQuoteExpired)
+
+		order, err := cli.AcceptConvertQuote(ctx, &AcceptConvertQuoteReq{QuoteId: quote.QuoteId, ValidTimestamp: quote.ValidTimestamp})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.Status, convey.ShouldEqual, ConvertStatusProcess)
+		convCtx.So(order.OrderId, convey.ShouldBeGreaterThan, 0)
+
+		order, err = cli.WaitConvertOrder(ctx, &ConvertOrderReq{OrderId: order.OrderId}, time.Millisecond)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.Status, convey.ShouldEqual, ConvertStatusSuccess)
+		convCtx.So(order.From, convey.ShouldEqual, "BUSD")
+		convCtx.So(order.FromAmount.String(), convey.ShouldEqual, "70")
+		convCtx.So(order.ToAmount.String(), convey.ShouldEqual, "50")
+		convCtx.So(fake.Balance("BUSD").String(), convey.ShouldEqual, "30")
+		convCtx.So(fake.Balance("EOS").String(), convey.ShouldEqual, "50")
+
+		byQuote, err := cli.ConvertOrderStatus(ctx, &ConvertOrderReq{QuoteId: quote.QuoteId})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(byQuote.OrderId, convey.ShouldEqual, order.OrderId)
+		convCtx.So(byQuote.QuoteId, convey.ShouldEqual, quote.QuoteId)
+
+		// a quote is accepted once
+		_, err = cli.AcceptConvertQuote(ctx, &AcceptConvertQuoteReq{QuoteId: quote.QuoteId})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+
+		// and not after validTimestamp
+		late, err := cli.ConvertQuote(ctx, &ConvertQuoteReq{From: "BUSD", To: "EOS", FromAmount: "10"})
+		convCtx.So(err, convey.ShouldBeNil)
+		fake.now = func() time.Time { return time.Now().Add(time.Minute) }
+		_, err = cli.AcceptConvertQuote(ctx, &AcceptConvertQuoteReq{QuoteId: late.QuoteId})
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		fake.now = time.Now
+		convCtx.So(fake.Balance("BUSD").String(), convey.ShouldEqual, "30")
+
+		history, err := cli.ConvertHistory(ctx, &ConvertHistoryReq{})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(history.Data), convey.ShouldEqual, 1)
+		convCtx.So(history.MoreData, convey.ShouldBeFalse)
+		convCtx.So(history.Data[0].QuoteId, convey.ShouldEqual, quote.QuoteId)
+		convCtx.So(history.Data[0].ToAmount.String(), convey.ShouldEqual, "50")
+		history, err = cli.ConvertHistory(ctx, &ConvertHistoryReq{EndTime: order.CreateTime - 1})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(history.Data), convey.ShouldEqual, 0)
+		_, err = cli.ConvertHistory(ctx, &ConvertHistoryReq{StartTime: 1, EndTime: 31 * 24 * 3600 * 1000})
+		convCtx.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestCompareConvertQuote(t *testing.T) {
+	convey.Convey("TestCompareConvertQuote", t, func(convCtx convey.C) {
+		cli := NewSpotClientWithExchange(newTestRouteExchange())
+		ctx := context.Background()
+
+		// the BTC route beats the direct RFQ price
+		cmp, err := cli.CompareConvertQuote(ctx, &CompareConvertReq{ConvertQuoteReq: ConvertQuoteReq{From: "BUSD", To: "EOS", FromAmount: "100"}})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(cmp.Quote.ToAmount.String(), convey.ShouldEqual, "71.42857142")
+		convCtx.So(cmp.Spot.Received.String(), convey.ShouldEqual, "77.96882812")
+		convCtx.So(cmp.Difference.String(), convey.ShouldEqual, "-6.5402567")
+		convCtx.So(cmp.DifferenceBps.String(), convey.ShouldEqual, "-838.83")
+		convCtx.So(cmp.Best, convey.ShouldEqual, ConvertViaSpot)
+
+		// the quote has no fee, a single spot leg pays the taker fee
+		cmp, err = cli.CompareConvertQuote(ctx, &CompareConvertReq{ConvertQuoteReq: ConvertQuoteReq{From: "BUSD", To: "EOS", FromAmount: "100"}, MaxLegs: 1})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(cmp.Spot.Received.String(), convey.ShouldEqual, "71.35714284")
+		convCtx.So(cmp.Difference.String(), convey.ShouldEqual, "0.07142858")
+		convCtx.So(cmp.Best, convey.ShouldEqual, ConvertViaRFQ)
+
+		// below minNotional no spot route is executable
+		cmp, err = cli.CompareConvertQuote(ctx, &CompareConvertReq{ConvertQuoteReq: ConvertQuoteReq{From: "BUSD", To: "EOS", FromAmount: "5"}})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(cmp.Spot, convey.ShouldBeNil)
+		convCtx.So(cmp.SpotReason, convey.ShouldContainSubstring, "NOTIONAL")
+		convCtx.So(cmp.Best, convey.ShouldEqual, ConvertViaRFQ)
+
+		_, err = cli.CompareConvertQuote(ctx, &CompareConvertReq{ConvertQuoteReq: ConvertQuoteReq{From: "BUSD", To: "EOS", ToAmount: "50"}})
+		convCtx.So(err, convey.ShouldNotBeNil)
+	})
+}
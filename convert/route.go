@@ -0,0 +1,382 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"sort"
+	"strings"
+)
+
+/*
+多跳兑换路由:
+
+用 exchangeInfo 建图 (资产为点, 交易对为边), 找出 From 到 To 不超过 MaxLegs 腿的路径,
+中间资产默认只走 DefaultRouteAssets (主流计价资产), 避免对每个交易对都拉一次深度.
+每条路径按深度逐腿估算 (上一腿扣除手续费后的到手数量作为下一腿的输入), 取最终到手最多的一条.
+执行时逐腿下市价单, 下一腿使用上一腿实际成交到手的数量, 任何一腿失败即停止并返回资金所在资产.
+*/
+
+// DefaultRouteAssets intermediate assets tried when ConvertReq.Via is empty
+var DefaultRouteAssets = []string{"USDT", "BUSD", "FDUSD", "USDC", "BTC", "ETH", "BNB"}
+
+const (
+	defaultRouteLegs = 2
+	maxRouteLegs     = 3
+)
+
+type ConvertReq struct {
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	Amount      string   `json:"amount"`      // amount of From to convert
+	MaxLegs     int      `json:"maxLegs"`     // default 2, at most 3
+	Via         []string `json:"via"`         // allowed intermediate assets, default DefaultRouteAssets
+	MinReceived string   `json:"minReceived"` // Convert refuses routes quoting less of To
+	DepthLimit  int      `json:"depthLimit"`  // depth snapshot levels per symbol, default 100
+}
+
+// RouteLeg one market order of a route, From is spent and To received
+type RouteLeg struct {
+	Symbol   string           `json:"symbol"`
+	Side     binance.SideType `json:"side"`
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Estimate *QuoteEstimate   `json:"estimate"`
+}
+
+type RouteQuote struct {
+	Path       []string    `json:"path"` // assets from From to To
+	Legs       []*RouteLeg `json:"legs"`
+	Amount     Decimal     `json:"amount"`
+	Received   Decimal     `json:"received"` // net of every leg's fee
+	Executable bool        `json:"executable"`
+	Reason     string      `json:"reason,omitempty"` // why the route is not executable
+}
+
+type RoutesResp struct {
+	Best   *RouteQuote   `json:"best"`   // nil when no route is executable
+	Routes []*RouteQuote `json:"routes"` // executable routes best first, then the rest
+}
+
+type routeEdge struct {
+	symbol *SymbolInfo
+	side   binance.SideType
+	to     string
+}
+
+// routeGraph market-tradable symbols as edges in both directions: base->quote SELL, quote->base BUY
+func routeGraph(symbols []*SymbolInfo) map[string][]routeEdge {
+	graph := make(map[string][]routeEdge)
+	for _, s := range symbols {
+		if !s.IsTrading() || !s.SupportsOrderType(binance.OrderTypeMarket) {
+			continue
+		}
+		if !s.IsSpotTradingAllowed && !s.HasPermission("SPOT") {
+			continue
+		}
+		graph[s.BaseAsset] = append(graph[s.BaseAsset], routeEdge{symbol: s, side: binance.SideTypeSell, to: s.QuoteAsset})
+		graph[s.QuoteAsset] = append(graph[s.QuoteAsset], routeEdge{symbol: s, side: binance.SideTypeBuy, to: s.BaseAsset})
+	}
+	return graph
+}
+
+// findRoutes every simple path from -> to of at most maxLegs edges through via assets
+func findRoutes(graph map[string][]routeEdge, from, to string, maxLegs int, via map[string]bool) [][]routeEdge {
+	var routes [][]routeEdge
+	visited := map[string]bool{from: true}
+	var walk func(asset string, path []routeEdge)
+	walk = func(asset string, path []routeEdge) {
+		for _, edge := range graph[asset] {
+			if edge.to == to {
+				routes = append(routes, append(append([]routeEdge(nil), path...), edge))
+				continue
+			}
+			if len(path)+1 >= maxLegs || visited[edge.to] || !via[edge.to] {
+				continue
+			}
+			visited[edge.to] = true
+			walk(edge.to, append(path, edge))
+			visited[edge.to] = false
+		}
+	}
+	walk(from, nil)
+	return routes
+}
+
+// routePricer caches depth snapshots and fee rates while pricing many routes
+type routePricer struct {
+	c          *SpotClient
+	depthLimit int
+	books      map[string]*OrderBook
+	fees       map[string]string
+}
+
+func (p *routePricer) book(ctx context.Context, symbol string) (*OrderBook, error) {
+	if book, ok := p.books[symbol]; ok {
+		return book, nil
+	}
+	depth, err := p.c.exchange.Depth(ctx, symbol, p.depthLimit)
+	if err != nil {
+		return nil, err
+	}
+	book := NewOrderBook(p.c.exchange, symbol, p.depthLimit)
+	if err = book.load(depth); err != nil {
+		return nil, err
+	}
+	p.books[symbol] = book
+	return book, nil
+}
+
+func (p *routePricer) feeRate(ctx context.Context, symbol string) (string, error) {
+	if rate, ok := p.fees[symbol]; ok {
+		return rate, nil
+	}
+	rate, err := p.c.takerFeeRate(ctx, symbol, "")
+	if err != nil {
+		return "", err
+	}
+	p.fees[symbol] = rate.String()
+	return p.fees[symbol], nil
+}
+
+// legKind a SELL spends base, a BUY spends quote
+func legKind(side binance.SideType) QuantityKind {
+	if side == binance.SideTypeSell {
+		return QuantityKindBase
+	}
+	return QuantityKindQuote
+}
+
+// price estimate the legs one after another, an error means the exchange could not be asked
+func (p *routePricer) price(ctx context.Context, from string, amount Decimal, edges []routeEdge) (*RouteQuote, error) {
+	route := &RouteQuote{Path: []string{from}, Amount: amount, Executable: true}
+	current := amount
+	for i, edge := range edges {
+		leg := &RouteLeg{Symbol: edge.symbol.Symbol, Side: edge.side, From: route.Path[i], To: edge.to}
+		route.Path = append(route.Path, edge.to)
+		route.Legs = append(route.Legs, leg)
+		if !route.Executable {
+			continue
+		}
+
+		book, err := p.book(ctx, edge.symbol.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		feeRate, err := p.feeRate(ctx, edge.symbol.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		kind := legKind(edge.side)
+		leg.Estimate, err = p.c.estimate(ctx, edge.symbol, &EstQuoteReq{
+			Symbol: edge.symbol.Symbol, Side: edge.side, Quantity: current.String(), QuantityKind: kind, FeeRate: feeRate, Book: book,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if !leg.Estimate.Complete {
+			route.Executable, route.Reason = false, fmt.Sprintf("%s order book too thin", edge.symbol.Symbol)
+			continue
+		}
+		if leg.Estimate.Quantity.Sign() <= 0 {
+			route.Executable, route.Reason = false, fmt.Sprintf("%s amount rounds to zero", edge.symbol.Symbol)
+			continue
+		}
+		check := &OrderCheck{Side: edge.side, Type: binance.OrderTypeMarket, AvgPrice: leg.Estimate.AvgPrice}
+		if kind == QuantityKindBase {
+			check.Quantity = leg.Estimate.Quantity
+		} else {
+			check.QuoteOrderQty = leg.Estimate.Quantity
+		}
+		if violations := edge.symbol.Filters.Validate(check); len(violations) > 0 {
+			route.Executable, route.Reason = false, fmt.Sprintf("%s %s", edge.symbol.Symbol, violations.Error())
+			continue
+		}
+		current = leg.Estimate.NetReceived
+	}
+	if route.Executable {
+		route.Received = current
+	}
+	return route, nil
+}
+
+// QuoteRoutes discover and price every route from req.From to req.To
+func (c *SpotClient) QuoteRoutes(ctx context.Context, req *ConvertReq) (*RoutesResp, error) {
+	from, to := strings.ToUpper(req.From), strings.ToUpper(req.To)
+	if from == "" || to == "" || from == to {
+		return nil, errors.New(fmt.Sprintf("invalid conversion %q -> %q", req.From, req.To))
+	}
+	amount, err := ParseDecimal(req.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("amount %w", err)
+	}
+	if amount.Sign() <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	maxLegs := req.MaxLegs
+	if maxLegs <= 0 {
+		maxLegs = defaultRouteLegs
+	}
+	if maxLegs > maxRouteLegs {
+		maxLegs = maxRouteLegs
+	}
+	viaAssets := req.Via
+	if len(viaAssets) == 0 {
+		viaAssets = DefaultRouteAssets
+	}
+	via := make(map[string]bool)
+	for _, asset := range viaAssets {
+		via[strings.ToUpper(asset)] = true
+	}
+
+	symbols, err := c.registry.Symbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paths := findRoutes(routeGraph(symbols), from, to, maxLegs, via)
+	if len(paths) == 0 {
+		return nil, errors.New(fmt.Sprintf("no route from %s to %s within %d legs", from, to, maxLegs))
+	}
+
+	pricer := &routePricer{c: c, depthLimit: req.DepthLimit, books: make(map[string]*OrderBook), fees: make(map[string]string)}
+	resp := &RoutesResp{}
+	for _, path := range paths {
+		route, err := pricer.price(ctx, from, amount, path)
+		if err != nil {
+			return nil, err
+		}
+		resp.Routes = append(resp.Routes, route)
+	}
+	sort.SliceStable(resp.Routes, func(i, j int) bool {
+		a, b := resp.Routes[i], resp.Routes[j]
+		if a.Executable != b.Executable {
+			return a.Executable
+		}
+		if cmp := a.Received.Cmp(b.Received); cmp != 0 {
+			return cmp > 0
+		}
+		return len(a.Legs) < len(b.Legs)
+	})
+	if resp.Routes[0].Executable {
+		resp.Best = resp.Routes[0]
+	}
+	return resp, nil
+}
+
+type ConvertLegResult struct {
+	Leg      *RouteLeg  `json:"leg"`
+	Order    *TradeResp `json:"order"`
+	Spent    Decimal    `json:"spent"`
+	Received Decimal    `json:"received"` // net of commission paid in the received asset
+}
+
+// ConvertResp Holding / HoldingAmount is where the funds ended, To after success
+type ConvertResp struct {
+	Route         *RouteQuote         `json:"route"`
+	Legs          []*ConvertLegResult `json:"legs"` // executed legs
+	Completed     bool                `json:"completed"`
+	FailedLeg     int                 `json:"failedLeg"` // index into Route.Legs, -1 when none failed
+	Holding       string              `json:"holding"`
+	HoldingAmount Decimal             `json:"holdingAmount"`
+}
+
+// legReceived amount of the leg's To asset credited by the order
+func legReceived(leg *RouteLeg, order *TradeResp) (spent, received Decimal, err error) {
+	executed, err := ParseDecimal(order.ExecutedQuantity)
+	if err != nil {
+		return Zero, Zero, fmt.Errorf("executedQty %w", err)
+	}
+	quote, err := ParseDecimal(order.CummulativeQuoteQuantity)
+	if err != nil {
+		return Zero, Zero, fmt.Errorf("cummulativeQuoteQty %w", err)
+	}
+	if leg.Side == binance.SideTypeBuy {
+		spent, received = quote, executed
+	} else {
+		spent, received = executed, quote
+	}
+	for _, fill := range order.Fills {
+		if fill.CommissionAsset != leg.To || fill.Commission == "" {
+			continue
+		}
+		commission, err := ParseDecimal(fill.Commission)
+		if err != nil {
+			return Zero, Zero, fmt.Errorf("commission %w", err)
+		}
+		received = received.Sub(commission)
+	}
+	return spent, received, nil
+}
+
+// orderFills the fills of an order from myTrades, for a recovered order whose response carries none
+func (c *SpotClient) orderFills(ctx context.Context, symbol string, orderId int64) ([]*binance.Fill, error) {
+	trades, err := c.exchange.ListTrades(ctx, &ListTradesParams{Symbol: symbol, OrderId: orderId, Limit: 1000})
+	if err != nil {
+		return nil, err
+	}
+	fills := make([]*binance.Fill, 0, len(trades))
+	for _, trade := range trades {
+		fills = append(fills, &binance.Fill{
+			TradeID:         int(trade.ID),
+			Price:           trade.Price,
+			Quantity:        trade.Quantity,
+			Commission:      trade.Commission,
+			CommissionAsset: trade.CommissionAsset,
+		})
+	}
+	return fills, nil
+}
+
+// Convert execute the best route leg by leg. When a leg fails the partial ConvertResp is returned with the error
+func (c *SpotClient) Convert(ctx context.Context, req *ConvertReq) (*ConvertResp, error) {
+	routes, err := c.QuoteRoutes(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if routes.Best == nil {
+		return nil, errors.New(fmt.Sprintf("no executable route from %s to %s: %s", req.From, req.To, routes.Routes[0].Reason))
+	}
+	route := routes.Best
+	if req.MinReceived != "" {
+		minReceived, err := ParseDecimal(req.MinReceived)
+		if err != nil {
+			return nil, fmt.Errorf("minReceived %w", err)
+		}
+		if route.Received.LessThan(minReceived) {
+			return nil, errors.New(fmt.Sprintf("best route %s quotes %s %s, below minReceived %s",
+				strings.Join(route.Path, "->"), route.Received, route.Path[len(route.Path)-1], req.MinReceived))
+		}
+	}
+
+	resp := &ConvertResp{Route: route, FailedLeg: -1, Holding: route.Path[0], HoldingAmount: route.Amount}
+	for i, leg := range route.Legs {
+		order, err := c.Trade(ctx, &TradeReq{
+			Symbol:       leg.Symbol,
+			Side:         leg.Side,
+			Quantity:     resp.HoldingAmount.String(),
+			QuantityKind: legKind(leg.Side),
+		})
+		if err != nil {
+			resp.FailedLeg = i
+			return resp, fmt.Errorf("convert leg %d %s %s %w", i+1, leg.Side, leg.Symbol, err)
+		}
+		if order.Recovered && len(order.Fills) == 0 {
+			// the commission is only known from the fills
+			if order.Fills, err = c.orderFills(ctx, leg.Symbol, order.OrderID); err != nil {
+				resp.FailedLeg = i
+				return resp, fmt.Errorf("convert leg %d %s trades %w", i+1, leg.Symbol, err)
+			}
+		}
+		spent, received, err := legReceived(leg, order)
+		if err != nil {
+			resp.FailedLeg = i
+			return resp, fmt.Errorf("convert leg %d %s %w", i+1, leg.Symbol, err)
+		}
+		resp.Legs = append(resp.Legs, &ConvertLegResult{Leg: leg, Order: order, Spent: spent, Received: received})
+		resp.Holding, resp.HoldingAmount = leg.To, received
+	}
+	resp.Completed = true
+	return resp, nil
+}
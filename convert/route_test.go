@@ -0,0 +1,139 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+// newTestRouteExchange BUSD -> EOS directly through EOSBUSD or via BTC (BTCBUSD, EOSBTC), the BTC route is cheaper
+func newTestRouteExchange() *FakeExchange {
+	fake := newTestFakeExchange()
+	for _, s := range []struct{ symbol, base, quote, step, tick string }{
+		{"BTCBUSD", "BTC", "BUSD", "0.00001", "0.01"},
+		{"EOSBUSD", "EOS", "BUSD", "0.01", "0.001"},
+	} {
+		fake.AddSymbol(binance.Symbol{
+			Symbol: s.symbol, Status: "TRADING", BaseAsset: s.base, QuoteAsset: s.quote,
+			BaseAssetPrecision: 8, QuotePrecision: 8, QuoteAssetPrecision: 8,
+			OrderTypes: []string{"LIMIT", "MARKET"}, IsSpotTradingAllowed: true, Permissions: []string{"SPOT"},
+			Filters: []map[string]interface{}{
+				{"filterType": "PRICE_FILTER", "minPrice": s.tick, "maxPrice": "1000000", "tickSize": s.tick},
+				{"filterType": "LOT_SIZE", "minQty": s.step, "maxQty": "9000000", "stepSize": s.step},
+				{"filterType": "NOTIONAL", "minNotional": "10", "applyMinToMarket": true, "maxNotional": "9000000", "applyMaxToMarket": false, "avgPriceMins": float64(5)},
+			},
+		})
+	}
+	fake.SetBookTicker("BTCBUSD", "19990", "10", "20000", "10")
+	fake.SetAveragePrice("BTCBUSD", "19995")
+	fake.SetDepth("BTCBUSD", 1, []binance.Bid{{Price: "19990", Quantity: "10"}}, []binance.Ask{{Price: "20000", Quantity: "10"}})
+	fake.SetBookTicker("EOSBUSD", "1.39", "1000", "1.40", "1000")
+	fake.SetAveragePrice("EOSBUSD", "1.395")
+	fake.SetDepth("EOSBUSD", 1, []binance.Bid{{Price: "1.39", Quantity: "1000"}}, []binance.Ask{{Price: "1.40", Quantity: "1000"}})
+	fake.SetBalance("BTC", "0")
+	fake.SetBalance("EOS", "0")
+	fake.SetDepth("EOSBTC", 1, []binance.Bid{{Price: "0.0000639", Quantity: "100"}}, []binance.Ask{{Price: "0.000064", Quantity: "100"}})
+	return fake
+}
+
+type failingExchange struct {
+	Exchange
+	symbol string
+}
+
+func (e *failingExchange) CreateOrder(ctx context.Context, params *CreateOrderParams) (*binance.CreateOrderResponse, error) {
+	if params.Symbol == e.symbol {
+		return nil, &common.APIError{Code: -2010, Message: "Account has insufficient balance for requested action."}
+	}
+	return e.Exchange.CreateOrder(ctx, params)
+}
+
+func TestQuoteRoutes(t *testing.T) {
+	convey.Convey("TestQuoteRoutes", t, func(convCtx convey.C) {
+		cli := NewSpotClientWithExchange(newTestRouteExchange())
+		ctx := context.Background()
+
+		routes, err := cli.QuoteRoutes(ctx, &ConvertReq{From: "busd", To: "EOS", Amount: "100"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(routes.Routes), convey.ShouldEqual, 2)
+		convCtx.So(routes.Best.Path, convey.ShouldResemble, []string{"BUSD", "BTC", "EOS"})
+		convCtx.So(routes.Best.Legs[0].Side, convey.ShouldEqual, "BUY")
+		convCtx.So(routes.Best.Legs[1].Estimate.Quantity.String(), convey.ShouldEqual, "0.004995")
+		convCtx.So(routes.Best.Received.String(), convey.ShouldEqual, "77.96882812")
+		convCtx.So(routes.Routes[1].Path, convey.ShouldResemble, []string{"BUSD", "EOS"})
+		convCtx.So(routes.Routes[1].Received.String(), convey.ShouldEqual, "71.35714284")
+
+		routes, err = cli.QuoteRoutes(ctx, &ConvertReq{From: "BUSD", To: "EOS", Amount: "100", MaxLegs: 1})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(routes.Best.Path, convey.ShouldResemble, []string{"BUSD", "EOS"})
+		routes, err = cli.QuoteRoutes(ctx, &ConvertReq{From: "BUSD", To: "EOS", Amount: "100", Via: []string{"USDT"}})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(routes.Routes), convey.ShouldEqual, 1)
+
+		// 5 BUSD is below every first leg's minNotional
+		routes, err = cli.QuoteRoutes(ctx, &ConvertReq{From: "BUSD", To: "EOS", Amount: "5"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(routes.Best, convey.ShouldBeNil)
+		convCtx.So(routes.Routes[0].Reason, convey.ShouldContainSubstring, "NOTIONAL")
+
+		_, err = cli.QuoteRoutes(ctx, &ConvertReq{From: "BUSD", To: "DOGE", Amount: "100"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+		_, err = cli.QuoteRoutes(ctx, &ConvertReq{From: "BUSD", To: "BUSD", Amount: "100"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestConvert(t *testing.T) {
+	convey.Convey("TestConvert", t, func(convCtx convey.C) {
+		fake := newTestRouteExchange()
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+
+		_, err := cli.Convert(ctx, &ConvertReq{From: "BUSD", To: "EOS", Amount: "100", MinReceived: "80"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+		convCtx.So(fake.Balance("BUSD").String(), convey.ShouldEqual, "100")
+
+		resp, err := cli.Convert(ctx, &ConvertReq{From: "BUSD", To: "EOS", Amount: "100", MinReceived: "77"})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Completed, convey.ShouldBeTrue)
+		convCtx.So(resp.FailedLeg, convey.ShouldEqual, -1)
+		convCtx.So(len(resp.Legs), convey.ShouldEqual, 2)
+		convCtx.So(resp.Legs[0].Received.String(), convey.ShouldEqual, "0.005")
+		convCtx.So(resp.Legs[1].Spent.String(), convey.ShouldEqual, "0.005")
+		convCtx.So(resp.Holding, convey.ShouldEqual, "EOS")
+		convCtx.So(resp.HoldingAmount.String(), convey.ShouldEqual, "78.125")
+		convCtx.So(fake.Balance("EOS").String(), convey.ShouldEqual, "78.125")
+		convCtx.So(fake.Balance("BUSD").IsZero(), convey.ShouldBeTrue)
+
+		// the second leg fails, the funds stay in BTC
+		fake = newTestRouteExchange()
+		cli = NewSpotClientWithExchange(&failingExchange{Exchange: fake, symbol: "EOSBTC"})
+		resp, err = cli.Convert(ctx, &ConvertReq{From: "BUSD", To: "EOS", Amount: "100"})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Code, convey.ShouldEqual, -2010)
+		convCtx.So(resp.Completed, convey.ShouldBeFalse)
+		convCtx.So(resp.FailedLeg, convey.ShouldEqual, 1)
+		convCtx.So(resp.Holding, convey.ShouldEqual, "BTC")
+		convCtx.So(resp.HoldingAmount.String(), convey.ShouldEqual, "0.005")
+		convCtx.So(fake.Balance("BTC").String(), convey.ShouldEqual, "0.005")
+
+		// a leg recovered after a timeout has no fills in its response, the commission comes from its trades
+		fake = newTestRouteExchange()
+		fake.SetChargeFees(true)
+		fake.SetTradeFee("BTCBUSD", "0.001", "0.001")
+		flaky := &flakyExchange{Exchange: fake, creates: []flakyCall{{err: &common.APIError{Code: -1007, Message: "Timeout waiting for response from backend server. Send status unknown; execution status unknown."}, placed: true}}}
+		cli = NewSpotClientWithExchange(&failingExchange{Exchange: flaky, symbol: "EOSBTC"})
+		convCtx.So(cli.SetOrderRetry(OrderRetryConfig{RetryDelay: time.Millisecond, QueryDelay: time.Millisecond, RecvWindow: time.Millisecond}), convey.ShouldBeNil)
+		resp, err = cli.Convert(ctx, &ConvertReq{From: "BUSD", To: "EOS", Amount: "100"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+		convCtx.So(resp.Legs[0].Order.Recovered, convey.ShouldBeTrue)
+		convCtx.So(len(resp.Legs[0].Order.Fills), convey.ShouldEqual, 1)
+		convCtx.So(resp.Legs[0].Received.String(), convey.ShouldEqual, "0.004995")
+		convCtx.So(resp.HoldingAmount.String(), convey.ShouldEqual, fake.Balance("BTC").String())
+	})
+}
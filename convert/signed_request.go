@@ -0,0 +1,56 @@
+package convert
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pursonchen/go-binance/v2/common"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// callSigned send a signed (HMAC SHA256) request for endpoints go-binance does not cover,
+// params travel in the query string, timestamp honours client.TimeOffset like the sdk
+func (e *BinanceExchange) callSigned(ctx context.Context, method, endpoint string, params url.Values, result interface{}) error {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = append([]string(nil), values...)
+	}
+	query.Set("timestamp", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond)-e.client.TimeOffset, 10))
+	raw := query.Encode()
+	mac := hmac.New(sha256.New, []byte(e.client.SecretKey))
+	mac.Write([]byte(raw))
+	raw += "&signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequest(method, e.client.BaseURL+endpoint+"?"+raw, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-MBX-APIKEY", e.client.APIKey)
+
+	httpClient := e.client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		apiErr := new(common.APIError)
+		_ = json.Unmarshal(data, apiErr)
+		return apiErr
+	}
+	return json.Unmarshal(data, result)
+}
@@ -0,0 +1,143 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/copier"
+	"github.com/pursonchen/go-binance/v2"
+)
+
+/*
+条件单 (conditional orders):
+
+STOP_LOSS / TAKE_PROFIT              触发后以市价成交, 需要 quantity 和 stopPrice 或 trailingDelta
+STOP_LOSS_LIMIT / TAKE_PROFIT_LIMIT  触发后以 price 挂限价单, 另需 timeInForce
+
+止损单在价格向不利方向移动到 stopPrice 时触发, 止盈单在价格向有利方向移动到 stopPrice 时触发.
+trailingDelta 以 BIPS 为单位 (1 BIPS = 0.01%), 不传 stopPrice 时立即开始追踪.
+*/
+
+type ConditionalOrderReq struct {
+	Symbol           string                   `json:"symbol"`
+	Side             binance.SideType         `json:"side"`          // SELL protects a long position
+	Quantity         string                   `json:"quantity"`      // base asset amount
+	Price            string                   `json:"price"`         // limit price, empty places the market variant
+	StopPrice        string                   `json:"stopPrice"`     // stopPrice and / or trailingDelta is required
+	TrailingDelta    int64                    `json:"trailingDelta"` // BIPS
+	TimeInForce      binance.TimeInForceType  `json:"timeInForce"`   // limit variant only, default GTC
+	NewClientOrderId string                   `json:"newClientOrderId"`
+	NewOrderRespType binance.NewOrderRespType `json:"newOrderRespType"`
+	Rounding         RoundingMode             `json:"rounding"`      // quantity rounding, default DOWN
+	PriceRounding    RoundingMode             `json:"priceRounding"` // price and stopPrice rounding, default DOWN
+}
+
+// StopLoss place a STOP_LOSS order, or STOP_LOSS_LIMIT when req.Price is set
+func (c *SpotClient) StopLoss(ctx context.Context, req *ConditionalOrderReq) (*TradeResp, error) {
+	orderType := binance.OrderTypeStopLoss
+	if req.Price != "" {
+		orderType = binance.OrderTypeStopLossLimit
+	}
+	return c.conditionalOrder(ctx, orderType, req)
+}
+
+// TakeProfit place a TAKE_PROFIT order, or TAKE_PROFIT_LIMIT when req.Price is set
+func (c *SpotClient) TakeProfit(ctx context.Context, req *ConditionalOrderReq) (*TradeResp, error) {
+	orderType := binance.OrderTypeTakeProfit
+	if req.Price != "" {
+		orderType = binance.OrderTypeTakeProfitLimit
+	}
+	return c.conditionalOrder(ctx, orderType, req)
+}
+
+func (c *SpotClient) conditionalOrder(ctx context.Context, orderType binance.OrderType, req *ConditionalOrderReq) (*TradeResp, error) {
+	if req.StopPrice == "" && req.TrailingDelta == 0 {
+		return nil, errors.New("stopPrice or trailingDelta is required")
+	}
+	if req.TrailingDelta < 0 {
+		return nil, errors.New(fmt.Sprintf("trailingDelta %d must be positive", req.TrailingDelta))
+	}
+
+	symbol, err := c.registry.Symbol(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if err = symbol.checkTrading(); err != nil {
+		return nil, err
+	}
+	if len(symbol.OrderTypes) > 0 && !symbol.SupportsOrderType(orderType) {
+		return nil, errors.New(fmt.Sprintf("%s does not support %s orders", req.Symbol, orderType))
+	}
+
+	normalizer, err := symbol.Normalizer()
+	if err != nil {
+		return nil, err
+	}
+	if req.Rounding != "" {
+		normalizer.QuantityMode = req.Rounding
+	}
+	if req.PriceRounding != "" {
+		normalizer.PriceMode = req.PriceRounding
+	}
+
+	dQuantity, err := ParseDecimal(req.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("quantity %w", err)
+	}
+	quantity := normalizer.Quantity(dQuantity, orderType)
+
+	check := &OrderCheck{Side: req.Side, Type: orderType, Quantity: quantity.Value, TrailingDelta: req.TrailingDelta}
+	params := &CreateOrderParams{
+		Symbol:           req.Symbol,
+		Side:             req.Side,
+		Type:             orderType,
+		Quantity:         quantity.Value.String(),
+		TrailingDelta:    req.TrailingDelta,
+		NewClientOrderId: req.NewClientOrderId,
+		NewOrderRespType: req.NewOrderRespType,
+	}
+
+	if req.StopPrice != "" {
+		dStopPrice, err := ParseDecimal(req.StopPrice)
+		if err != nil {
+			return nil, fmt.Errorf("stopPrice %w", err)
+		}
+		check.StopPrice = normalizer.Price(dStopPrice).Value
+		params.StopPrice = check.StopPrice.String()
+	}
+	if req.Price != "" {
+		dPrice, err := ParseDecimal(req.Price)
+		if err != nil {
+			return nil, fmt.Errorf("price %w", err)
+		}
+		check.Price = normalizer.Price(dPrice).Value
+		params.Price = check.Price.String()
+		params.TimeInForce = req.TimeInForce
+		if params.TimeInForce == "" {
+			params.TimeInForce = binance.TimeInForceTypeGTC
+		}
+	}
+
+	// PERCENT_PRICE and PERCENT_PRICE_BY_SIDE compare against the average price
+	if check.AvgPrice, err = c.avgPrice(ctx, req.Symbol); err != nil {
+		return nil, err
+	}
+	if err = c.accountCheck(ctx, symbol, check); err != nil {
+		return nil, err
+	}
+	if violations := normalizer.Filters.Validate(check); len(violations) > 0 {
+		return nil, violations
+	}
+
+	placement, err := c.placeOrder(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp TradeResp
+	copier.Copy(&resp, placement.order)
+	resp.Dust = quantity.Dust.String()
+	resp.Attempts, resp.Recovered = placement.attempts, placement.recovered
+
+	return &resp, nil
+}
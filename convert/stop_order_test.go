@@ -0,0 +1,102 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestStopLossTakeProfit(t *testing.T) {
+	convey.Convey("TestStopLossTakeProfit", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+
+		_, err := cli.Trade(ctx, &TradeReq{Symbol: "LUNCBUSD", Side: "BUY", Quantity: "20.01"})
+		convCtx.So(err, convey.ShouldBeNil)
+
+		stop, err := cli.StopLoss(ctx, &ConditionalOrderReq{
+			Symbol: "LUNCBUSD", Side: "SELL", Quantity: "60000.5", StopPrice: "0.00019", Price: "0.0001890",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(stop.Type, convey.ShouldEqual, "STOP_LOSS_LIMIT")
+		convCtx.So(stop.Status, convey.ShouldEqual, "NEW")
+		convCtx.So(stop.TimeInForce, convey.ShouldEqual, "GTC")
+		convCtx.So(stop.Dust, convey.ShouldEqual, "0.5")
+
+		profit, err := cli.TakeProfit(ctx, &ConditionalOrderReq{
+			Symbol: "LUNCBUSD", Side: "SELL", Quantity: "40000", StopPrice: "0.00025", Price: "0.00025",
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(profit.Type, convey.ShouldEqual, "TAKE_PROFIT_LIMIT")
+
+		_, err = cli.StopLoss(ctx, &ConditionalOrderReq{
+			Symbol: "LUNCBUSD", Side: "SELL", Quantity: "60000", StopPrice: "0.00021", Price: "0.00021",
+		})
+		var apiErr *common.APIError
+		convCtx.So(errors.As(err, &apiErr), convey.ShouldBeTrue)
+		convCtx.So(apiErr.Message, convey.ShouldEqual, "Stop price would trigger immediately.")
+
+		_, err = cli.StopLoss(ctx, &ConditionalOrderReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "60000", Price: "0.00019"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		// LUNCBUSD only lists the limit variants
+		_, err = cli.StopLoss(ctx, &ConditionalOrderReq{Symbol: "LUNCBUSD", Side: "SELL", Quantity: "60000", StopPrice: "0.00019"})
+		convCtx.So(err, convey.ShouldNotBeNil)
+
+		// triggered, but the limit price is above the bid
+		fake.SetBookTicker("LUNCBUSD", "0.00018800", "1000000.00", "0.00018900", "1000000.00")
+		order, err := cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: stop.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.Status, convey.ShouldEqual, "NEW")
+
+		fake.SetBookTicker("LUNCBUSD", "0.00018900", "1000000.00", "0.00019000", "1000000.00")
+		order, err = cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: stop.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(fake.Balance("LUNC").String(), convey.ShouldEqual, "40000")
+
+		order, err = cli.GetOrder(ctx, &GetOrderReq{Symbol: "LUNCBUSD", OrderId: profit.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.Status, convey.ShouldEqual, "NEW")
+	})
+}
+
+func TestConditionalOrderFilters(t *testing.T) {
+	convey.Convey("TestConditionalOrderFilters", t, func(convCtx convey.C) {
+		fake := NewFakeExchange()
+		fake.AddSymbol(binance.Symbol{
+			Symbol: "BTCUSDT", Status: "TRADING", BaseAsset: "BTC", QuoteAsset: "USDT",
+			BaseAssetPrecision: 8, QuoteAssetPrecision: 8,
+			OrderTypes: []string{"LIMIT", "MARKET", "STOP_LOSS", "STOP_LOSS_LIMIT", "TAKE_PROFIT", "TAKE_PROFIT_LIMIT"},
+			Filters:    testFilters,
+		})
+		fake.SetBookTicker("BTCUSDT", "19999.99", "1", "20000.01", "1")
+		fake.SetAveragePrice("BTCUSDT", "20000")
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+
+		_, err := cli.StopLoss(ctx, &ConditionalOrderReq{Symbol: "BTCUSDT", Side: "SELL", Quantity: "0.001", StopPrice: "3000"})
+		var violations FilterViolations
+		convCtx.So(errors.As(err, &violations), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypePercentPriceBySide), convey.ShouldBeTrue)
+
+		_, err = cli.TakeProfit(ctx, &ConditionalOrderReq{Symbol: "BTCUSDT", Side: "SELL", Quantity: "0.001", TrailingDelta: 5})
+		convCtx.So(errors.As(err, &violations), convey.ShouldBeTrue)
+		convCtx.So(violations.Has(FilterTypeTrailingDelta), convey.ShouldBeTrue)
+
+		resp, err := cli.StopLoss(ctx, &ConditionalOrderReq{
+			Symbol: "BTCUSDT", Side: "SELL", Quantity: "0.001", StopPrice: "19000.005", TrailingDelta: 100,
+		})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Type, convey.ShouldEqual, "STOP_LOSS")
+		convCtx.So(resp.Status, convey.ShouldEqual, "NEW")
+
+		order, err := cli.GetOrder(ctx, &GetOrderReq{Symbol: "BTCUSDT", OrderId: resp.OrderID})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(order.StopPrice, convey.ShouldEqual, "19000")
+	})
+}
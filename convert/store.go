@@ -0,0 +1,554 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+本地 K 线库:
+
+目录结构 <dir>/<SYMBOL>/<interval>/, 每个分区一个 CSV 文件 (无表头, 列同 klineCSVHeader), 按开盘时间升序:
+1s 按天分区 (2022-10-01.csv), 1h 以下按月 (2022-10.csv), 其余按年 (2022.csv).
+ranges.json 记录已经从交易所下载过的区间, 交易所本来就没有数据的空档也算下载过, 续传时不会重复请求.
+新的 K 线都在分区最后一根之后时直接追加到文件末尾 (回填是按时间顺序的, 不用每页重写整个分区),
+和已有的 K 线重叠时才合并后先写临时文件再 rename. 追加到一半退出留下的不完整的最后一行在下次写入时截掉.
+1M 的目录名是 1mon, 避免在大小写不敏感的文件系统上和 1m 冲突.
+*/
+
+const klineRangesFile = "ranges.json"
+
+var klineCSVHeader = []string{
+	"openTime", "open", "high", "low", "close", "volume", "closeTime",
+	"quoteAssetVolume", "tradeNum", "takerBuyBaseAssetVolume", "takerBuyQuoteAssetVolume",
+}
+
+// KlineStore candles on disk by symbol and interval, safe for concurrent use within one process
+type KlineStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenKlineStore a store rooted at dir, created when missing
+func OpenKlineStore(dir string) (*KlineStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &KlineStore{dir: dir}, nil
+}
+
+// seriesDir directory of symbol@interval
+func (s *KlineStore) seriesDir(symbol, interval string) (string, error) {
+	if symbol == "" || strings.ContainsAny(symbol, `/\.`) {
+		return "", errors.New(fmt.Sprintf("invalid symbol %q", symbol))
+	}
+	if _, ok := klineIntervals[interval]; !ok {
+		return "", errors.New(fmt.Sprintf("invalid kline interval %q", interval))
+	}
+	name := interval
+	if interval == "1M" {
+		name = "1mon"
+	}
+	return filepath.Join(s.dir, strings.ToUpper(symbol), name), nil
+}
+
+// klinePartition file name, without extension, holding the candle opened at openTime
+func klinePartition(interval string, openTime int64) string {
+	t := time.Unix(0, openTime*int64(time.Millisecond)).UTC()
+	switch {
+	case interval == "1s":
+		return t.Format("2006-01-02")
+	case klineIntervals[interval] < time.Hour:
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006")
+	}
+}
+
+// nextKlineOpen open time of the candle after the one opened at openTime
+func nextKlineOpen(interval string, openTime int64) int64 {
+	if interval == "1M" {
+		t := time.Unix(0, openTime*int64(time.Millisecond)).UTC()
+		return t.AddDate(0, 1, 0).UnixNano() / int64(time.Millisecond)
+	}
+	return openTime + klineIntervals[interval].Milliseconds()
+}
+
+func klineRecord(k *Kline) []string {
+	return []string{
+		strconv.FormatInt(k.OpenTime, 10), k.Open.String(), k.High.String(), k.Low.String(), k.Close.String(), k.Volume.String(),
+		strconv.FormatInt(k.CloseTime, 10), k.QuoteAssetVolume.String(), strconv.FormatInt(k.TradeNum, 10),
+		k.TakerBuyBaseAssetVolume.String(), k.TakerBuyQuoteAssetVolume.String(),
+	}
+}
+
+func parseKlineRecord(record []string) (*Kline, error) {
+	if len(record) != len(klineCSVHeader) {
+		return nil, errors.New(fmt.Sprintf("kline record has %d fields, want %d", len(record), len(klineCSVHeader)))
+	}
+	k := &Kline{}
+	ints := []struct {
+		index int
+		dst   *int64
+	}{{0, &k.OpenTime}, {6, &k.CloseTime}, {8, &k.TradeNum}}
+	for _, field := range ints {
+		v, err := strconv.ParseInt(record[field.index], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("kline %s %w", klineCSVHeader[field.index], err)
+		}
+		*field.dst = v
+	}
+	decimals := []struct {
+		index int
+		dst   *Decimal
+	}{{1, &k.Open}, {2, &k.High}, {3, &k.Low}, {4, &k.Close}, {5, &k.Volume}, {7, &k.QuoteAssetVolume}, {9, &k.TakerBuyBaseAssetVolume}, {10, &k.TakerBuyQuoteAssetVolume}}
+	for _, field := range decimals {
+		d, err := ParseDecimal(record[field.index])
+		if err != nil {
+			return nil, fmt.Errorf("kline %d %s %w", k.OpenTime, klineCSVHeader[field.index], err)
+		}
+		*field.dst = d
+	}
+	return k, nil
+}
+
+// readKlineFile candles of a partition, none when the file does not exist
+func readKlineFile(path string) ([]*Kline, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(klineCSVHeader)
+	r.ReuseRecord = true
+	var klines []*Kline
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return klines, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", path, err)
+		}
+		k, err := parseKlineRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", path, err)
+		}
+		klines = append(klines, k)
+	}
+}
+
+// writeFileAtomic replace path with the output of write
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func writeKlineFile(path string, klines []*Kline) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		for _, k := range klines {
+			if err := cw.Write(klineRecord(k)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+}
+
+// klineFileTail open time of the last complete row of a partition and the file size up to that row,
+// zero size when the file is missing or has no complete row
+func klineFileTail(path string) (lastOpen int64, size int64, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	// a row is about 150 bytes
+	offset := info.Size() - 4096
+	if offset < 0 {
+		offset = 0
+	}
+	chunk := make([]byte, info.Size()-offset)
+	if _, err = f.ReadAt(chunk, offset); err != nil {
+		return 0, 0, err
+	}
+	end := bytes.LastIndexByte(chunk, '\n')
+	if end < 0 {
+		if offset > 0 {
+			return 0, 0, errors.New(fmt.Sprintf("%s last row longer than %d bytes", path, len(chunk)))
+		}
+		return 0, 0, nil
+	}
+	begin := bytes.LastIndexByte(chunk[:end], '\n') + 1
+	if begin == 0 && offset > 0 {
+		return 0, 0, errors.New(fmt.Sprintf("%s last row longer than %d bytes", path, len(chunk)))
+	}
+	record, err := csv.NewReader(bytes.NewReader(chunk[begin:end])).Read()
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s %w", path, err)
+	}
+	k, err := parseKlineRecord(record)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s %w", path, err)
+	}
+	return k.OpenTime, offset + int64(end) + 1, nil
+}
+
+// appendKlineFile add klines after the first size bytes of path, dropping anything beyond them
+func appendKlineFile(path string, size int64, klines []*Kline) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err = f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err = f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	cw := csv.NewWriter(f)
+	for _, k := range klines {
+		if err = cw.Write(klineRecord(k)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// mergeKlines stored and klines by open time, klines replace stored candles with the same open time
+func mergeKlines(stored, klines []*Kline) []*Kline {
+	byOpen := make(map[int64]*Kline, len(stored)+len(klines))
+	for _, k := range stored {
+		byOpen[k.OpenTime] = k
+	}
+	for _, k := range klines {
+		byOpen[k.OpenTime] = k
+	}
+	merged := make([]*Kline, 0, len(byOpen))
+	for _, k := range byOpen {
+		merged = append(merged, k)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].OpenTime < merged[j].OpenTime
+	})
+	return merged
+}
+
+// Append store klines, a candle already stored with the same open time is replaced.
+// Candles after the last stored one are appended to their partition, overlapping ones rewrite it
+func (s *KlineStore) Append(symbol, interval string, klines []*Kline) error {
+	dir, err := s.seriesDir(symbol, interval)
+	if err != nil {
+		return err
+	}
+	if len(klines) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	partitions := map[string][]*Kline{}
+	var names []string
+	for _, k := range klines {
+		name := klinePartition(interval, k.OpenTime)
+		if _, ok := partitions[name]; !ok {
+			names = append(names, name)
+		}
+		partitions[name] = append(partitions[name], k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name+".csv")
+		added := mergeKlines(nil, partitions[name])
+		lastOpen, size, err := klineFileTail(path)
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			if err = writeKlineFile(path, added); err != nil {
+				return err
+			}
+			continue
+		}
+		if added[0].OpenTime > lastOpen {
+			if err = appendKlineFile(path, size, added); err != nil {
+				return err
+			}
+			continue
+		}
+		// an interrupted append may have left half a row
+		if err = os.Truncate(path, size); err != nil {
+			return err
+		}
+		stored, err := readKlineFile(path)
+		if err != nil {
+			return err
+		}
+		if err = writeKlineFile(path, mergeKlines(stored, added)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ranges open time ranges already downloaded for symbol@interval, merged
+func (s *KlineStore) Ranges(symbol, interval string) ([]KlineRange, error) {
+	dir, err := s.seriesDir(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return readKlineRanges(dir)
+}
+
+func readKlineRanges(dir string) ([]KlineRange, error) {
+	data, err := os.ReadFile(filepath.Join(dir, klineRangesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ranges []KlineRange
+	if err = json.Unmarshal(data, &ranges); err != nil {
+		return nil, fmt.Errorf("%s %w", klineRangesFile, err)
+	}
+	return MergeKlineRanges(ranges), nil
+}
+
+// AddRanges record ranges of symbol@interval as downloaded
+func (s *KlineStore) AddRanges(symbol, interval string, ranges ...KlineRange) error {
+	dir, err := s.seriesDir(symbol, interval)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, err := readKlineRanges(dir)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(MergeKlineRanges(append(stored, ranges...)))
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, klineRangesFile), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Scan call fn with every stored candle of symbol@interval opened in [start, end], oldest first.
+// A zero start or end leaves that side open
+func (s *KlineStore) Scan(symbol, interval string, start, end int64, fn func(k *Kline) error) error {
+	dir, err := s.seriesDir(symbol, interval)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	entries, err := os.ReadDir(dir)
+	s.mu.Unlock()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// partition names sort in time order
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".csv") {
+			continue
+		}
+		name = strings.TrimSuffix(name, ".csv")
+		if (start > 0 && name < klinePartition(interval, start)) || (end > 0 && name > klinePartition(interval, end)) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.mu.Lock()
+		klines, err := readKlineFile(filepath.Join(dir, name+".csv"))
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		i := sort.Search(len(klines), func(i int) bool {
+			return klines[i].OpenTime >= start
+		})
+		for ; i < len(klines); i++ {
+			if end > 0 && klines[i].OpenTime > end {
+				return nil
+			}
+			if err = fn(klines[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Read stored candles of symbol@interval opened in [start, end], see Scan
+func (s *KlineStore) Read(symbol, interval string, start, end int64) ([]*Kline, error) {
+	var klines []*Kline
+	err := s.Scan(symbol, interval, start, end, func(k *Kline) error {
+		klines = append(klines, k)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return klines, nil
+}
+
+// KlineCheck integrity of the stored candles in a range
+type KlineCheck struct {
+	Klines    int          `json:"klines"`
+	First     int64        `json:"first"`     // open time of the first candle
+	Last      int64        `json:"last"`      // open time of the last candle
+	Missing   []KlineRange `json:"missing"`   // gaps between stored candles
+	Invalid   []int64      `json:"invalid"`   // open times of candles with inconsistent prices or times
+	Unfetched []KlineRange `json:"unfetched"` // parts of [start, end] never downloaded, only with both set
+}
+
+// Check look for missing and inconsistent candles of symbol@interval in [start, end].
+// Missing ranges inside downloaded ranges are gaps on the exchange itself, e.g. maintenance
+func (s *KlineStore) Check(symbol, interval string, start, end int64) (*KlineCheck, error) {
+	resp := &KlineCheck{}
+	var prev *Kline
+	err := s.Scan(symbol, interval, start, end, func(k *Kline) error {
+		next := nextKlineOpen(interval, k.OpenTime)
+		if k.Low.GreaterThan(k.High) || k.Open.LessThan(k.Low) || k.Open.GreaterThan(k.High) ||
+			k.Close.LessThan(k.Low) || k.Close.GreaterThan(k.High) || k.CloseTime != next-1 {
+			resp.Invalid = append(resp.Invalid, k.OpenTime)
+		}
+		if prev == nil {
+			resp.First = k.OpenTime
+		} else if expected := nextKlineOpen(interval, prev.OpenTime); k.OpenTime > expected {
+			resp.Missing = append(resp.Missing, KlineRange{Start: expected, End: k.OpenTime - 1})
+		}
+		resp.Last = k.OpenTime
+		resp.Klines++
+		prev = k
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if start > 0 && end > 0 {
+		ranges, err := s.Ranges(symbol, interval)
+		if err != nil {
+			return nil, err
+		}
+		resp.Unfetched = missingKlineRanges(KlineRange{Start: start, End: end}, ranges)
+	}
+	return resp, nil
+}
+
+// ExportCSV write the candles of symbol@interval in [start, end] as CSV with a header row
+func (s *KlineStore) ExportCSV(w io.Writer, symbol, interval string, start, end int64) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(klineCSVHeader); err != nil {
+		return err
+	}
+	err := s.Scan(symbol, interval, start, end, func(k *Kline) error {
+		return cw.Write(klineRecord(k))
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportJSONL write the candles of symbol@interval in [start, end] as JSON Lines
+func (s *KlineStore) ExportJSONL(w io.Writer, symbol, interval string, start, end int64) error {
+	enc := json.NewEncoder(w)
+	return s.Scan(symbol, interval, start, end, func(k *Kline) error {
+		return enc.Encode(k)
+	})
+}
+
+// BackfillKlineStore backfill req into store, skipping ranges the store already downloaded.
+// Ranges are recorded after every page, so an interrupted backfill resumes where it stopped
+func (c *SpotClient) BackfillKlineStore(ctx context.Context, store *KlineStore, req *BackfillKlinesReq) (*BackfillKlinesResp, error) {
+	have, err := store.Ranges(req.Symbol, req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	backfill := *req
+	backfill.Have = append(have, req.Have...)
+	resp, err := c.BackfillKlines(ctx, &backfill, func(klines []*Kline) error {
+		if err := store.Append(req.Symbol, req.Interval, klines); err != nil {
+			return err
+		}
+		return store.AddRanges(req.Symbol, req.Interval, KlineRange{Start: klines[0].OpenTime, End: klines[len(klines)-1].OpenTime})
+	})
+	if resp != nil {
+		// also the empty stretches, which the pages above do not cover
+		if rangesErr := store.AddRanges(req.Symbol, req.Interval, resp.Ranges...); rangesErr != nil && err == nil {
+			err = rangesErr
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,147 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKlineStore(t *testing.T) {
+	convey.Convey("TestKlineStore", t, func(convCtx convey.C) {
+		store, err := OpenKlineStore(t.TempDir())
+		convCtx.So(err, convey.ShouldBeNil)
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+		minute := int64(time.Minute / time.Millisecond)
+		// crosses into the next monthly partition
+		start := time.Date(2022, 10, 31, 23, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+		addTestKlines(fake, "EOSBTC", start, 120)
+		resp, err := cli.Klines(ctx, &KlinesReq{Symbol: "EOSBTC", Interval: "1m", StartTime: start, Limit: 120})
+		convCtx.So(err, convey.ShouldBeNil)
+
+		// appended out of order and twice
+		convCtx.So(store.Append("EOSBTC", "1m", resp.Klines[60:]), convey.ShouldBeNil)
+		convCtx.So(store.Append("EOSBTC", "1m", resp.Klines[:70]), convey.ShouldBeNil)
+		_, err = os.Stat(filepath.Join(store.dir, "EOSBTC", "1m", "2022-11.csv"))
+		convCtx.So(err, convey.ShouldBeNil)
+		klines, err := store.Read("EOSBTC", "1m", 0, 0)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(klines), convey.ShouldEqual, 120)
+		for i, k := range klines {
+			convCtx.So(k.OpenTime, convey.ShouldEqual, start+int64(i)*minute)
+		}
+		convCtx.So(klineRecord(klines[119]), convey.ShouldResemble, klineRecord(resp.Klines[119]))
+
+		klines, err = store.Read("EOSBTC", "1m", start+58*minute, start+61*minute+1)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(klines), convey.ShouldEqual, 4)
+		convCtx.So(klines[0].Close.String(), convey.ShouldEqual, "58.5")
+		convCtx.So(klines[3].Close.String(), convey.ShouldEqual, "61.5")
+
+		check, err := store.Check("EOSBTC", "1m", start, start+119*minute)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(check.Klines, convey.ShouldEqual, 120)
+		convCtx.So(check.Missing, convey.ShouldBeNil)
+		convCtx.So(check.Invalid, convey.ShouldBeNil)
+		convCtx.So(check.Unfetched, convey.ShouldResemble, []KlineRange{{Start: start, End: start + 119*minute}})
+
+		// a hole and a candle closing below its low
+		convCtx.So(writeKlineFile(filepath.Join(store.dir, "EOSBTC", "1m", "2022-10.csv"), append(resp.Klines[:10:10], resp.Klines[13:60]...)), convey.ShouldBeNil)
+		bad := *resp.Klines[100]
+		bad.Close = MustDecimal("1")
+		convCtx.So(store.Append("EOSBTC", "1m", []*Kline{&bad}), convey.ShouldBeNil)
+		check, err = store.Check("EOSBTC", "1m", 0, 0)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(check.Klines, convey.ShouldEqual, 117)
+		convCtx.So(check.First, convey.ShouldEqual, start)
+		convCtx.So(check.Last, convey.ShouldEqual, start+119*minute)
+		convCtx.So(check.Missing, convey.ShouldResemble, []KlineRange{{Start: start + 10*minute, End: start + 13*minute - 1}})
+		convCtx.So(check.Invalid, convey.ShouldResemble, []int64{bad.OpenTime})
+
+		var csvOut, jsonOut bytes.Buffer
+		convCtx.So(store.ExportCSV(&csvOut, "EOSBTC", "1m", start, start+minute), convey.ShouldBeNil)
+		lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+		convCtx.So(len(lines), convey.ShouldEqual, 3)
+		convCtx.So(lines[0], convey.ShouldStartWith, "openTime,open,high,low,close")
+		convCtx.So(store.ExportJSONL(&jsonOut, "EOSBTC", "1m", start+119*minute, 0), convey.ShouldBeNil)
+		var exported Kline
+		convCtx.So(json.Unmarshal(jsonOut.Bytes(), &exported), convey.ShouldBeNil)
+		convCtx.So(klineRecord(&exported), convey.ShouldResemble, klineRecord(resp.Klines[119]))
+
+		// later candles are appended in place, half a row left by an interrupted append is dropped
+		path := filepath.Join(store.dir, "EOSBTC", "1m", "2022-11.csv")
+		before, err := os.Stat(path)
+		convCtx.So(err, convey.ShouldBeNil)
+		addTestKlines(fake, "EOSBTC", start+120*minute, 10)
+		more, err := cli.Klines(ctx, &KlinesReq{Symbol: "EOSBTC", Interval: "1m", StartTime: start + 120*minute, Limit: 10})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(store.Append("EOSBTC", "1m", more.Klines[:5]), convey.ShouldBeNil)
+		after, err := os.Stat(path)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(os.SameFile(before, after), convey.ShouldBeTrue)
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		convCtx.So(err, convey.ShouldBeNil)
+		_, err = f.WriteString("1667261100000,1.5,2")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(f.Close(), convey.ShouldBeNil)
+		convCtx.So(store.Append("EOSBTC", "1m", more.Klines[5:]), convey.ShouldBeNil)
+		klines, err = store.Read("EOSBTC", "1m", start+60*minute, 0)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(len(klines), convey.ShouldEqual, 70)
+		convCtx.So(klineRecord(klines[69]), convey.ShouldResemble, klineRecord(more.Klines[9]))
+
+		convCtx.So(store.Append("../EOSBTC", "1m", resp.Klines), convey.ShouldNotBeNil)
+		convCtx.So(store.Append("EOSBTC", "2m", resp.Klines), convey.ShouldNotBeNil)
+		klines, err = store.Read("LUNCBUSD", "1m", 0, 0)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(klines, convey.ShouldBeNil)
+	})
+}
+
+func TestBackfillKlineStore(t *testing.T) {
+	convey.Convey("TestBackfillKlineStore", t, func(convCtx convey.C) {
+		store, err := OpenKlineStore(t.TempDir())
+		convCtx.So(err, convey.ShouldBeNil)
+		fake := newTestFakeExchange()
+		cli := NewSpotClientWithExchange(fake)
+		ctx := context.Background()
+		minute := int64(time.Minute / time.Millisecond)
+		start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+		addTestKlines(fake, "EOSBTC", start, 2000)
+		// maintenance: no candles for an hour
+		addTestKlines(fake, "EOSBTC", start+2060*minute, 500)
+		end := start + 2559*minute
+
+		resp, err := cli.BackfillKlineStore(ctx, store, &BackfillKlinesReq{Symbol: "EOSBTC", Interval: "1m", StartTime: start, EndTime: start + 1499*minute})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Requests, convey.ShouldEqual, 2)
+
+		// only the rest is downloaded
+		resp, err = cli.BackfillKlineStore(ctx, store, &BackfillKlinesReq{Symbol: "EOSBTC", Interval: "1m", StartTime: start, EndTime: end})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Requests, convey.ShouldEqual, 1)
+		convCtx.So(resp.Klines, convey.ShouldEqual, 1000)
+		resp, err = cli.BackfillKlineStore(ctx, store, &BackfillKlinesReq{Symbol: "EOSBTC", Interval: "1m", StartTime: start, EndTime: end})
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(resp.Requests, convey.ShouldEqual, 0)
+
+		ranges, err := store.Ranges("EOSBTC", "1m")
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(ranges, convey.ShouldResemble, []KlineRange{{Start: start, End: end}})
+		check, err := store.Check("EOSBTC", "1m", start, end)
+		convCtx.So(err, convey.ShouldBeNil)
+		convCtx.So(check.Klines, convey.ShouldEqual, 2500)
+		convCtx.So(check.Missing, convey.ShouldResemble, []KlineRange{{Start: start + 2000*minute, End: start + 2060*minute - 1}})
+		convCtx.So(check.Unfetched, convey.ShouldBeNil)
+
+		_, err = cli.BackfillKlineStore(ctx, store, &BackfillKlinesReq{Symbol: "EOSBTC", Interval: "7m", StartTime: start})
+		convCtx.So(err, convey.ShouldNotBeNil)
+	})
+}
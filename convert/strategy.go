@@ -0,0 +1,69 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"github.com/pursonchen/go-binance/v2"
+	"strings"
+)
+
+// Strategy trading logic run unchanged live (RunStrategy) and against history (Backtest):
+// it trades through cli, a real SpotClient live and one on a simulated exchange in a backtest
+type Strategy interface {
+	// OnKline called once per closed candle of symbol
+	OnKline(ctx context.Context, cli *SpotClient, symbol string, kline *Kline) error
+}
+
+// StrategyFunc a function as a Strategy
+type StrategyFunc func(ctx context.Context, cli *SpotClient, symbol string, kline *Kline) error
+
+func (f StrategyFunc) OnKline(ctx context.Context, cli *SpotClient, symbol string, kline *Kline) error {
+	return f(ctx, cli, symbol, kline)
+}
+
+// NewWsKline typed candle of a kline stream event
+func NewWsKline(k *binance.WsKline) (*Kline, error) {
+	return NewKline(&binance.Kline{
+		OpenTime:                 k.StartTime,
+		Open:                     k.Open,
+		High:                     k.High,
+		Low:                      k.Low,
+		Close:                    k.Close,
+		Volume:                   k.Volume,
+		CloseTime:                k.EndTime,
+		QuoteAssetVolume:         k.QuoteVolume,
+		TradeNum:                 k.TradeNum,
+		TakerBuyBaseAssetVolume:  k.ActiveBuyVolume,
+		TakerBuyQuoteAssetVolume: k.ActiveBuyQuoteVolume,
+	})
+}
+
+// RunStrategy hand every closed candle of symbol@interval on stream to strategy, trading through cli.
+// It returns when ctx is done, the stream closes or the strategy fails
+func RunStrategy(ctx context.Context, cli *SpotClient, stream *MarketStream, symbol, interval string, strategy Strategy) error {
+	events, err := stream.Kline(symbol, interval)
+	if err != nil {
+		return err
+	}
+	defer stream.Unsubscribe(strings.ToLower(symbol) + "@kline_" + interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-events:
+			if !ok {
+				return ErrStreamClosed
+			}
+			if !event.Kline.IsFinal {
+				continue
+			}
+			kline, err := NewWsKline(&event.Kline)
+			if err != nil {
+				return err
+			}
+			if err = strategy.OnKline(ctx, cli, symbol, kline); err != nil {
+				return fmt.Errorf("kline %d %w", kline.OpenTime, err)
+			}
+		}
+	}
+}
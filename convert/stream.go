@@ -0,0 +1,616 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/pursonchen/go-binance/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+行情 websocket 订阅:
+
+所有订阅共用一个 combined stream 连接 (/stream?streams=a/b/c), 连接建立后新增的订阅通过 SUBSCRIBE 发送.
+断线 (包括 binance 24 小时强制断开, StaleAfter 内没有消息) 后按指数退避重连, 并用全部订阅重新建立连接.
+丢失的数据通过 Gaps() 报告:
+RECONNECT: 断线期间每个订阅都可能丢消息
+SEQUENCE:  trade / aggTrade id 或 depth update id 不连续, kline 开盘时间跳过了整根
+DROPPED:   消费方读得太慢, channel 满了丢弃的事件
+*/
+
+const (
+	StreamBaseURL        = "wss://stream.binance.com:9443"
+	StreamTestnetBaseURL = "wss://testnet.binance.vision"
+)
+
+var ErrStreamClosed = errors.New("market stream closed")
+
+type GapReason string
+
+const (
+	GapReconnect GapReason = "RECONNECT"
+	GapSequence  GapReason = "SEQUENCE"
+	GapDropped   GapReason = "DROPPED"
+)
+
+// StreamGap a range of events a subscriber did not receive
+type StreamGap struct {
+	Stream string    `json:"stream"`
+	Reason GapReason `json:"reason"`
+	// SEQUENCE: missing trade / aggTrade / depth update ids or kline open times, both inclusive
+	FromSeq int64 `json:"fromSeq"`
+	ToSeq   int64 `json:"toSeq"`
+	// RECONNECT: last message before the disconnect and the moment the stream was resubscribed
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+type StreamConfig struct {
+	BaseURL      string        // default StreamBaseURL, StreamTestnetBaseURL when binance.UseTestnet
+	BufferSize   int           // per subscription channel, default 256
+	ReconnectMin time.Duration // default 1s
+	ReconnectMax time.Duration // default 30s
+	StaleAfter   time.Duration // reconnect when nothing arrives for this long, default 3m, negative disables
+	Dialer       *websocket.Dialer
+}
+
+func (cfg StreamConfig) withDefaults() StreamConfig {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = StreamBaseURL
+		if binance.UseTestnet {
+			cfg.BaseURL = StreamTestnetBaseURL
+		}
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 256
+	}
+	if cfg.ReconnectMin <= 0 {
+		cfg.ReconnectMin = time.Second
+	}
+	if cfg.ReconnectMax < cfg.ReconnectMin {
+		cfg.ReconnectMax = 30 * time.Second
+		if cfg.ReconnectMax < cfg.ReconnectMin {
+			cfg.ReconnectMax = cfg.ReconnectMin
+		}
+	}
+	if cfg.StaleAfter == 0 {
+		cfg.StaleAfter = 3 * time.Minute
+	}
+	if cfg.Dialer == nil {
+		cfg.Dialer = websocket.DefaultDialer
+	}
+	return cfg
+}
+
+// backoff exponential reconnect delay, reset after a successful connect
+type backoff struct {
+	min, max, next time.Duration
+}
+
+func (b *backoff) Next() time.Duration {
+	if b.next < b.min {
+		b.next = b.min
+	}
+	d := b.next
+	b.next *= 2
+	if b.next > b.max {
+		b.next = b.max
+	}
+	return d
+}
+
+func (b *backoff) Reset() {
+	b.next = b.min
+}
+
+type subscription struct {
+	stream string
+	// decode unmarshal data for the next send and return the sequence numbers it covers, 0 when the stream has none
+	decode func(data json.RawMessage) (first, last int64, err error)
+	send   func() bool
+	close  func()
+	// distance between consecutive sequence numbers, 0 disables SEQUENCE gaps.
+	// kline updates repeat the open time, only a new one is checked
+	step    int64
+	lastSeq int64
+	lastAt  time.Time
+}
+
+// MarketStream typed market data subscriptions over one auto reconnecting websocket
+type MarketStream struct {
+	cfg StreamConfig
+
+	mu      sync.Mutex
+	subs    map[string]*subscription
+	conn    *websocket.Conn
+	started bool
+	closed  bool
+	nextId  int64
+
+	writeMu sync.Mutex
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	gaps    chan *StreamGap
+	errs    chan error
+}
+
+func NewMarketStream(cfg *StreamConfig) *MarketStream {
+	var c StreamConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	c = c.withDefaults()
+	return &MarketStream{
+		cfg:  c,
+		subs: make(map[string]*subscription),
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		gaps: make(chan *StreamGap, c.BufferSize),
+		errs: make(chan error, c.BufferSize),
+	}
+}
+
+// Gaps missed events of every subscription, never blocks the stream, closed by Close
+func (s *MarketStream) Gaps() <-chan *StreamGap {
+	return s.gaps
+}
+
+// Errors connection and decode errors, the stream keeps reconnecting after them, closed by Close
+func (s *MarketStream) Errors() <-chan error {
+	return s.errs
+}
+
+func (s *MarketStream) reportGap(gap *StreamGap) {
+	select {
+	case s.gaps <- gap:
+	default:
+	}
+}
+
+func (s *MarketStream) reportError(err error) {
+	select {
+	case s.errs <- err:
+	default:
+	}
+}
+
+// subscribeStream subscribe stream and deliver what decode makes of each payload on a channel of its own.
+// decode also returns the sequence numbers the event covers, 0 when the stream has none; step is that of subscription
+func subscribeStream[T any](s *MarketStream, stream string, step int64, decode func(data json.RawMessage) (event *T, first, last int64, err error)) (<-chan *T, error) {
+	ch := make(chan *T, s.cfg.BufferSize)
+	var event *T
+	sub := &subscription{
+		stream: stream,
+		step:   step,
+		decode: func(data json.RawMessage) (first, last int64, err error) {
+			event, first, last, err = decode(data)
+			return first, last, err
+		},
+		send: func() bool {
+			select {
+			case ch <- event:
+				return true
+			default:
+				return false
+			}
+		},
+		close: func() { close(ch) },
+	}
+	if err := s.subscribe(sub); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// jsonEvent decode the payload as a T, seq (nil for streams without one) gives its sequence number
+func jsonEvent[T any](seq func(event *T) int64) func(data json.RawMessage) (*T, int64, int64, error) {
+	return func(data json.RawMessage) (*T, int64, int64, error) {
+		event := new(T)
+		if err := json.Unmarshal(data, event); err != nil {
+			return nil, 0, 0, err
+		}
+		if seq == nil {
+			return event, 0, 0, nil
+		}
+		n := seq(event)
+		return event, n, n, nil
+	}
+}
+
+// BookTicker best bid / ask updates of a symbol
+func (s *MarketStream) BookTicker(symbol string) (<-chan *binance.WsBookTickerEvent, error) {
+	return subscribeStream(s, strings.ToLower(symbol)+"@bookTicker", 0, jsonEvent[binance.WsBookTickerEvent](nil))
+}
+
+// Trade raw trades of a symbol, SEQUENCE gaps on trade id
+func (s *MarketStream) Trade(symbol string) (<-chan *binance.WsTradeEvent, error) {
+	return subscribeStream(s, strings.ToLower(symbol)+"@trade", 1, jsonEvent(func(event *binance.WsTradeEvent) int64 {
+		return event.TradeID
+	}))
+}
+
+// AggTrade aggregate trades of a symbol, SEQUENCE gaps on aggregate trade id
+func (s *MarketStream) AggTrade(symbol string) (<-chan *binance.WsAggTradeEvent, error) {
+	return subscribeStream(s, strings.ToLower(symbol)+"@aggTrade", 1, jsonEvent(func(event *binance.WsAggTradeEvent) int64 {
+		return event.AggTradeID
+	}))
+}
+
+// Kline candle updates of a symbol, SEQUENCE gaps on open time for fixed intervals (not 1M)
+func (s *MarketStream) Kline(symbol, interval string) (<-chan *binance.WsKlineEvent, error) {
+	if interval == "" {
+		return nil, errors.New("interval is required")
+	}
+	return subscribeStream(s, strings.ToLower(symbol)+"@kline_"+interval, intervalMillis(interval), jsonEvent(func(event *binance.WsKlineEvent) int64 {
+		return event.Kline.StartTime
+	}))
+}
+
+// MiniTicker rolling 24hr statistics of a symbol, pushed every second
+func (s *MarketStream) MiniTicker(symbol string) (<-chan *binance.WsMiniMarketsStatEvent, error) {
+	return subscribeStream(s, strings.ToLower(symbol)+"@miniTicker", 0, jsonEvent[binance.WsMiniMarketsStatEvent](nil))
+}
+
+// depthEvent diff depth payload, levels are ["price", "quantity"] pairs
+type depthEvent struct {
+	Event         string      `json:"e"`
+	Time          int64       `json:"E"`
+	Symbol        string      `json:"s"`
+	FirstUpdateID int64       `json:"U"`
+	LastUpdateID  int64       `json:"u"`
+	Bids          [][2]string `json:"b"`
+	Asks          [][2]string `json:"a"`
+}
+
+func priceLevels(raw [][2]string) []binance.Bid {
+	levels := make([]binance.Bid, 0, len(raw))
+	for _, level := range raw {
+		levels = append(levels, binance.Bid{Price: level[0], Quantity: level[1]})
+	}
+	return levels
+}
+
+// Depth diff depth updates of a symbol, speed "" (1000ms) or "100ms", SEQUENCE gaps on update id.
+// Feed the channel to OrderBook.Run for a local book
+func (s *MarketStream) Depth(symbol, speed string) (<-chan *binance.WsDepthEvent, error) {
+	stream := strings.ToLower(symbol) + "@depth"
+	if speed != "" {
+		stream += "@" + speed
+	}
+	return subscribeStream(s, stream, 1, func(data json.RawMessage) (*binance.WsDepthEvent, int64, int64, error) {
+		var raw depthEvent
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, 0, 0, err
+		}
+		event := &binance.WsDepthEvent{
+			Event:         raw.Event,
+			Time:          raw.Time,
+			Symbol:        raw.Symbol,
+			FirstUpdateID: raw.FirstUpdateID,
+			LastUpdateID:  raw.LastUpdateID,
+			Bids:          priceLevels(raw.Bids),
+			Asks:          priceLevels(raw.Asks),
+		}
+		return event, raw.FirstUpdateID, raw.LastUpdateID, nil
+	})
+}
+
+var intervalUnits = map[byte]int64{
+	's': int64(time.Second / time.Millisecond),
+	'm': int64(time.Minute / time.Millisecond),
+	'h': int64(time.Hour / time.Millisecond),
+	'd': 24 * int64(time.Hour/time.Millisecond),
+	'w': 7 * 24 * int64(time.Hour/time.Millisecond),
+}
+
+// intervalMillis kline interval length, 0 for 1M and anything unknown
+func intervalMillis(interval string) int64 {
+	if len(interval) < 2 {
+		return 0
+	}
+	unit, ok := intervalUnits[interval[len(interval)-1]]
+	if !ok {
+		return 0
+	}
+	var n int64
+	if _, err := fmt.Sscanf(interval[:len(interval)-1], "%d", &n); err != nil || n <= 0 {
+		return 0
+	}
+	return n * unit
+}
+
+func (s *MarketStream) subscribe(sub *subscription) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrStreamClosed
+	}
+	if _, ok := s.subs[sub.stream]; ok {
+		s.mu.Unlock()
+		return errors.New(fmt.Sprintf("%s already subscribed", sub.stream))
+	}
+	s.subs[sub.stream] = sub
+	conn := s.conn
+	if !s.started {
+		s.started = true
+		go s.run()
+	}
+	s.mu.Unlock()
+
+	if conn != nil {
+		// a failed write breaks the connection, the reconnect then subscribes everything
+		_ = s.send(conn, "SUBSCRIBE", []string{sub.stream})
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Unsubscribe stop a stream by name (e.g. btcusdt@trade) and close its channel
+func (s *MarketStream) Unsubscribe(stream string) error {
+	s.mu.Lock()
+	sub, ok := s.subs[stream]
+	if !ok {
+		s.mu.Unlock()
+		return errors.New(fmt.Sprintf("%s not subscribed", stream))
+	}
+	delete(s.subs, stream)
+	conn := s.conn
+	s.mu.Unlock()
+
+	sub.close()
+	if conn != nil {
+		return s.send(conn, "UNSUBSCRIBE", []string{stream})
+	}
+	return nil
+}
+
+// Streams names of the current subscriptions, sorted
+func (s *MarketStream) Streams() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var streams []string
+	for stream := range s.subs {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	return streams
+}
+
+// Close disconnect and close every channel
+func (s *MarketStream) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	started := s.started
+	conn := s.conn
+	s.mu.Unlock()
+
+	close(s.stop)
+	if conn != nil {
+		conn.Close()
+	}
+	if started {
+		<-s.done
+	}
+
+	s.mu.Lock()
+	for stream, sub := range s.subs {
+		sub.close()
+		delete(s.subs, stream)
+	}
+	s.mu.Unlock()
+	close(s.gaps)
+	close(s.errs)
+}
+
+func (s *MarketStream) send(conn *websocket.Conn, method string, streams []string) error {
+	s.mu.Lock()
+	s.nextId++
+	id := s.nextId
+	s.mu.Unlock()
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return conn.WriteJSON(map[string]interface{}{"method": method, "params": streams, "id": id})
+}
+
+func (s *MarketStream) run() {
+	defer close(s.done)
+	b := &backoff{min: s.cfg.ReconnectMin, max: s.cfg.ReconnectMax}
+	var disconnectedAt time.Time
+	for {
+		streams := s.Streams()
+		if len(streams) == 0 {
+			select {
+			case <-s.stop:
+				return
+			case <-s.wake:
+				continue
+			}
+		}
+
+		conn, err := s.connect(streams)
+		if err != nil {
+			s.reportError(err)
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(b.Next()):
+				continue
+			}
+		}
+		b.Reset()
+		if !disconnectedAt.IsZero() {
+			s.reportReconnect(disconnectedAt)
+		}
+
+		err = s.read(conn)
+		conn.Close()
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		s.reportError(err)
+		disconnectedAt = time.Now()
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(b.Next()):
+		}
+	}
+}
+
+// connect dial the combined stream with the given streams, then SUBSCRIBE anything added while dialing
+func (s *MarketStream) connect(streams []string) (*websocket.Conn, error) {
+	endpoint := fmt.Sprintf("%s/stream?streams=%s", s.cfg.BaseURL, strings.Join(streams, "/"))
+	conn, _, err := s.cfg.Dialer.Dial(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dialed := make(map[string]bool)
+	for _, stream := range streams {
+		dialed[stream] = true
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return nil, ErrStreamClosed
+	}
+	s.conn = conn
+	var added, removed []string
+	for stream := range s.subs {
+		if !dialed[stream] {
+			added = append(added, stream)
+		}
+	}
+	for stream := range dialed {
+		if _, ok := s.subs[stream]; !ok {
+			removed = append(removed, stream)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(added) > 0 {
+		if err = s.send(conn, "SUBSCRIBE", added); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if len(removed) > 0 {
+		if err = s.send(conn, "UNSUBSCRIBE", removed); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// reportReconnect every subscription may have missed events while disconnected
+func (s *MarketStream) reportReconnect(disconnectedAt time.Time) {
+	now := time.Now()
+	s.mu.Lock()
+	var gaps []*StreamGap
+	for _, sub := range s.subs {
+		since := sub.lastAt
+		if since.IsZero() {
+			since = disconnectedAt
+		}
+		gaps = append(gaps, &StreamGap{Stream: sub.stream, Reason: GapReconnect, Since: since, Until: now})
+	}
+	s.mu.Unlock()
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].Stream < gaps[j].Stream })
+	for _, gap := range gaps {
+		s.reportGap(gap)
+	}
+}
+
+type combinedMessage struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+	// SUBSCRIBE / UNSUBSCRIBE replies
+	Id    *int64 `json:"id"`
+	Error *struct {
+		Code int64  `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+}
+
+func (s *MarketStream) read(conn *websocket.Conn) error {
+	for {
+		if s.cfg.StaleAfter > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(s.cfg.StaleAfter))
+		}
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var msg combinedMessage
+		if err = json.Unmarshal(message, &msg); err != nil {
+			s.reportError(fmt.Errorf("stream message %w", err))
+			continue
+		}
+		if msg.Error != nil {
+			s.reportError(errors.New(fmt.Sprintf("stream request %d: <APIError> code=%d, msg=%s", derefInt64(msg.Id), msg.Error.Code, msg.Error.Msg)))
+			continue
+		}
+		if msg.Stream == "" {
+			continue
+		}
+		s.dispatch(msg.Stream, msg.Data)
+	}
+}
+
+func derefInt64(v *int64) int64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// dispatch runs on the read goroutine only, sends are non blocking so holding mu is fine
+func (s *MarketStream) dispatch(stream string, data json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[stream]
+	if !ok {
+		return
+	}
+	first, last, err := sub.decode(data)
+	if err != nil {
+		s.reportError(fmt.Errorf("%s %w", stream, err))
+		return
+	}
+	sub.lastAt = time.Now()
+	if last != 0 && last != sub.lastSeq {
+		if sub.step > 0 && sub.lastSeq != 0 && first > sub.lastSeq+sub.step {
+			s.reportGap(&StreamGap{Stream: stream, Reason: GapSequence, FromSeq: sub.lastSeq + sub.step, ToSeq: first - sub.step})
+		}
+		if last > sub.lastSeq {
+			sub.lastSeq = last
+		}
+	}
+	if !sub.send() {
+		s.reportGap(&StreamGap{Stream: stream, Reason: GapDropped, FromSeq: first, ToSeq: last, Since: sub.lastAt, Until: sub.lastAt})
+	}
+}
@@ -0,0 +1,131 @@
+package convert
+
+import (
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWsConn one accepted websocket connection and the path + query it dialed
+type testWsConn struct {
+	*websocket.Conn
+	URL string
+}
+
+// newTestWsServer websocket server handing every accepted connection to the test
+func newTestWsServer() (*httptest.Server, chan *testWsConn) {
+	conns := make(chan *testWsConn, 8)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- &testWsConn{Conn: conn, URL: r.URL.String()}
+	}))
+	return server, conns
+}
+
+func testWsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func waitConn(conns chan *testWsConn) *testWsConn {
+	select {
+	case conn := <-conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		panic("no websocket connection")
+	}
+}
+
+func TestMarketStream(t *testing.T) {
+	convey.Convey("TestMarketStream", t, func(convCtx convey.C) {
+		server, conns := newTestWsServer()
+		defer server.Close()
+		stream := NewMarketStream(&StreamConfig{BaseURL: testWsURL(server), ReconnectMin: 10 * time.Millisecond, ReconnectMax: 20 * time.Millisecond})
+
+		trades, err := stream.Trade("BTCUSDT")
+		convCtx.So(err, convey.ShouldBeNil)
+		_, err = stream.Trade("BTCUSDT")
+		convCtx.So(err, convey.ShouldNotBeNil)
+		conn := waitConn(conns)
+		convCtx.So(conn.URL, convey.ShouldEqual, "/stream?streams=btcusdt@trade")
+
+		klines, err := stream.Kline("BTCUSDT", "1m")
+		convCtx.So(err, convey.ShouldBeNil)
+		var sub map[string]interface{}
+		convCtx.So(conn.ReadJSON(&sub), convey.ShouldBeNil)
+		convCtx.So(sub["method"], convey.ShouldEqual, "SUBSCRIBE")
+		convCtx.So(sub["params"], convey.ShouldResemble, []interface{}{"btcusdt@kline_1m"})
+		convCtx.So(conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"result":null,"id":%v}`, sub["id"]))), convey.ShouldBeNil)
+
+		for _, id := range []int64{1, 2, 5} {
+			msg := fmt.Sprintf(`{"stream":"btcusdt@trade","data":{"e":"trade","s":"BTCUSDT","t":%d,"p":"20000.01","q":"0.1"}}`, id)
+			convCtx.So(conn.WriteMessage(websocket.TextMessage, []byte(msg)), convey.ShouldBeNil)
+		}
+		for _, open := range []int64{60000, 60000, 240000} {
+			msg := fmt.Sprintf(`{"stream":"btcusdt@kline_1m","data":{"e":"kline","s":"BTCUSDT","k":{"t":%d,"i":"1m","c":"20000"}}}`, open)
+			convCtx.So(conn.WriteMessage(websocket.TextMessage, []byte(msg)), convey.ShouldBeNil)
+		}
+		convCtx.So((<-trades).TradeID, convey.ShouldEqual, 1)
+		convCtx.So((<-trades).TradeID, convey.ShouldEqual, 2)
+		trade := <-trades
+		convCtx.So(trade.TradeID, convey.ShouldEqual, 5)
+		convCtx.So(trade.Price, convey.ShouldEqual, "20000.01")
+		gap := <-stream.Gaps()
+		convCtx.So(*gap, convey.ShouldResemble, StreamGap{Stream: "btcusdt@trade", Reason: GapSequence, FromSeq: 3, ToSeq: 4})
+		for i := 0; i < 3; i++ {
+			<-klines
+		}
+		gap = <-stream.Gaps()
+		convCtx.So(*gap, convey.ShouldResemble, StreamGap{Stream: "btcusdt@kline_1m", Reason: GapSequence, FromSeq: 120000, ToSeq: 180000})
+
+		// server drops the connection, everything is resubscribed on the next one
+		conn.Close()
+		conn = waitConn(conns)
+		convCtx.So(conn.URL, convey.ShouldEqual, "/stream?streams=btcusdt@kline_1m/btcusdt@trade")
+		gap = <-stream.Gaps()
+		convCtx.So(gap.Stream, convey.ShouldEqual, "btcusdt@kline_1m")
+		convCtx.So(gap.Reason, convey.ShouldEqual, GapReconnect)
+		convCtx.So(gap.Until.After(gap.Since), convey.ShouldBeTrue)
+		gap = <-stream.Gaps()
+		convCtx.So(gap.Stream, convey.ShouldEqual, "btcusdt@trade")
+		convCtx.So(<-stream.Errors(), convey.ShouldNotBeNil)
+
+		convCtx.So(conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"btcusdt@trade","data":{"e":"trade","t":8}}`)), convey.ShouldBeNil)
+		convCtx.So((<-trades).TradeID, convey.ShouldEqual, 8)
+		gap = <-stream.Gaps()
+		convCtx.So(gap.FromSeq, convey.ShouldEqual, 6)
+		convCtx.So(gap.ToSeq, convey.ShouldEqual, 7)
+
+		convCtx.So(stream.Unsubscribe("btcusdt@kline_1m"), convey.ShouldBeNil)
+		convCtx.So(conn.ReadJSON(&sub), convey.ShouldBeNil)
+		convCtx.So(sub["method"], convey.ShouldEqual, "UNSUBSCRIBE")
+		_, ok := <-klines
+		convCtx.So(ok, convey.ShouldBeFalse)
+		convCtx.So(stream.Streams(), convey.ShouldResemble, []string{"btcusdt@trade"})
+
+		stream.Close()
+		_, ok = <-trades
+		convCtx.So(ok, convey.ShouldBeFalse)
+		_, err = stream.BookTicker("BTCUSDT")
+		convCtx.So(err, convey.ShouldEqual, ErrStreamClosed)
+	})
+}
+
+func TestIntervalMillis(t *testing.T) {
+	convey.Convey("TestIntervalMillis", t, func(convCtx convey.C) {
+		convCtx.So(intervalMillis("1s"), convey.ShouldEqual, 1000)
+		convCtx.So(intervalMillis("15m"), convey.ShouldEqual, 900000)
+		convCtx.So(intervalMillis("4h"), convey.ShouldEqual, 14400000)
+		convCtx.So(intervalMillis("1w"), convey.ShouldEqual, 604800000)
+		convCtx.So(intervalMillis("1M"), convey.ShouldEqual, 0)
+		convCtx.So(intervalMillis("m"), convey.ShouldEqual, 0)
+	})
+}
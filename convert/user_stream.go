@@ -0,0 +1,517 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/pursonchen/go-binance/v2"
+	"github.com/pursonchen/go-binance/v2/common"
+	"sync"
+	"time"
+)
+
+/*
+用户数据流 (订单 / 余额推送):
+
+Start 创建 listenKey 并连接 /ws/<listenKey>, 每 KeepaliveInterval 续期一次 (binance 60 分钟不续期即失效).
+断线后先续期原 listenKey 再重连, listenKey 失效 (keepalive 返回 -1125 或收到 listenKeyExpired) 则重新创建.
+重连成功后向订阅者推送 RECONNECT 事件, 断线期间的订单变化需要调用方用 GetOrder 自行核对.
+*/
+
+type UserEventType string
+
+const (
+	UserEventExecutionReport  UserEventType = "executionReport"
+	UserEventAccountPosition  UserEventType = "outboundAccountPosition"
+	UserEventBalanceUpdate    UserEventType = "balanceUpdate"
+	UserEventListStatus       UserEventType = "listStatus"
+	UserEventListenKeyExpired UserEventType = "listenKeyExpired"
+	// UserEventReconnect not sent by binance, the stream was down between Since and Until
+	UserEventReconnect UserEventType = "RECONNECT"
+)
+
+// ExecutionReport order update, ExecutionType NEW CANCELED REPLACED REJECTED TRADE EXPIRED
+type ExecutionReport struct {
+	Event                    string                  `json:"e"`
+	EventTime                int64                   `json:"E"`
+	Symbol                   string                  `json:"s"`
+	ClientOrderId            string                  `json:"c"`
+	Side                     binance.SideType        `json:"S"`
+	Type                     binance.OrderType       `json:"o"`
+	TimeInForce              binance.TimeInForceType `json:"f"`
+	Quantity                 string                  `json:"q"`
+	Price                    string                  `json:"p"`
+	StopPrice                string                  `json:"P"`
+	TrailingDelta            int64                   `json:"d"`
+	IcebergQuantity          string                  `json:"F"`
+	OrderListId              int64                   `json:"g"`
+	OrigClientOrderId        string                  `json:"C"` // order being canceled
+	ExecutionType            string                  `json:"x"`
+	Status                   binance.OrderStatusType `json:"X"`
+	RejectReason             string                  `json:"r"`
+	OrderId                  int64                   `json:"i"`
+	LastExecutedQuantity     string                  `json:"l"`
+	CumulativeFilledQuantity string                  `json:"z"`
+	LastExecutedPrice        string                  `json:"L"`
+	Commission               string                  `json:"n"`
+	CommissionAsset          string                  `json:"N"`
+	TransactionTime          int64                   `json:"T"`
+	TradeId                  int64                   `json:"t"`
+	IgnoreI                  int64                   `json:"I"` // keeps "I" from case-insensitively landing in OrderId
+	IsWorking                bool                    `json:"w"` // on the book
+	IsMaker                  bool                    `json:"m"`
+	IgnoreM                  bool                    `json:"M"`
+	CreationTime             int64                   `json:"O"`
+	CumulativeQuoteQuantity  string                  `json:"Z"`
+	LastQuoteQuantity        string                  `json:"Y"`
+	QuoteOrderQuantity       string                  `json:"Q"`
+	WorkingTime              int64                   `json:"W"`
+	SelfTradePrevention      string                  `json:"V"`
+}
+
+type AccountBalance struct {
+	Asset  string `json:"a"`
+	Free   string `json:"f"`
+	Locked string `json:"l"`
+}
+
+// AccountPosition balances of the assets that changed
+type AccountPosition struct {
+	Event          string            `json:"e"`
+	EventTime      int64             `json:"E"`
+	LastUpdateTime int64             `json:"u"`
+	Balances       []*AccountBalance `json:"B"`
+}
+
+// BalanceUpdate deposit, withdrawal or transfer
+type BalanceUpdate struct {
+	Event     string `json:"e"`
+	EventTime int64  `json:"E"`
+	Asset     string `json:"a"`
+	Delta     string `json:"d"`
+	ClearTime int64  `json:"T"`
+}
+
+type ListStatusOrder struct {
+	Symbol        string `json:"s"`
+	OrderId       int64  `json:"i"`
+	ClientOrderId string `json:"c"`
+}
+
+// ListStatus OCO order list update
+type ListStatus struct {
+	Event             string             `json:"e"`
+	EventTime         int64              `json:"E"`
+	Symbol            string             `json:"s"`
+	OrderListId       int64              `json:"g"`
+	ContingencyType   string             `json:"c"`
+	ListStatusType    string             `json:"l"`
+	ListOrderStatus   string             `json:"L"`
+	RejectReason      string             `json:"r"`
+	ListClientOrderId string             `json:"C"`
+	TransactionTime   int64              `json:"T"`
+	Orders            []*ListStatusOrder `json:"O"`
+}
+
+// UserEvent exactly one of the pointers matching Type is set, none for RECONNECT
+type UserEvent struct {
+	Type            UserEventType
+	EventTime       int64
+	ExecutionReport *ExecutionReport
+	AccountPosition *AccountPosition
+	BalanceUpdate   *BalanceUpdate
+	ListStatus      *ListStatus
+	// RECONNECT
+	Since time.Time
+	Until time.Time
+}
+
+func decodeUserEvent(message []byte) (*UserEvent, error) {
+	var head struct {
+		Event     string `json:"e"`
+		EventTime int64  `json:"E"`
+	}
+	if err := json.Unmarshal(message, &head); err != nil {
+		return nil, err
+	}
+	event := &UserEvent{Type: UserEventType(head.Event), EventTime: head.EventTime}
+	var target interface{}
+	switch event.Type {
+	case UserEventExecutionReport:
+		event.ExecutionReport = new(ExecutionReport)
+		target = event.ExecutionReport
+	case UserEventAccountPosition:
+		event.AccountPosition = new(AccountPosition)
+		target = event.AccountPosition
+	case UserEventBalanceUpdate:
+		event.BalanceUpdate = new(BalanceUpdate)
+		target = event.BalanceUpdate
+	case UserEventListStatus:
+		event.ListStatus = new(ListStatus)
+		target = event.ListStatus
+	case UserEventListenKeyExpired:
+		return event, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown user data event %q", head.Event))
+	}
+	if err := json.Unmarshal(message, target); err != nil {
+		return nil, fmt.Errorf("%s %w", head.Event, err)
+	}
+	return event, nil
+}
+
+type UserStreamConfig struct {
+	StreamConfig                    // StaleAfter defaults to 10m, binance pings every 3m
+	KeepaliveInterval time.Duration // default 30m
+}
+
+// UserStream keeps a listenKey alive and fans its events out to subscribers
+type UserStream struct {
+	exchange Exchange
+	cfg      UserStreamConfig
+
+	mu          sync.Mutex
+	listenKey   string
+	conn        *websocket.Conn
+	subscribers map[chan *UserEvent]map[UserEventType]bool
+	started     bool
+	closed      bool
+
+	writeMu sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	errs    chan error
+}
+
+// NewUserStream user data stream of the client's account, call Start to connect
+func (c *SpotClient) NewUserStream(cfg *UserStreamConfig) *UserStream {
+	return NewUserStream(c.exchange, cfg)
+}
+
+func NewUserStream(exchange Exchange, cfg *UserStreamConfig) *UserStream {
+	var c UserStreamConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.StaleAfter == 0 {
+		c.StaleAfter = 10 * time.Minute
+	}
+	c.StreamConfig = c.StreamConfig.withDefaults()
+	if c.KeepaliveInterval <= 0 {
+		c.KeepaliveInterval = 30 * time.Minute
+	}
+	return &UserStream{
+		exchange:    exchange,
+		cfg:         c,
+		subscribers: make(map[chan *UserEvent]map[UserEventType]bool),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		errs:        make(chan error, c.BufferSize),
+	}
+}
+
+// Subscribe events of the given types, every type when none is given.
+// Subscribers that fall BufferSize events behind lose events, reported on Errors
+func (u *UserStream) Subscribe(types ...UserEventType) <-chan *UserEvent {
+	ch := make(chan *UserEvent, u.cfg.BufferSize)
+	filter := make(map[UserEventType]bool)
+	for _, t := range types {
+		filter[t] = true
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		close(ch)
+		return ch
+	}
+	u.subscribers[ch] = filter
+	return ch
+}
+
+// Unsubscribe close a channel returned by Subscribe
+func (u *UserStream) Unsubscribe(ch <-chan *UserEvent) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for sub := range u.subscribers {
+		if sub == ch {
+			delete(u.subscribers, sub)
+			close(sub)
+		}
+	}
+}
+
+// Errors listenKey, connection and decode errors, the stream keeps running after them, closed by Close
+func (u *UserStream) Errors() <-chan error {
+	return u.errs
+}
+
+func (u *UserStream) reportError(err error) {
+	select {
+	case u.errs <- err:
+	default:
+	}
+}
+
+// ListenKey current listenKey, empty before Start and while it is being recreated
+func (u *UserStream) ListenKey() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.listenKey
+}
+
+// Start create the listenKey and connect, later failures are retried in the background
+func (u *UserStream) Start(ctx context.Context) error {
+	u.mu.Lock()
+	if u.closed {
+		u.mu.Unlock()
+		return ErrStreamClosed
+	}
+	if u.started {
+		u.mu.Unlock()
+		return errors.New("user stream already started")
+	}
+	u.mu.Unlock()
+
+	listenKey, err := u.exchange.StartUserStream(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := u.dial(listenKey)
+	if err != nil {
+		_ = u.exchange.CloseUserStream(ctx, listenKey)
+		return err
+	}
+
+	u.mu.Lock()
+	if u.closed {
+		// Close ran while dialing and did not see this listenKey
+		u.mu.Unlock()
+		conn.Close()
+		_ = u.exchange.CloseUserStream(ctx, listenKey)
+		return ErrStreamClosed
+	}
+	u.started = true
+	u.listenKey = listenKey
+	u.conn = conn
+	u.mu.Unlock()
+
+	go u.keepalive()
+	go u.run(conn)
+	return nil
+}
+
+// Close disconnect, delete the listenKey and close every channel
+func (u *UserStream) Close() {
+	u.mu.Lock()
+	if u.closed {
+		u.mu.Unlock()
+		return
+	}
+	u.closed = true
+	started := u.started
+	conn := u.conn
+	u.mu.Unlock()
+
+	close(u.stop)
+	if conn != nil {
+		conn.Close()
+	}
+	if started {
+		<-u.done
+	}
+
+	u.mu.Lock()
+	listenKey := u.listenKey
+	u.listenKey = ""
+	for sub := range u.subscribers {
+		delete(u.subscribers, sub)
+		close(sub)
+	}
+	u.mu.Unlock()
+	if listenKey != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_ = u.exchange.CloseUserStream(ctx, listenKey)
+		cancel()
+	}
+	close(u.errs)
+}
+
+func (u *UserStream) dial(listenKey string) (*websocket.Conn, error) {
+	conn, _, err := u.cfg.Dialer.Dial(fmt.Sprintf("%s/ws/%s", u.cfg.BaseURL, listenKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetPingHandler(func(data string) error {
+		if u.cfg.StaleAfter > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(u.cfg.StaleAfter))
+		}
+		u.writeMu.Lock()
+		defer u.writeMu.Unlock()
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+	return conn, nil
+}
+
+// isListenKeyGone -1125 This listenKey does not exist.
+func isListenKeyGone(err error) bool {
+	var apiErr *common.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == -1125
+}
+
+// expireListenKey forget the listenKey and drop the connection so run creates a new one
+func (u *UserStream) expireListenKey(listenKey string) {
+	u.mu.Lock()
+	if u.listenKey != listenKey {
+		u.mu.Unlock()
+		return
+	}
+	u.listenKey = ""
+	conn := u.conn
+	u.mu.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func (u *UserStream) keepalive() {
+	ticker := time.NewTicker(u.cfg.KeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-u.stop:
+			return
+		case <-ticker.C:
+		}
+		listenKey := u.ListenKey()
+		if listenKey == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := u.exchange.KeepaliveUserStream(ctx, listenKey)
+		cancel()
+		if err != nil {
+			u.reportError(fmt.Errorf("keepalive listenKey %w", err))
+			if isListenKeyGone(err) {
+				u.expireListenKey(listenKey)
+			}
+		}
+	}
+}
+
+// reconnect reuse the listenKey while binance still knows it, otherwise create a new one
+func (u *UserStream) reconnect() (*websocket.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	listenKey := u.ListenKey()
+	if listenKey != "" {
+		if err := u.exchange.KeepaliveUserStream(ctx, listenKey); err != nil {
+			if !isListenKeyGone(err) {
+				return nil, fmt.Errorf("keepalive listenKey %w", err)
+			}
+			listenKey = ""
+		}
+	}
+	if listenKey == "" {
+		var err error
+		if listenKey, err = u.exchange.StartUserStream(ctx); err != nil {
+			return nil, fmt.Errorf("create listenKey %w", err)
+		}
+	}
+	conn, err := u.dial(listenKey)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	if u.closed {
+		// Close already ran and only knew the previous listenKey
+		u.mu.Unlock()
+		conn.Close()
+		_ = u.exchange.CloseUserStream(ctx, listenKey)
+		return nil, ErrStreamClosed
+	}
+	u.listenKey = listenKey
+	u.conn = conn
+	u.mu.Unlock()
+	return conn, nil
+}
+
+func (u *UserStream) run(conn *websocket.Conn) {
+	defer close(u.done)
+	b := &backoff{min: u.cfg.ReconnectMin, max: u.cfg.ReconnectMax}
+	for {
+		err := u.read(conn)
+		conn.Close()
+		u.mu.Lock()
+		u.conn = nil
+		u.mu.Unlock()
+		select {
+		case <-u.stop:
+			return
+		default:
+		}
+		if err != nil {
+			u.reportError(err)
+		}
+
+		lastAt := time.Now()
+		for {
+			select {
+			case <-u.stop:
+				return
+			case <-time.After(b.Next()):
+			}
+			if conn, err = u.reconnect(); err == nil {
+				break
+			}
+			if errors.Is(err, ErrStreamClosed) {
+				return
+			}
+			u.reportError(err)
+		}
+		b.Reset()
+		u.publish(&UserEvent{Type: UserEventReconnect, Since: lastAt, Until: time.Now()})
+	}
+}
+
+// read until the connection breaks, nil when binance expired the listenKey
+func (u *UserStream) read(conn *websocket.Conn) error {
+	for {
+		if u.cfg.StaleAfter > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(u.cfg.StaleAfter))
+		}
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		event, err := decodeUserEvent(message)
+		if err != nil {
+			u.reportError(err)
+			continue
+		}
+		if event.Type == UserEventListenKeyExpired {
+			u.mu.Lock()
+			u.listenKey = ""
+			u.mu.Unlock()
+			return nil
+		}
+		u.publish(event)
+	}
+}
+
+func (u *UserStream) publish(event *UserEvent) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for sub, filter := range u.subscribers {
+		if len(filter) > 0 && !filter[event.Type] && event.Type != UserEventReconnect {
+			continue
+		}
+		select {
+		case sub <- event:
+		default:
+			u.reportError(errors.New(fmt.Sprintf("user stream subscriber full, dropped %s", event.Type)))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package convert
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/smartystreets/goconvey/convey"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUserStream(t *testing.T) {
+	convey.Convey("TestUserStream", t, func(convCtx convey.C) {
+		server, fake := newTestMockServer()
+		defer server.Close()
+		ws, conns := newTestWsServer()
+		defer ws.Close()
+		cli := NewSpotClient(server.Client())
+
+		stream := cli.NewUserStream(&UserStreamConfig{
+			StreamConfig:      StreamConfig{BaseURL: testWsURL(ws), ReconnectMin: 10 * time.Millisecond, ReconnectMax: 20 * time.Millisecond},
+			KeepaliveInterval: 20 * time.Millisecond,
+		})
+		all := stream.Subscribe()
+		orders := stream.Subscribe(UserEventExecutionReport)
+		convCtx.So(stream.Start(context.Background()), convey.ShouldBeNil)
+		convCtx.So(stream.Start(context.Background()), convey.ShouldNotBeNil)
+		conn := waitConn(conns)
+		convCtx.So(conn.URL, convey.ShouldEqual, "/ws/fakelistenkey1")
+		convCtx.So(stream.ListenKey(), convey.ShouldEqual, "fakelistenkey1")
+
+		for _, msg := range []string{
+			`{"e":"executionReport","E":1499405658658,"s":"LUNCBUSD","c":"mUvoqJxFIILMdfAW5iGSOW","S":"BUY","o":"LIMIT","f":"GTC","q":"100000.00000000","p":"0.00020000","P":"0.00000000","F":"0.00000000","g":-1,"C":"","x":"TRADE","X":"FILLED","r":"NONE","i":4293153,"l":"100000.00000000","z":"100000.00000000","L":"0.00020000","n":"0.02000000","N":"BUSD","T":1499405658657,"t":77,"I":8641984,"w":false,"m":true,"M":false,"O":1499405658657,"Z":"20.00000000","Y":"20.00000000","Q":"0.00000000"}`,
+			`{"e":"outboundAccountPosition","E":1564034571105,"u":1564034571073,"B":[{"a":"BUSD","f":"79.98","l":"0.00"},{"a":"LUNC","f":"100000","l":"0"}]}`,
+			`{"e":"balanceUpdate","E":1573200697110,"a":"BUSD","d":"100.00","T":1573200697068}`,
+		} {
+			convCtx.So(conn.WriteMessage(websocket.TextMessage, []byte(msg)), convey.ShouldBeNil)
+		}
+
+		report := (<-orders).ExecutionReport
+		convCtx.So(report.OrderId, convey.ShouldEqual, 4293153)
+		convCtx.So(report.Status, convey.ShouldEqual, "FILLED")
+		convCtx.So(report.CumulativeQuoteQuantity, convey.ShouldEqual, "20.00000000")
+		convCtx.So(report.CommissionAsset, convey.ShouldEqual, "BUSD")
+		convCtx.So((<-all).Type, convey.ShouldEqual, UserEventExecutionReport)
+		position := <-all
+		convCtx.So(position.AccountPosition.Balances[1].Asset, convey.ShouldEqual, "LUNC")
+		balance := <-all
+		convCtx.So(balance.BalanceUpdate.Delta, convey.ShouldEqual, "100.00")
+		convCtx.So(balance.EventTime, convey.ShouldEqual, 1573200697110)
+
+		// binance expires the key, a new one is created and subscribers learn about the gap
+		convCtx.So(conn.WriteMessage(websocket.TextMessage, []byte(`{"e":"listenKeyExpired","E":1576653824250,"listenKey":"fakelistenkey1"}`)), convey.ShouldBeNil)
+		conn = waitConn(conns)
+		convCtx.So(conn.URL, convey.ShouldEqual, "/ws/fakelistenkey2")
+		convCtx.So((<-orders).Type, convey.ShouldEqual, UserEventReconnect)
+		convCtx.So((<-all).Type, convey.ShouldEqual, UserEventReconnect)
+
+		// a dropped connection keeps a listenKey that is still alive
+		conn.Close()
+		conn = waitConn(conns)
+		convCtx.So(conn.URL, convey.ShouldEqual, "/ws/fakelistenkey2")
+		convCtx.So((<-all).Type, convey.ShouldEqual, UserEventReconnect)
+
+		fake.ExpireListenKey("fakelistenkey2")
+		conn = waitConn(conns)
+		convCtx.So(conn.URL, convey.ShouldEqual, "/ws/fakelistenkey3")
+
+		stream.Unsubscribe(orders)
+		stream.Close()
+		_, ok := <-all
+		convCtx.So(ok, convey.ShouldBeFalse)
+		convCtx.So(fake.KeepaliveUserStream(context.Background(), "fakelistenkey3"), convey.ShouldNotBeNil)
+
+		var keepalives int
+		for _, req := range server.Requests() {
+			if req.Method == "PUT" && req.Path == "/api/v3/userDataStream" {
+				keepalives++
+				convCtx.So(req.Header.Get("X-MBX-APIKEY"), convey.ShouldEqual, "mockApiKey")
+			}
+		}
+		convCtx.So(keepalives, convey.ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestUserStreamCloseWhileDialing(t *testing.T) {
+	convey.Convey("TestUserStreamCloseWhileDialing", t, func(convCtx convey.C) {
+		fake := newTestFakeExchange()
+		ws, _ := newTestWsServer()
+		defer ws.Close()
+		cli := NewSpotClientWithExchange(fake)
+
+		var stream *UserStream
+		var alive error
+		dialer := &websocket.Dialer{NetDial: func(network, addr string) (net.Conn, error) {
+			alive = fake.KeepaliveUserStream(context.Background(), "fakelistenkey1")
+			stream.Close()
+			return net.Dial(network, addr)
+		}}
+		stream = cli.NewUserStream(&UserStreamConfig{StreamConfig: StreamConfig{BaseURL: testWsURL(ws), Dialer: dialer}})
+		convCtx.So(stream.Start(context.Background()), convey.ShouldEqual, ErrStreamClosed)
+		convCtx.So(alive, convey.ShouldBeNil)
+		convCtx.So(fake.KeepaliveUserStream(context.Background(), "fakelistenkey1"), convey.ShouldNotBeNil)
+	})
+}